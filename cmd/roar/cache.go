@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"roar/internal/app"
+	"roar/internal/pkg/cache"
+	"roar/internal/pkg/logger"
+
+	"github.com/spf13/pflag"
+)
+
+func cacheCommand() *command {
+	return &command{
+		name:    "cache",
+		args:    "info|clean [CHART_PATH]",
+		summary: "Show the size of the render cache or remove all its entries",
+		words:   []string{"info", "clean"},
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.Config{}
+			fs.StringVar(&cfg.CacheDir, "cache-dir", "", "Directory of the render cache")
+			fs.StringVarP(&cfg.LogLevel, "log-level", "l", "warn", "Log level (debug, info, warn, error)")
+			addConfigFlag(fs)
+
+			return func(args []string) {
+				setupLogger(cfg.LogLevel)
+				if len(args) == 0 || len(args) > 2 || (args[0] != "info" && args[0] != "clean") {
+					logger.Log.Error("Error: expected 'info' or 'clean' and at most one argument [CHART_PATH].")
+					fs.Usage()
+					os.Exit(1)
+				}
+				if len(args) == 2 {
+					cfg.ChartPath = args[1]
+				}
+				if _, err := applyConfigFile(fs, &cfg); err != nil {
+					logger.Log.Fatalf("Could not load config file: %v", err)
+				}
+				if cfg.CacheDir == "" {
+					logger.Log.Fatal("No render cache configured: set --cache-dir or render.cacheDir in the config file.")
+				}
+
+				c, err := cache.New(cfg.CacheDir)
+				if err != nil {
+					logger.Log.Fatalf("%v", err)
+				}
+				if args[0] == "clean" {
+					if err := c.Clean(); err != nil {
+						logger.Log.Fatalf("%v", err)
+					}
+					fmt.Printf("Removed all render cache entries from %s\n", c.Dir())
+					return
+				}
+				entries, size, err := c.Stats()
+				if err != nil {
+					logger.Log.Fatalf("%v", err)
+				}
+				fmt.Printf("Directory: %s\nEntries:   %d\nSize:      %d bytes\n", c.Dir(), entries, size)
+			}
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// command is a roar subcommand. setup registers the flags of the command and
+// returns the function that runs it with the remaining positional arguments.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// details are printed in the usage of the command below the summary.
+	details string
+	// words are the fixed first arguments offered by shell completion.
+	words []string
+	setup func(fs *pflag.FlagSet) func(args []string)
+}
+
+// commands returns all subcommands in the order they are listed in the help.
+func commands() []*command {
+	return []*command{
+		renderCommand(),
+		listCommand(),
+		diffCommand(),
+		compareCommand(),
+		validateCommand(),
+		explainCommand(),
+		imagesCommand(),
+		cacheCommand(),
+		configCommand(),
+		completionCommand(),
+		versionCommand(),
+	}
+}
+
+func commandNames() []string {
+	var names []string
+	for _, c := range commands() {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func findCommand(name string) *command {
+	if name == "help" {
+		return helpCommand()
+	}
+	for _, c := range commands() {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *command) flagSet() (*pflag.FlagSet, func([]string)) {
+	fs := pflag.NewFlagSet(c.name, pflag.ExitOnError)
+	run := c.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: roar %s [flags]\n\n", strings.TrimSpace(c.name+" "+c.args))
+		fmt.Fprintf(os.Stderr, "%s.\n", c.summary)
+		if c.details != "" {
+			fmt.Fprintf(os.Stderr, "%s\n", c.details)
+		}
+		if c.name == "render" {
+			fmt.Fprintf(os.Stderr, "'render' is the default command: 'roar CHART_PATH' is the same as 'roar render CHART_PATH'.\n\n")
+			printCommands()
+		}
+		if fs.HasFlags() {
+			fmt.Fprintf(os.Stderr, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs, run
+}
+
+func (c *command) execute(argv []string) {
+	fs, run := c.flagSet()
+	_ = fs.Parse(argv)
+	run(fs.Args())
+}
+
+func printCommands() {
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, c := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'roar help COMMAND' for the flags of a command.\n")
+}
+
+func helpCommand() *command {
+	return &command{
+		name:    "help",
+		args:    "[COMMAND]",
+		summary: "Show help for roar or one of its commands",
+		words:   commandNames(),
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			return func(args []string) {
+				if len(args) == 1 {
+					if c := findCommand(args[0]); c != nil {
+						cfs, _ := c.flagSet()
+						cfs.Usage()
+						return
+					}
+				}
+				fmt.Fprintf(os.Stderr, "Usage: roar [COMMAND] [flags]\n\n")
+				printCommands()
+			}
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"roar/internal/app"
+	"roar/internal/pkg/logger"
+
+	"github.com/spf13/pflag"
+)
+
+func compareCommand() *command {
+	return &command{
+		name:    "compare",
+		args:    "--base REV --head REV CHART_PATH",
+		summary: "Render CHART_PATH at two revisions of its git repository and compare them",
+		details: "Reports changed Applications, parameters and manifests.\nExits with 0 when there are no changes, 1 when changes are present and 2 on errors, including Applications that failed to render.",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.CompareConfig{}
+			addRenderFlags(fs, &cfg.Render)
+			fs.StringVar(&cfg.Base, "base", "main", "Base revision of the repository containing CHART_PATH")
+			fs.StringVar(&cfg.Head, "head", "HEAD", "Head revision of the repository containing CHART_PATH")
+			markdown := addMarkdownFlags(fs)
+
+			return func(args []string) {
+				prepareConfig(fs, &cfg.Render, args, exitError)
+
+				changes, err := app.Compare(cfg)
+				if err != nil {
+					logger.Log.Errorf("Compare failed: %v", err)
+					os.Exit(exitError)
+				}
+				reportChanges(changes, markdown)
+			}
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"roar/internal/pkg/logger"
+
+	"github.com/spf13/pflag"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+func completionCommand() *command {
+	return &command{
+		name:    "completion",
+		args:    "bash|zsh|fish",
+		summary: "Print a shell completion script, e.g. 'source <(roar completion bash)'",
+		words:   completionShells,
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			return func(args []string) {
+				setupLogger("warn")
+				if len(args) != 1 {
+					logger.Log.Error("Error: exactly one argument (bash, zsh or fish) is required.")
+					fs.Usage()
+					os.Exit(1)
+				}
+				switch args[0] {
+				case "bash":
+					fmt.Print(bashCompletion())
+				case "zsh":
+					fmt.Print("#compdef roar\n\nautoload -U +X bashcompinit && bashcompinit\n\n" + bashCompletion())
+				case "fish":
+					fmt.Print(fishCompletion())
+				default:
+					logger.Log.Fatalf("Unsupported shell '%s', expected one of %s.", args[0], strings.Join(completionShells, ", "))
+				}
+			}
+		},
+	}
+}
+
+func commandFlags(c *command) *pflag.FlagSet {
+	fs, _ := c.flagSet()
+	return fs
+}
+
+func bashCompletion() string {
+	var b strings.Builder
+	b.WriteString("# bash completion for roar\n")
+	b.WriteString("_roar() {\n")
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "    local commands=%q\n", strings.Join(commandNames(), " "))
+	b.WriteString("    local cmd=render flags words\n")
+	b.WriteString("    if [[ ${COMP_CWORD} -gt 1 && \" ${commands} \" == *\" ${COMP_WORDS[1]} \"* ]]; then\n")
+	b.WriteString("        cmd=\"${COMP_WORDS[1]}\"\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    case \"${cmd}\" in\n")
+	for _, c := range commands() {
+		var flags []string
+		commandFlags(c).VisitAll(func(f *pflag.Flag) {
+			flags = append(flags, "--"+f.Name)
+			if f.Shorthand != "" {
+				flags = append(flags, "-"+f.Shorthand)
+			}
+		})
+		fmt.Fprintf(&b, "        %s) flags=%q; words=%q ;;\n", c.name, strings.Join(flags, " "), strings.Join(c.words, " "))
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    if [[ \"${cur}\" == -* ]]; then\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"${flags}\" -- \"${cur}\"))\n")
+	b.WriteString("    elif [[ ${COMP_CWORD} -eq 1 ]]; then\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"${commands}\" -- \"${cur}\"))\n")
+	b.WriteString("    elif [[ ${COMP_CWORD} -eq 2 && -n \"${words}\" ]]; then\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"${words}\" -- \"${cur}\"))\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o default -F _roar roar\n")
+	return b.String()
+}
+
+func fishCompletion() string {
+	names := strings.Join(commandNames(), " ")
+	var b strings.Builder
+	b.WriteString("# fish completion for roar\n")
+	for _, c := range commands() {
+		fmt.Fprintf(&b, "complete -c roar -n '__fish_use_subcommand' -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	for _, c := range commands() {
+		condition := "__fish_seen_subcommand_from " + c.name
+		if c.name == "render" {
+			// render is the default command and may be omitted.
+			condition = "not __fish_seen_subcommand_from " + strings.TrimPrefix(names, "render ")
+		}
+		if len(c.words) > 0 {
+			fmt.Fprintf(&b, "complete -c roar -n '%s' -a %s\n", condition, fishQuote(strings.Join(c.words, " ")))
+		}
+		commandFlags(c).VisitAll(func(f *pflag.Flag) {
+			fmt.Fprintf(&b, "complete -c roar -n '%s' -l %s", condition, f.Name)
+			if f.Shorthand != "" {
+				fmt.Fprintf(&b, " -s %s", f.Shorthand)
+			}
+			fmt.Fprintf(&b, " -d %s\n", fishQuote(f.Usage))
+		})
+	}
+	return b.String()
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
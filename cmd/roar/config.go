@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"roar/internal/app"
+	"roar/internal/pkg/config"
+	"roar/internal/pkg/logger"
+
+	"github.com/spf13/pflag"
+)
+
+// applyConfigFile loads the file given with --config, or the one discovered
+// next to the chart or in the working directory, and copies its values into
+// cfg for every flag that was not set on the command line. Settings of flags
+// the command does not have are ignored. It returns the path of the loaded
+// file, or an empty string when there is none.
+func applyConfigFile(fs *pflag.FlagSet, cfg *app.Config) (string, error) {
+	path, _ := fs.GetString("config")
+	if path == "" {
+		var err error
+		if path, err = config.Find(cfg.ChartPath); err != nil || path == "" {
+			return "", err
+		}
+	}
+	file, err := config.Load(path)
+	if err != nil {
+		return "", err
+	}
+
+	useFile := func(name string) bool {
+		f := fs.Lookup(name)
+		return f != nil && !f.Changed
+	}
+	setString := func(name string, target *string, value string) {
+		if value != "" && useFile(name) {
+			*target = value
+		}
+	}
+	setStrings := func(name string, target *[]string, value []string) {
+		if len(value) > 0 && useFile(name) {
+			*target = value
+		}
+	}
+	setStringMap := func(name string, target *map[string]string, value map[string]string) {
+		if len(value) > 0 && useFile(name) {
+			*target = value
+		}
+	}
+	setInt := func(name string, target *int, value int) {
+		if value != 0 && useFile(name) {
+			*target = value
+		}
+	}
+	setBool := func(name string, target *bool, value *bool) {
+		if value != nil && useFile(name) {
+			*target = *value
+		}
+	}
+
+	setStrings("values", &cfg.ValuesFiles, file.Values)
+	setString("output-dir", &cfg.OutputDir, file.Output.Dir)
+	setString("layout", &cfg.Layout, file.Output.Layout)
+	setString("output-mode", &cfg.OutputMode, file.Output.Mode)
+	setBool("kustomization", &cfg.Kustomization, file.Output.Kustomization)
+	setBool("prune", &cfg.Prune, file.Output.Prune)
+	setBool("sync-order", &cfg.SyncOrder, file.Output.SyncOrder)
+	setBool("normalize", &cfg.Normalize, file.Normalize.Enabled)
+	setStrings("strip-label", &cfg.NormalizeOpts.StripLabels, file.Normalize.StripLabels)
+	setStrings("strip-annotation", &cfg.NormalizeOpts.StripAnnotations, file.Normalize.StripAnnotations)
+	setStrings("app", &cfg.Filter.Apps, file.Filter.Apps)
+	setString("selector", &cfg.Filter.Selector, file.Filter.Selector)
+	setStrings("env", &cfg.Filter.Envs, file.Filter.Envs)
+	setStrings("instance", &cfg.Filter.Instances, file.Filter.Instances)
+	setStrings("repo", &cfg.Filter.Repos, file.Filter.Repos)
+	setString("cache-dir", &cfg.CacheDir, file.Render.CacheDir)
+	setString("kube-version", &cfg.KubeVersion, file.Render.KubeVersion)
+	setInt("concurrency", &cfg.Concurrency, file.Render.Concurrency)
+	setBool("lenient", &cfg.Lenient, file.Render.Lenient)
+	setString("ssh-key-file", &cfg.SSHKeyFile, file.Git.SSHKeyFile)
+	setStringMap("repo-rewrite", &cfg.RepoRewrites, file.Git.Rewrites)
+	setStrings("application-api-version", &cfg.Applications.APIVersions, file.Applications.APIVersions)
+	setString("control-plane-namespace", &cfg.Applications.ControlPlaneNamespace, file.Applications.ControlPlaneNamespace)
+	setBool("validate-schemas", &cfg.ValidateSchemas, file.Validate.Schemas)
+	setStrings("schema-dir", &cfg.SchemaDirs, file.Validate.SchemaDirs)
+	setStrings("policy-dir", &cfg.PolicyDirs, file.Validate.PolicyDirs)
+	setBool("check-deprecated-apis", &cfg.CheckDeprecatedAPIs, file.Validate.DeprecatedAPIs)
+	setString("target-kube-version", &cfg.TargetKubeVersion, file.Validate.TargetKubeVersion)
+	setBool("check-duplicates", &cfg.CheckDuplicates, file.Validate.Duplicates)
+	setString("report", &cfg.ReportFile, file.Reports.JSON)
+	setString("junit", &cfg.JUnitFile, file.Reports.JUnit)
+	setString("log-level", &cfg.LogLevel, file.LogLevel)
+	return path, nil
+}
+
+// configFile is the inverse of applyConfigFile and describes cfg in the
+// format of the configuration file.
+func configFile(cfg app.Config) *config.File {
+	return &config.File{
+		Values: cfg.ValuesFiles,
+		Output: config.Output{
+			Dir:           cfg.OutputDir,
+			Layout:        cfg.Layout,
+			Mode:          cfg.OutputMode,
+			Kustomization: &cfg.Kustomization,
+			Prune:         &cfg.Prune,
+			SyncOrder:     &cfg.SyncOrder,
+		},
+		Normalize: config.Normalize{
+			Enabled:          &cfg.Normalize,
+			StripLabels:      cfg.NormalizeOpts.StripLabels,
+			StripAnnotations: cfg.NormalizeOpts.StripAnnotations,
+		},
+		Filter: config.Filter{
+			Apps:      cfg.Filter.Apps,
+			Selector:  cfg.Filter.Selector,
+			Envs:      cfg.Filter.Envs,
+			Instances: cfg.Filter.Instances,
+			Repos:     cfg.Filter.Repos,
+		},
+		Render: config.Render{CacheDir: cfg.CacheDir, KubeVersion: cfg.KubeVersion, Concurrency: cfg.Concurrency, Lenient: &cfg.Lenient},
+		Git:    config.Git{SSHKeyFile: cfg.SSHKeyFile, Rewrites: cfg.RepoRewrites},
+		Applications: config.Applications{
+			APIVersions:           cfg.Applications.APIVersions,
+			ControlPlaneNamespace: cfg.Applications.ControlPlaneNamespace,
+		},
+		Validate: config.Validate{Schemas: &cfg.ValidateSchemas, SchemaDirs: cfg.SchemaDirs, PolicyDirs: cfg.PolicyDirs, DeprecatedAPIs: &cfg.CheckDeprecatedAPIs, TargetKubeVersion: cfg.TargetKubeVersion, Duplicates: &cfg.CheckDuplicates},
+		Reports:  config.Reports{JSON: cfg.ReportFile, JUnit: cfg.JUnitFile},
+		LogLevel: cfg.LogLevel,
+	}
+}
+
+func configCommand() *command {
+	return &command{
+		name:    "config",
+		args:    "print [CHART_PATH]",
+		summary: "Print the effective configuration: " + config.FileName + " merged with the given flags",
+		words:   []string{"print"},
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.Config{}
+			addRenderFlags(fs, &cfg)
+			addCheckFlags(fs, &cfg)
+			addRunFlags(fs, &cfg)
+
+			return func(args []string) {
+				setupLogger(cfg.LogLevel)
+				if len(args) == 0 || args[0] != "print" || len(args) > 2 {
+					logger.Log.Error("Error: expected 'print' and at most one argument [CHART_PATH].")
+					fs.Usage()
+					os.Exit(1)
+				}
+				if len(args) == 2 {
+					cfg.ChartPath = args[1]
+				}
+
+				path, err := applyConfigFile(fs, &cfg)
+				if err != nil {
+					logger.Log.Fatalf("Could not load config file: %v", err)
+				}
+				if err := cfg.CheckKubeVersions(); err != nil {
+					logger.Log.Fatalf("Invalid configuration: %v", err)
+				}
+				data, err := configFile(cfg).Marshal()
+				if err != nil {
+					logger.Log.Fatalf("%v", err)
+				}
+				if path != "" {
+					fmt.Printf("# config file: %s\n", path)
+				} else {
+					fmt.Printf("# no config file found, showing flag values\n")
+				}
+				os.Stdout.Write(data)
+			}
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+
+	"roar/internal/app"
+	"roar/internal/pkg/diff"
+	"roar/internal/pkg/logger"
+	"roar/internal/pkg/report"
+
+	"github.com/spf13/pflag"
+)
+
+// Exit codes of `roar diff`, following diff(1): 0 when there are no changes,
+// 1 when changes are present and 2 on errors, including Applications that
+// failed to render.
+const (
+	exitNoChanges = 0
+	exitChanges   = 1
+	exitError     = 2
+)
+
+func diffCommand() *command {
+	return &command{
+		name:    "diff",
+		args:    "CHART_PATH",
+		summary: "Render into a scratch directory and compare with a previous output",
+		details: "Exits with 0 when there are no changes, 1 when changes are present and 2 on errors, including Applications that failed to render.",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.DiffConfig{}
+			addRenderFlags(fs, &cfg.Render)
+			fs.StringVar(&cfg.AgainstDir, "against", "", "Existing output directory to compare with")
+			fs.StringVar(&cfg.AgainstRef, "against-ref", "", "Git ref of the rendered-manifests repository to compare with (instead of --against)")
+			fs.StringVar(&cfg.AgainstRepo, "against-repo", ".", "Path to the rendered-manifests git repository used with --against-ref")
+			fs.StringVar(&cfg.AgainstPath, "against-path", "", "Output directory inside the rendered-manifests repository used with --against-ref")
+			markdown := addMarkdownFlags(fs)
+
+			return func(args []string) {
+				prepareConfig(fs, &cfg.Render, args, exitError)
+				if (cfg.AgainstDir == "") == (cfg.AgainstRef == "") {
+					logger.Log.Error("Error: exactly one of --against or --against-ref is required.")
+					fs.Usage()
+					os.Exit(exitError)
+				}
+
+				changes, err := app.Diff(cfg)
+				if err != nil {
+					logger.Log.Errorf("Diff failed: %v", err)
+					os.Exit(exitError)
+				}
+				reportChanges(changes, markdown)
+			}
+		},
+	}
+}
+
+type markdownFlags struct {
+	path    string
+	maxDiff int
+}
+
+func addMarkdownFlags(fs *pflag.FlagSet) *markdownFlags {
+	flags := &markdownFlags{}
+	fs.StringVar(&flags.path, "markdown", "", "Write a Markdown report suitable for a merge request note to this file")
+	fs.IntVar(&flags.maxDiff, "markdown-max-diff", report.DefaultMarkdownMaxDiff, "Maximum diff size in bytes shown per Application in the Markdown report (0 for unlimited)")
+	return flags
+}
+
+// reportChanges prints the unified diff, writes the optional Markdown report
+// and exits with the code matching the change set. A failed Application is an
+// error even when other Applications changed: CI must not mistake a broken
+// render for a change.
+func reportChanges(changes *diff.ChangeSet, markdown *markdownFlags) {
+	if err := changes.WriteUnified(os.Stdout); err != nil {
+		logger.Log.Errorf("Failed to write diff: %v", err)
+		os.Exit(exitError)
+	}
+	if markdown.path != "" {
+		if err := writeMarkdown(markdown, changes); err != nil {
+			logger.Log.Errorf("Failed to write Markdown report: %v", err)
+			os.Exit(exitError)
+		}
+	}
+	if failed := changes.Count(diff.StatusFailed); failed > 0 {
+		logger.Log.Errorf("%d Application(s) failed to render", failed)
+		os.Exit(exitError)
+	}
+	if changes.HasChanges() {
+		os.Exit(exitChanges)
+	}
+	os.Exit(exitNoChanges)
+}
+
+func writeMarkdown(markdown *markdownFlags, changes *diff.ChangeSet) error {
+	f, err := os.Create(markdown.path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteMarkdown(f, changes, report.MarkdownOptions{MaxDiffBytes: markdown.maxDiff}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"roar/internal/app"
+	"roar/internal/pkg/logger"
+
+	"github.com/spf13/pflag"
+)
+
+func explainCommand() *command {
+	return &command{
+		name:    "explain",
+		args:    "APP CHART_PATH",
+		summary: "Show how the parameters of one Application were resolved and how it is going to be rendered",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.ExplainConfig{}
+			addRenderFlags(fs, &cfg.Render)
+			fs.BoolVar(&cfg.Offline, "offline", false, "Do not clone the Application repository; the chart and values files are not checked")
+
+			return func(args []string) {
+				if len(args) != 2 {
+					setupLogger(cfg.Render.LogLevel)
+					logger.Log.Error("Error: exactly two arguments APP and CHART_PATH are required.")
+					fs.Usage()
+					os.Exit(1)
+				}
+				prepareConfig(fs, &cfg.Render, args[1:], 1)
+				cfg.App = args[0]
+				if err := app.Explain(cfg); err != nil {
+					logger.Log.Fatalf("Explain failed: %v", err)
+				}
+			}
+		},
+	}
+}
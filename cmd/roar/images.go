@@ -0,0 +1,33 @@
+package main
+
+import (
+	"roar/internal/app"
+	"roar/internal/pkg/images"
+	"roar/internal/pkg/logger"
+
+	"github.com/spf13/pflag"
+)
+
+func imagesCommand() *command {
+	return &command{
+		name:    "images",
+		args:    "CHART_PATH",
+		summary: "Render into a scratch directory and list the container images of every Application",
+		details: "Images are taken from the pod specs of Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs,\nand from the --image-path JSONPaths of other kinds.",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.ImagesConfig{}
+			addRenderFlags(fs, &cfg.Render)
+			fs.StringVar(&cfg.Format, "format", images.FormatJSON, "Inventory format: 'json' or 'csv'")
+			fs.StringVar(&cfg.OutputFile, "inventory", "-", "File to write the inventory to ('-' for stdout)")
+			fs.StringSliceVar(&cfg.Paths, "image-path", []string{}, "KIND=JSONPATH locating images in custom resources, e.g. 'Rollout=.spec.template.spec.containers[*].image' (can be repeated)")
+			fs.BoolVar(&cfg.FlagMutable, "flag-mutable-tags", false, "Mark images that are not pinned by digest as mutable")
+
+			return func(args []string) {
+				prepareConfig(fs, &cfg.Render, args, 1)
+				if err := app.Images(cfg); err != nil {
+					logger.Log.Fatalf("Image inventory failed: %v", err)
+				}
+			}
+		},
+	}
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"roar/internal/app"
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/config"
 	"roar/internal/pkg/logger"
+	"roar/internal/pkg/output"
 
 	"github.com/spf13/pflag"
 )
@@ -13,46 +18,217 @@ import (
 var version = "dev"
 
 func main() {
+	cmd, argv := renderCommand(), os.Args[1:]
+	if len(argv) > 0 {
+		if c := findCommand(argv[0]); c != nil {
+			cmd, argv = c, argv[1:]
+		}
+	}
+	cmd.execute(argv)
+}
+
+func renderCommand() *command {
+	return &command{
+		name:    "render",
+		args:    "CHART_PATH",
+		summary: "Render every Application of the app-of-apps chart into the output directory (default command)",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			versionFlag := fs.BoolP("version", "v", false, "Print version information and exit")
+			cfg := app.Config{}
+			addRenderFlags(fs, &cfg)
+			addCheckFlags(fs, &cfg)
+			changedFilesFlag := addRunFlags(fs, &cfg)
 
-	versionFlag := pflag.BoolP("version", "v", false, "Print version information and exit")
-	cfg := app.Config{}
-	// pflag.StringVar(&cfg.ChartPath, "chart-path", "", "Path to the app-of-apps Helm chart (required)")
-	pflag.StringSliceVarP(&cfg.ValuesFiles, "values", "f", []string{}, "Path to a values file for the app-of-apps chart (can be repeated)")
-	pflag.StringVarP(&cfg.OutputDir, "output-dir", "o", "rendered", "Directory to save rendered manifests")
-	pflag.StringVarP(&cfg.LogLevel, "log-level", "l", "warn", "Log level (debug, info, warn, error)")
+			return func(args []string) {
+				if *versionFlag {
+					printVersion()
+					return
+				}
+				prepareConfig(fs, &cfg, args, 1)
 
-	roar := "roar"
+				if *changedFilesFlag != "" {
+					files, err := readChangedFiles(*changedFilesFlag)
+					if err != nil {
+						logger.Log.Fatalf("Could not read changed files: %v", err)
+					}
+					cfg.ChangedFiles = files
+				}
 
-	pflag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [CHART_PATH] [flags]\n\n", roar)
-		fmt.Fprintf(os.Stderr, "Arguments:\n")
-		fmt.Fprintf(os.Stderr, "  CHART_PATH   Path to the app-of-apps Helm chart (required)\n\n")
-		fmt.Fprintf(os.Stderr, "Flags:\n")
-		pflag.PrintDefaults()
+				if err := app.Run(cfg); err != nil {
+					logger.Log.Fatalf("Application failed: %v", err)
+				}
+			}
+		},
 	}
+}
 
-	pflag.Parse()
+func listCommand() *command {
+	return &command{
+		name:    "list",
+		args:    "CHART_PATH",
+		summary: "Print the selected Applications without cloning or rendering anything",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.Config{List: true}
+			addRenderFlags(fs, &cfg)
 
-	logger.InitLogger()
-	logger.Log.SetLevel(logger.ParseLogLevel(cfg.LogLevel))
-	logger.Log.SetFormatter(&CustomFormatter{})
+			return func(args []string) {
+				prepareConfig(fs, &cfg, args, 1)
+				if err := app.Run(cfg); err != nil {
+					logger.Log.Fatalf("Application failed: %v", err)
+				}
+			}
+		},
+	}
+}
+
+func validateCommand() *command {
+	return &command{
+		name:    "validate",
+		args:    "CHART_PATH",
+		summary: "Check that the Applications of the app-of-apps chart can be parsed and placed in the output layout",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			cfg := app.Config{}
+			addRenderFlags(fs, &cfg)
 
-	if *versionFlag {
-		fmt.Printf("roar version: %s\n", version)
-		return
+			return func(args []string) {
+				prepareConfig(fs, &cfg, args, 1)
+				if err := app.Validate(cfg); err != nil {
+					logger.Log.Fatalf("Validation failed: %v", err)
+				}
+			}
+		},
 	}
+}
+
+func versionCommand() *command {
+	return &command{
+		name:    "version",
+		summary: "Print version information",
+		setup: func(fs *pflag.FlagSet) func([]string) {
+			return func([]string) {
+				printVersion()
+			}
+		},
+	}
+}
 
-	args := pflag.Args()
+func printVersion() {
+	fmt.Printf("roar version: %s\n", version)
+}
 
+// prepareConfig takes the chart path from the single positional argument,
+// merges the configuration file into cfg and sets up logging. It exits with
+// exitCode on errors.
+func prepareConfig(fs *pflag.FlagSet, cfg *app.Config, args []string, exitCode int) {
+	setupLogger(cfg.LogLevel)
 	if len(args) != 1 {
 		logger.Log.Error("Error: exactly one argument [CHART_PATH] is required.")
-		pflag.Usage()
-		os.Exit(1)
+		fs.Usage()
+		os.Exit(exitCode)
 	}
-
 	cfg.ChartPath = args[0]
+	cfg.Version = version
+
+	path, err := applyConfigFile(fs, cfg)
+	if err != nil {
+		logger.Log.Errorf("Could not load config file: %v", err)
+		os.Exit(exitCode)
+	}
+	if err := cfg.CheckKubeVersions(); err != nil {
+		logger.Log.Errorf("Invalid configuration: %v", err)
+		os.Exit(exitCode)
+	}
+	setupLogger(cfg.LogLevel)
+	if path != "" {
+		logger.Log.Infof("Using config file %s", path)
+	}
+}
+
+// addRunFlags registers the flags of the default render command that other
+// commands do not share. It returns the value of --changed-files.
+func addRunFlags(fs *pflag.FlagSet, cfg *app.Config) *string {
+	fs.BoolVar(&cfg.Prune, "prune", false, "Remove files written by previous runs that are no longer generated")
+	fs.BoolVar(&cfg.PruneDryRun, "prune-dry-run", false, "Print the files --prune would remove without removing them")
+	fs.BoolVar(&cfg.List, "list", false, "Print the selected Applications without cloning or rendering anything")
+	fs.StringVar(&cfg.ReportFile, "report", "", "Write a JSON report describing every processed Application to this file")
+	fs.StringVar(&cfg.JUnitFile, "junit", "", "Write a JUnit XML report with one test case per Application to this file")
+	changedFiles := fs.String("changed-files", "", "File with repository-relative paths changed since the previous run, one per line ('-' for stdin); only affected Applications are rendered")
+	fs.StringVar(&cfg.ChangedBase, "changed-base", "", "Git revision of the app-of-apps repository to compute changed files from; only affected Applications are rendered")
+	fs.StringVar(&cfg.ChangedHead, "changed-head", "HEAD", "Git revision compared against --changed-base")
+	return changedFiles
+}
+
+// addRenderFlags registers the flags that control rendering and are shared by
+// all commands that render the app-of-apps chart.
+func addRenderFlags(fs *pflag.FlagSet, cfg *app.Config) {
+	// fs.StringVar(&cfg.ChartPath, "chart-path", "", "Path to the app-of-apps Helm chart (required)")
+	fs.StringSliceVarP(&cfg.ValuesFiles, "values", "f", []string{}, "Path to a values file for the app-of-apps chart (can be repeated)")
+	fs.StringVarP(&cfg.OutputDir, "output-dir", "o", "rendered", "Directory to save rendered manifests")
+	fs.StringVar(&cfg.Layout, "layout", output.DefaultLayout, "Go template for the output file path of each Application, relative to --output-dir")
+	fs.StringVar(&cfg.OutputMode, "output-mode", string(output.ModeFile), "Output mode: 'file' writes one multi-document file per Application, 'split' writes one file per resource")
+	fs.BoolVar(&cfg.Kustomization, "kustomization", false, "Write a kustomization.yaml index into every Application directory (split mode only)")
+	fs.BoolVar(&cfg.SyncOrder, "sync-order", false, "Process Applications and write rendered resources in Argo CD sync order (phase, sync wave, kind, name) and list the sync waves in the output index")
+	fs.BoolVar(&cfg.Normalize, "normalize", false, "Canonicalize rendered YAML (sorted keys, no comments, no empty documents) before writing")
+	fs.StringSliceVar(&cfg.NormalizeOpts.StripLabels, "strip-label", []string{}, "Glob of a label key to remove during normalization (can be repeated)")
+	fs.StringSliceVar(&cfg.NormalizeOpts.StripAnnotations, "strip-annotation", []string{}, "Glob of an annotation key to remove during normalization (can be repeated)")
+	fs.StringVar(&cfg.CacheDir, "cache-dir", "", "Directory of the render cache; Applications whose inputs did not change are not rendered again (disabled when empty)")
+	fs.StringVar(&cfg.SSHKeyFile, "ssh-key-file", "", "Private key for cloning Application repositories over SSH instead of ssh-agent (passphrase in $"+app.SSHKeyPassphraseEnv+")")
+	fs.StringToStringVar(&cfg.RepoRewrites, "repo-rewrite", map[string]string{}, "FROM=TO replacing the prefix FROM of Application repository URLs before cloning; rewritten URLs are cloned as is (can be repeated)")
+	fs.StringVar(&cfg.KubeVersion, "kube-version", "", "Kubernetes version passed to 'helm template --kube-version'")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "Number of Applications cloned and rendered at the same time; reports and the output index keep the order of the Applications")
+	fs.StringSliceVar(&cfg.Applications.APIVersions, "application-api-version", []string{argo.DefaultAPIVersion}, "apiVersion of documents of kind Application in the app-of-apps output (can be repeated)")
+	fs.StringVar(&cfg.Applications.ControlPlaneNamespace, "control-plane-namespace", argo.DefaultControlPlaneNamespace, "Namespace Argo CD runs in; Applications in other namespaces are identified as <namespace>/<name>")
+	fs.BoolVar(&cfg.Lenient, "lenient", false, "Skip documents of the app-of-apps output that cannot be parsed or fail validation instead of aborting; they are reported and fail the run")
+	fs.BoolVar(&cfg.ValidateSchemas, "validate-schemas", false, "Check rendered resources against the bundled upstream OpenAPI schemas of common built-in kinds for --kube-version (unknown fields, types, enums, required fields) and the CRDs in --schema-dir; Applications with violations fail")
+	fs.StringSliceVar(&cfg.SchemaDirs, "schema-dir", []string{}, "Directory with CustomResourceDefinitions or schema bundles to validate against (can be repeated, implies --validate-schemas)")
+	fs.StringSliceVar(&cfg.PolicyDirs, "policy-dir", []string{}, "Directory with CEL policies evaluated on rendered resources; Applications denied by a policy fail (can be repeated)")
+	fs.StringSliceVar(&cfg.Filter.Apps, "app", []string{}, "Only render Applications whose name matches this glob (can be repeated)")
+	fs.StringVar(&cfg.Filter.Selector, "selector", "", "Only render Applications matching this label selector (e.g. 'team=core,tier in (web,api)')")
+	fs.StringSliceVar(&cfg.Filter.Envs, "env", []string{}, "Only render Applications of this env (can be repeated)")
+	fs.StringSliceVar(&cfg.Filter.Instances, "instance", []string{}, "Only render Applications of this instance (can be repeated)")
+	fs.StringSliceVar(&cfg.Filter.Repos, "repo", []string{}, "Only render Applications whose repository URL matches this glob (can be repeated)")
+	fs.StringVarP(&cfg.LogLevel, "log-level", "l", "warn", "Log level (debug, info, warn, error)")
+	addConfigFlag(fs)
+}
+
+// addCheckFlags registers the checks that run on the whole output after all
+// Applications are rendered. Only the render command runs them; commands that
+// render into a scratch directory do not register them.
+func addCheckFlags(fs *pflag.FlagSet, cfg *app.Config) {
+	fs.BoolVar(&cfg.CheckDeprecatedAPIs, "check-deprecated-apis", false, "List rendered resources using Kubernetes APIs deprecated or removed as of --target-kube-version; removed APIs fail the run")
+	fs.StringVar(&cfg.TargetKubeVersion, "target-kube-version", "", "Kubernetes version to check deprecated APIs against (defaults to --kube-version, all known deprecations when empty)")
+	fs.BoolVar(&cfg.CheckDuplicates, "check-duplicates", false, "Report resources rendered by more than one application into the same cluster and namespace; duplicates fail the run")
+}
 
-	if err := app.Run(cfg); err != nil {
-		logger.Log.Fatalf("Application failed: %v", err)
+func addConfigFlag(fs *pflag.FlagSet) {
+	fs.String("config", "", "Path to the configuration file (default: "+config.FileName+" next to CHART_PATH or in the working directory)")
+}
+
+// readChangedFiles reads one path per line from path, or from stdin when path
+// is "-". Blank lines and lines starting with '#' are ignored.
+func readChangedFiles(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			files = append(files, line)
+		}
 	}
+	return files, nil
+}
+
+func setupLogger(level string) {
+	logger.InitLogger()
+	logger.Log.SetLevel(logger.ParseLogLevel(level))
+	logger.Log.SetFormatter(&CustomFormatter{})
 }
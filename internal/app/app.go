@@ -1,95 +1,591 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"roar/internal/pkg/argo"
-	"roar/internal/pkg/git"
+	"roar/internal/pkg/cache"
 	"roar/internal/pkg/helm"
+	"roar/internal/pkg/kubeversion"
 	"roar/internal/pkg/logger"
+	"roar/internal/pkg/manifest"
+	"roar/internal/pkg/output"
+	"roar/internal/pkg/policy"
+	"roar/internal/pkg/report"
+	"roar/internal/pkg/schema"
+	"roar/internal/pkg/syncorder"
+
+	"github.com/sirupsen/logrus"
 )
 
 type Config struct {
-	ChartPath   string
-	ValuesFiles []string
-	OutputDir   string
-	LogLevel    string
-	tempDir_    string
+	ChartPath     string
+	ValuesFiles   []string
+	OutputDir     string
+	Layout        string
+	OutputMode    string
+	Kustomization bool
+	Normalize     bool
+	NormalizeOpts manifest.NormalizeOptions
+	Prune         bool
+	PruneDryRun   bool
+	Filter        argo.Filter
+	List          bool
+	ChangedFiles  []string
+	ChangedBase   string
+	ChangedHead   string
+	CacheDir      string
+	KubeVersion   string
+	// Concurrency is the number of Applications rendered at the same time;
+	// values below 1 render them one at a time.
+	Concurrency int
+	// RepoRewrites replaces prefixes of Application repository URLs before
+	// cloning, e.g. to clone from a mirror. SSHKeyFile is the private key
+	// used for SSH instead of ssh-agent.
+	RepoRewrites map[string]string
+	SSHKeyFile   string
+	// ValidateSchemas checks the rendered resources against the bundled
+	// schemas of built-in resources for KubeVersion and the CRDs in
+	// SchemaDirs; setting SchemaDirs enables it as well.
+	ValidateSchemas bool
+	SchemaDirs      []string
+	// PolicyDirs are the directories with the policies evaluated on the
+	// rendered resources.
+	PolicyDirs []string
+	// CheckDeprecatedAPIs reports rendered resources using Kubernetes APIs
+	// that are deprecated or removed as of TargetKubeVersion, which defaults
+	// to KubeVersion.
+	CheckDeprecatedAPIs bool
+	TargetKubeVersion   string
+	// CheckDuplicates reports resources rendered by more than one
+	// application into the same cluster and namespace.
+	CheckDuplicates bool
+	// Applications selects the Application documents of the app-of-apps
+	// output and how they are identified.
+	Applications argo.ParseOptions
+	// Lenient skips the documents of the app-of-apps output that cannot be
+	// parsed or fail validation instead of aborting the run; they are
+	// reported and fail the run once the other Applications are rendered.
+	Lenient bool
+	// SyncOrder processes the Applications and writes the rendered
+	// resources in the order Argo CD syncs them: by phase, sync wave, kind
+	// and name.
+	SyncOrder  bool
+	ReportFile string
+	JUnitFile  string
+	Version    string
+	LogLevel   string
+	Stdout     io.Writer
+	tempDir_   string
+}
+
+// CheckKubeVersions rejects Kubernetes versions that cannot be parsed. Left
+// unchecked, a typo in TargetKubeVersion would silently report nothing and one
+// in KubeVersion would only fail inside helm.
+func (cfg Config) CheckKubeVersions() error {
+	for _, v := range []struct{ name, version string }{
+		{"--kube-version (render.kubeVersion)", cfg.KubeVersion},
+		{"--target-kube-version (validate.targetKubeVersion)", cfg.TargetKubeVersion},
+	} {
+		if v.version == "" {
+			continue
+		}
+		if err := kubeversion.Check(v.version); err != nil {
+			return fmt.Errorf("%s: %w", v.name, err)
+		}
+	}
+	return nil
 }
 
 type appState struct {
 	tempDir      string
-	outputDir    string
-	clonedRepos  map[string]string
+	output       output.Options
+	normalize    *manifest.NormalizeOptions
+	repositories repositoryAccess
+	// cloneMu guards clonedRepos and cloneCounter, which are shared by the
+	// Applications rendered concurrently.
+	cloneMu      sync.Mutex
+	clonedRepos  map[string]*clonedRepo
 	cloneCounter int
+	cache        *cache.Cache
+	schemas      *schema.Set
+	policies     *policy.Set
+	kubeVersion  string
+	version      string
+}
+
+type clonedRepo struct {
+	path string
+	sha  string
+	// done is closed once the clone finished; err is its error.
+	done chan struct{}
+	err  error
+}
+
+// runResult describes the outcome of a run for callers that post-process the
+// rendered output, such as Diff.
+type runResult struct {
+	apps    []argo.Application
+	failed  map[string]error
+	skipped []string
+	// paths maps every application to its layout path relative to the output
+	// directory, slash-separated.
+	paths  map[string]string
+	report *report.RunReport
 }
 
 func Run(cfg Config) error {
+	result, err := run(cfg)
+	if err == nil {
+		if cfg.CheckDeprecatedAPIs && !cfg.List {
+			writeDeprecatedAPIs(cfg, result.report)
+		}
+		if cfg.CheckDuplicates && !cfg.List {
+			writeDuplicates(cfg, result.report)
+		}
+		err = result.checkFailures()
+	}
+	if cfg.ReportFile == "" && cfg.JUnitFile == "" {
+		return err
+	}
+
+	runReport := report.NewRunReport(cfg.Version, cfg.ChartPath, cfg.ValuesFiles, cfg.OutputDir)
+	if result != nil {
+		runReport = result.report
+	}
+	runReport.Finish(err)
+	if cfg.ReportFile != "" {
+		if writeErr := runReport.WriteJSON(cfg.ReportFile); writeErr != nil {
+			logger.Log.Errorf("Could not write run report: %v", writeErr)
+		}
+	}
+	if cfg.JUnitFile != "" {
+		if writeErr := runReport.WriteJUnit(cfg.JUnitFile); writeErr != nil {
+			logger.Log.Errorf("Could not write JUnit report: %v", writeErr)
+		}
+	}
+	return err
+}
+
+func run(cfg Config) (*runResult, error) {
 	var tempDir string
 	var err error
 
+	if cfg.Stdout == nil {
+		cfg.Stdout = os.Stdout
+	}
+	if err := cfg.CheckKubeVersions(); err != nil {
+		return nil, err
+	}
+
 	if cfg.tempDir_ != "" {
 		tempDir = cfg.tempDir_
 	} else {
 		tempDir, err = os.MkdirTemp("", "argo-charts-*")
 		if err != nil {
-			return fmt.Errorf("failed to create temp directory: %w", err)
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
 		}
 		defer os.RemoveAll(tempDir)
 	}
 
 	logger.Log.Infof("Using temporary directory for clones: %s", tempDir)
+	runReport := report.NewRunReport(cfg.Version, cfg.ChartPath, cfg.ValuesFiles, cfg.OutputDir)
+
+	layout, err := output.NewLayout(cfg.OutputDir, cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	outputMode, err := output.ParseMode(cfg.OutputMode)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := cfg.Filter.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	applications, problems, err := renderAndParseAppOfApps(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("initialization failed: %w", err)
+	}
+	invalid, _ := argo.SplitWarnings(problems)
+	for _, problem := range invalid {
+		runReport.InvalidDocuments = append(runReport.InvalidDocuments, report.InvalidDocument{
+			Document:    problem.Document,
+			Source:      problem.Source,
+			Line:        problem.Line,
+			Column:      problem.Column,
+			Application: problem.Application,
+			Field:       problem.Field,
+			Message:     problem.Message,
+		})
+	}
+
+	if cfg.SyncOrder {
+		syncorder.Applications(applications)
+	}
+
+	if cfg.List {
+		return &runResult{apps: applications, report: runReport}, listApplications(cfg.Stdout, applications, matcher)
+	}
+
+	outputFiles, err := layout.Resolve(applications, outputMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve output layout: %w", err)
+	}
 
 	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %w", cfg.OutputDir, err)
+		return nil, fmt.Errorf("failed to create output directory %s: %w", cfg.OutputDir, err)
 	}
 
-	applications, err := renderAndParseAppOfApps(cfg.ChartPath, cfg.ValuesFiles)
+	previousIndex, err := output.ReadIndex(cfg.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	repositories, err := newRepositoryAccess(cfg)
+	if err != nil {
+		return nil, err
+	}
+	currentIndex := output.NewIndex()
+	currentIndex.Settings = settingsFingerprint(cfg)
+	if cfg.SyncOrder {
+		currentIndex.SyncWaves = syncWaves(applications)
+	}
+	inc, err := newIncremental(cfg, previousIndex, currentIndex.Settings, repositories)
 	if err != nil {
-		return fmt.Errorf("initialization failed: %w", err)
+		return nil, fmt.Errorf("failed to determine changed files: %w", err)
+	}
+	result := &runResult{
+		apps:   applications,
+		failed: make(map[string]error),
+		paths:  make(map[string]string),
+		report: runReport,
 	}
 
 	state := &appState{
-		tempDir:     tempDir,
-		outputDir:   cfg.OutputDir,
-		clonedRepos: make(map[string]string),
+		tempDir:      tempDir,
+		repositories: repositories,
+		output:       output.Options{Mode: outputMode, Kustomization: cfg.Kustomization, SyncOrder: cfg.SyncOrder},
+		clonedRepos:  make(map[string]*clonedRepo),
+		kubeVersion:  cfg.KubeVersion,
+		version:      cfg.Version,
 	}
-
-	for _, app := range applications {
-		err := processApplication(app, state)
+	if cfg.Normalize {
+		state.normalize = &cfg.NormalizeOpts
+	}
+	if cfg.CacheDir != "" {
+		state.cache, err = cache.New(cfg.CacheDir)
 		if err != nil {
-			logger.Log.WithField("application", app.Name).Errorf("Could not process application: %v. Skipping.", err)
+			return nil, err
+		}
+	}
+	if cfg.ValidateSchemas || len(cfg.SchemaDirs) > 0 {
+		if state.schemas, err = loadSchemas(cfg.SchemaDirs, cfg.KubeVersion); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.PolicyDirs) > 0 {
+		if state.policies, err = loadPolicies(cfg.PolicyDirs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Applications that have to be rendered are rendered by up to
+	// cfg.Concurrency workers once the others are handled; the report and the
+	// index are then updated in the order of the Applications, so that the
+	// results do not depend on the concurrency.
+	appReports := make([]report.ApplicationReport, len(applications))
+	definitions := make([]string, len(applications))
+	var pending []int
+	for i, app := range applications {
+		// Applications are identified by their qualified name everywhere,
+		// so that same-named Applications in different namespaces do not
+		// share index entries.
+		name := app.QualifiedName()
+		if rel, err := filepath.Rel(cfg.OutputDir, outputFiles[i]); err == nil {
+			result.paths[name] = filepath.ToSlash(rel)
+		}
+		appReport := report.ApplicationReport{Name: name, Namespace: app.Namespace, Env: app.Env, Instance: app.Instance}
+		if !matcher.Matches(app) {
+			logger.Log.WithField("application", name).Debug("Skipping application: filtered out.")
+			appReport.Skipped = true
+			appReports[i] = appReport
+			currentIndex.Carry(previousIndex, name)
+			result.skipped = append(result.skipped, name)
+			continue
+		}
+		definition := fingerprint(app)
+		if render, reason := inc.needsRender(app, definition); !render {
+			logCtx := logger.Log.WithField("application", name)
+			logCtx.Info("No relevant changes, reusing previous output.")
+			appReport.Reused = true
+			for _, file := range previousIndex.Applications[name] {
+				appReport.OutputFiles = append(appReport.OutputFiles, filepath.Join(cfg.OutputDir, filepath.FromSlash(file)))
+			}
+			currentIndex.Carry(previousIndex, name)
+			if state.checksResources() {
+				if err := checkReusedOutput(app, cfg.OutputDir, previousIndex.Applications[name], state, &appReport, logCtx); err != nil {
+					logCtx.Errorf("Previous output does not pass the checks: %v", err)
+					appReport.Error = err.Error()
+					delete(currentIndex.Definitions, name)
+					result.failed[name] = err
+				}
+			}
+			appReports[i] = appReport
+			continue
+		} else if reason != "" {
+			logger.Log.WithField("application", name).Infof("Rendering: %s.", reason)
+		}
+		appReports[i] = appReport
+		definitions[i] = definition
+		pending = append(pending, i)
+	}
+
+	errs := renderApplications(applications, outputFiles, pending, appReports, state, cfg.Concurrency)
+	for _, i := range pending {
+		name := applications[i].QualifiedName()
+		if err := errs[i]; err != nil {
+			logger.Log.WithField("application", name).Errorf("Could not process application: %v. Skipping.", err)
+			currentIndex.Carry(previousIndex, name)
+			delete(currentIndex.Definitions, name)
+			result.failed[name] = err
+			continue
+		}
+		currentIndex.Definitions[name] = definitions[i]
+		currentIndex.Revisions[name] = appReports[i].CommitSHA
+		if err := currentIndex.Add(cfg.OutputDir, name, appReports[i].OutputFiles); err != nil {
+			return nil, err
 		}
 	}
+	runReport.Applications = append(runReport.Applications, appReports...)
+
+	// Applications whose documents were skipped count as failed: their
+	// previous output is kept and diffs do not show them as removed.
+	for _, name := range skippedApplications(applications, invalid) {
+		currentIndex.Carry(previousIndex, name)
+		delete(currentIndex.Definitions, name)
+		result.failed[name] = errors.New("the Application document is invalid and was skipped")
+	}
+
+	if cfg.CheckDeprecatedAPIs {
+		target := cfg.TargetKubeVersion
+		if target == "" {
+			target = cfg.KubeVersion
+		}
+		if err := findDeprecatedAPIs(applications, cfg.OutputDir, currentIndex, runReport, target); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CheckDuplicates {
+		if err := findDuplicates(applications, cfg.OutputDir, currentIndex, runReport); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := pruneStaleFiles(cfg, previousIndex, currentIndex); err != nil {
+		return nil, err
+	}
 
 	logger.Log.Info("All done!")
-	return nil
+	return result, nil
 }
 
-func renderAndParseAppOfApps(chartPath string, valuesFiles []string) ([]argo.Application, error) {
+// renderAndParseAppOfApps renders the app-of-apps chart and parses its
+// Applications. In lenient mode invalid documents are skipped and returned as
+// problems instead of failing. Warnings are logged and returned along with
+// the problems in both modes.
+func renderAndParseAppOfApps(cfg Config) ([]argo.Application, []argo.Problem, error) {
+	appOfAppsManifests, err := renderAppOfApps(cfg.ChartPath, cfg.ValuesFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Lenient {
+		logger.Log.Info("Parsing for Argo CD applications, skipping invalid documents...")
+		applications, problems := argo.ParseApplicationsLenient(appOfAppsManifests, cfg.Applications)
+		for _, problem := range problems {
+			if problem.Warning {
+				logger.Log.Warnf("Application manifest %s", problem)
+			} else {
+				logger.Log.Errorf("Skipping invalid Application document: %s", problem)
+			}
+		}
+		logger.Log.Infof("Found %d applications to process.", len(applications))
+		return applications, problems, nil
+	}
+
+	logger.Log.Info("Validating Argo CD applications...")
+	warnings, err := validateAppOfApps(appOfAppsManifests, cfg.Applications)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Log.Info("Parsing for Argo CD applications...")
+	applications, err := argo.ParseApplications(appOfAppsManifests, cfg.Applications)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse Argo applications: %w", err)
+	}
+
+	logger.Log.Infof("Found %d applications to process.", len(applications))
+	return applications, warnings, nil
+}
+
+// skippedApplications returns the names of the Applications whose documents
+// were skipped in lenient mode and that were not parsed from another
+// document.
+func skippedApplications(applications []argo.Application, invalid []argo.Problem) []string {
+	parsed := make(map[string]bool)
+	for _, app := range applications {
+		parsed[app.QualifiedName()] = true
+	}
+	var names []string
+	for _, problem := range invalid {
+		if problem.Application != "" && !parsed[problem.Application] {
+			parsed[problem.Application] = true
+			names = append(names, problem.Application)
+		}
+	}
+	return names
+}
+
+// syncWaves groups Applications, already sorted in sync order, by their sync
+// wave.
+func syncWaves(applications []argo.Application) []output.SyncWave {
+	var waves []output.SyncWave
+	for _, app := range applications {
+		wave := syncorder.Wave(app.Annotations)
+		if len(waves) == 0 || waves[len(waves)-1].Wave != wave {
+			waves = append(waves, output.SyncWave{Wave: wave})
+		}
+		last := &waves[len(waves)-1]
+		last.Applications = append(last.Applications, app.QualifiedName())
+	}
+	return waves
+}
+
+// validateAppOfApps checks the Application manifests before anything is
+// cloned, so that all problems are reported at once instead of failing
+// individual applications later. Warnings are logged and returned, they do
+// not fail the validation.
+func validateAppOfApps(manifests []byte, opts argo.ParseOptions) ([]argo.Problem, error) {
+	problems, err := argo.ValidateApplications(manifests, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Argo applications: %w", err)
+	}
+	invalid, warnings := argo.SplitWarnings(problems)
+	for _, warning := range warnings {
+		logger.Log.Warnf("Application manifest %s", warning)
+	}
+	if len(invalid) > 0 {
+		return warnings, &argo.ValidationError{Problems: invalid}
+	}
+	return warnings, nil
+}
+
+func renderAppOfApps(chartPath string, valuesFiles []string) ([]byte, error) {
 	logger.Log.Info("Rendering the main 'app-of-apps' chart...")
 	appOfAppsOpts := helm.RenderOptions{ReleaseName: "app-of-apps", ChartPath: chartPath, ValuesFiles: valuesFiles}
 	appOfAppsManifests, err := helm.Template(appOfAppsOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render app-of-apps chart: %w", err)
 	}
+	return appOfAppsManifests, nil
+}
 
-	logger.Log.Info("Parsing for Argo CD applications...")
-	applications, err := argo.ParseApplications(appOfAppsManifests)
+// processApplication renders one application and records what it did in
+// appReport, including the error if it fails.
+func processApplication(app argo.Application, outputFile string, state *appState, appReport *report.ApplicationReport) error {
+	err := renderApplication(app, outputFile, state, appReport)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Argo applications: %w", err)
+		appReport.Error = err.Error()
+		var helmErr *helm.Error
+		if errors.As(err, &helmErr) {
+			appReport.Stderr = helmErr.Stderr
+		}
 	}
+	return err
+}
 
-	logger.Log.Infof("Found %d applications to process.", len(applications))
-	return applications, nil
+// cloneRepository clones cloneURL at revision once per run. Applications
+// rendered concurrently from the same repository and revision wait for that
+// clone; after a failed clone, later Applications try again.
+func (s *appState) cloneRepository(cloneURL, revision string, appReport *report.ApplicationReport, logCtx *logrus.Entry) (*clonedRepo, error) {
+	key := fmt.Sprintf("%s@%s", cloneURL, revision)
+	s.cloneMu.Lock()
+	repo, isCached := s.clonedRepos[key]
+	if !isCached {
+		s.cloneCounter++
+		repo = &clonedRepo{
+			path: filepath.Join(s.tempDir, fmt.Sprintf("clone-%d", s.cloneCounter)),
+			done: make(chan struct{}),
+		}
+		s.clonedRepos[key] = repo
+	}
+	s.cloneMu.Unlock()
+
+	if isCached {
+		<-repo.done
+		if repo.err != nil {
+			return nil, repo.err
+		}
+		logCtx.Infof("Using cached repository from path: %s", repo.path)
+		appReport.CloneCached = true
+		return repo, nil
+	}
+
+	logCtx.Infof("Cloning %s to %s", key, repo.path)
+	cloneStart := time.Now()
+	repo.sha, repo.err = s.repositories.clone(cloneURL, revision, repo.path)
+	appReport.CloneDurationMs = time.Since(cloneStart).Milliseconds()
+	if repo.err != nil {
+		s.cloneMu.Lock()
+		delete(s.clonedRepos, key)
+		s.cloneMu.Unlock()
+	}
+	close(repo.done)
+	if repo.err != nil {
+		return nil, repo.err
+	}
+	return repo, nil
 }
 
-func processApplication(app argo.Application, state *appState) error {
-	logCtx := logger.Log.WithField("application", app.Name)
+// renderApplications processes the Applications at the given indices with up
+// to concurrency workers and returns their errors, indexed like applications.
+// The report of every Application is written to the same index of reports.
+func renderApplications(applications []argo.Application, outputFiles []string, indices []int, reports []report.ApplicationReport, state *appState, concurrency int) []error {
+	errs := make([]error, len(applications))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(indices); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				errs[i] = processApplication(applications[i], outputFiles[i], state, &reports[i])
+			}
+		}()
+	}
+	for _, i := range indices {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return errs
+}
+
+func renderApplication(app argo.Application, outputFile string, state *appState, appReport *report.ApplicationReport) error {
+	logCtx := logger.Log.WithField("application", app.QualifiedName())
 	logCtx.Info("Processing application...")
 
 	werfSetValues := app.Setters
@@ -107,61 +603,94 @@ func processApplication(app argo.Application, state *appState) error {
 		werfSetValues["global.env"] = app.Env
 		logCtx.Infof("Resolved final 'env' to '%s'", app.Env)
 	}
+	appReport.Setters = werfSetValues
+	appReport.Revision = app.TargetRevision
+	appReport.RepoURL = app.RepoURL
 
-	sshURL, err := convertHTTPtoSSH(app.RepoURL)
+	cloneURL, err := state.repositories.cloneURL(app.RepoURL)
 	if err != nil {
 		return fmt.Errorf("invalid repo URL '%s': %w", app.RepoURL, err)
 	}
+	appReport.RepoURL = cloneURL
 
-	cacheKey := fmt.Sprintf("%s@%s", sshURL, app.TargetRevision)
-	repoPath, isCached := state.clonedRepos[cacheKey]
-	if !isCached {
-		state.cloneCounter++
-		repoPath = filepath.Join(state.tempDir, fmt.Sprintf("clone-%d", state.cloneCounter))
-		logCtx.Infof("Cloning %s to %s", cacheKey, repoPath)
-		err = git.Clone(sshURL, app.TargetRevision, repoPath)
-		if err != nil {
-			return fmt.Errorf("failed to clone repo: %w", err)
-		}
-		state.clonedRepos[cacheKey] = repoPath
-	} else {
-		logCtx.Infof("Using cached repository from path: %s", repoPath)
+	repo, err := state.cloneRepository(cloneURL, app.TargetRevision, appReport, logCtx)
+	if err != nil {
+		return fmt.Errorf("failed to clone repo: %w", err)
 	}
+	appReport.CommitSHA = repo.sha
 
-	appServicePath := filepath.Join(repoPath, app.Path)
+	appServicePath := filepath.Join(repo.path, app.Path)
 	appChartPath := filepath.Join(appServicePath, ".helm")
 	absoluteValuesFiles := make([]string, len(app.ValuesFiles))
 	for i, file := range app.ValuesFiles {
 		absoluteValuesFiles[i] = filepath.Join(appServicePath, file)
 	}
+	appReport.ChartPath = appChartPath
+	appReport.ValuesFiles = absoluteValuesFiles
 
-	appOpts := helm.RenderOptions{ReleaseName: app.Name, ChartPath: appChartPath, ValuesFiles: absoluteValuesFiles, SetValues: werfSetValues}
-	renderedApp, err := helm.Template(appOpts)
+	appOpts := helm.RenderOptions{
+		ReleaseName: app.Name,
+		ChartPath:   appChartPath,
+		ValuesFiles: absoluteValuesFiles,
+		SetValues:   werfSetValues,
+		KubeVersion: state.kubeVersion,
+	}
+	renderStart := time.Now()
+	renderedApp, err := renderChart(app, *repo, appOpts, state, appReport)
+	appReport.RenderDurationMs = time.Since(renderStart).Milliseconds()
 	if err != nil {
 		return fmt.Errorf("failed to render chart: %w", err)
 	}
+	if state.checksResources() {
+		resources, err := manifest.Parse(renderedApp)
+		if err != nil {
+			return fmt.Errorf("failed to parse rendered manifests: %w", err)
+		}
+		if err := checkResources(app, resources, state, appReport, logCtx); err != nil {
+			return err
+		}
+	}
 
-	finalOutputDir := state.outputDir
-	if app.Env != "" {
-		finalOutputDir = filepath.Join(finalOutputDir, app.Env)
-		logCtx.Infof("Using resolved 'env': '%s' for output directory.", app.Env)
+	// Sorting goes before normalization, which may strip the annotations
+	// that define the order.
+	if state.output.SyncOrder {
+		renderedApp, err = syncorder.Sort(renderedApp)
+		if err != nil {
+			return fmt.Errorf("failed to sort rendered manifests in sync order: %w", err)
+		}
 	}
-	if app.Instance != "" {
-		finalOutputDir = filepath.Join(finalOutputDir, app.Instance)
-		logCtx.Infof("Using resolved 'instance': '%s' for output directory.", app.Instance)
+	if state.normalize != nil {
+		renderedApp, err = manifest.Normalize(renderedApp, *state.normalize)
+		if err != nil {
+			return fmt.Errorf("failed to normalize rendered manifests: %w", err)
+		}
 	}
+	appReport.Resources = countResources(renderedApp, logCtx)
 
-	if err := os.MkdirAll(finalOutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output subdirectory %s: %w", finalOutputDir, err)
+	writtenFiles, err := output.Write(outputFile, renderedApp, state.output)
+	if err != nil {
+		return err
+	}
+	appReport.OutputFiles = writtenFiles
+	if state.output.Mode == output.ModeSplit {
+		logCtx.Infof("Successfully rendered and saved %d files to %s", len(writtenFiles), output.SplitDir(outputFile))
+	} else {
+		logCtx.Infof("Successfully rendered and saved manifest to %s", outputFile)
 	}
+	return nil
+}
 
-	outputFile := filepath.Join(finalOutputDir, fmt.Sprintf("%s.yaml", app.Name))
-	err = os.WriteFile(outputFile, renderedApp, 0644)
+func countResources(rendered []byte, logCtx *logrus.Entry) map[string]int {
+	counts := make(map[string]int)
+	resources, err := manifest.Parse(rendered)
 	if err != nil {
-		return fmt.Errorf("failed to write manifest to %s: %w", outputFile, err)
+		logCtx.Warnf("Could not count rendered resources: %v", err)
+		return counts
 	}
-	logCtx.Infof("Successfully rendered and saved manifest to %s", outputFile)
-	return nil
+	for _, r := range resources {
+		counts[r.Kind]++
+	}
+	return counts
 }
 
 func convertHTTPtoSSH(httpURL string) (string, error) {
@@ -4,12 +4,22 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/diff"
+	"roar/internal/pkg/images"
+	"roar/internal/pkg/output"
+	"roar/internal/pkg/report"
+
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 
 	"github.com/stretchr/testify/require"
@@ -32,6 +42,9 @@ if [ "$RELEASE_NAME" == "app-of-apps" ]; then
     if [ -d "${CHART_PATH}/templates" ]; then
         cat "${CHART_PATH}"/templates/*.yaml
     fi
+elif [ -d "${CHART_PATH}/templates" ]; then
+    # Дочернее приложение с шаблонами выводит их как есть
+    cat "${CHART_PATH}"/templates/*.yaml
 else
     # Иначе, это дочернее приложение. Выводим фейковый YAML.
     echo "kind: FakedHelmOutputForApp"
@@ -87,6 +100,45 @@ func createFakeGitRepo(t *testing.T) string {
 	return repoPath
 }
 
+// createAppsWithTemplates добавляет в репозиторий сервисов чарт с шаблонами для
+// каждого приложения и создает app-of-apps чарт, который на них ссылается.
+func createAppsWithTemplates(t *testing.T, testRootDir, fakeRepoPath string, templates map[string]string, annotations map[string]string) string {
+	r, err := git.PlainOpen(fakeRepoPath)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	var appOfAppsTemplate string
+	for name, content := range templates {
+		dir := filepath.Join(fakeRepoPath, "stable", name, ".helm", "templates")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "all.yaml"), []byte(content), 0644))
+		appOfAppsTemplate += fmt.Sprintf(`---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %s
+  annotations:
+    rawRepository: "%s"
+    rawPath: "stable/%s"
+%s
+spec:
+  source:
+    targetRevision: master
+`, name, fakeRepoPath, name, annotations[name])
+	}
+	_, err = w.Add(".")
+	require.NoError(t, err)
+	_, err = w.Commit("Add templates", &git.CommitOptions{Author: &object.Signature{Name: "Test Author", Email: "test@example.com"}})
+	require.NoError(t, err)
+
+	appOfAppsDir := filepath.Join(testRootDir, "app-of-apps-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "apps.yaml"), []byte(appOfAppsTemplate), 0644))
+	return appOfAppsDir
+}
+
 func TestAppRun_Integration(t *testing.T) {
 	cmdLogPath, cleanup := setupIntegrationTest(t)
 	defer cleanup()
@@ -120,10 +172,12 @@ spec:
 `, fakeRepoPath)
 	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "app.yaml"), []byte(appOfAppsTemplate), 0644))
 
+	reportFile := filepath.Join(testRootDir, "report.json")
 	cfg := Config{
-		ChartPath: appOfAppsDir,
-		OutputDir: outputDir,
-		tempDir_:  clonesDir,
+		ChartPath:  appOfAppsDir,
+		OutputDir:  outputDir,
+		ReportFile: reportFile,
+		tempDir_:   clonesDir,
 	}
 	err := Run(cfg)
 	require.NoError(t, err)
@@ -145,4 +199,1380 @@ spec:
 	require.Contains(t, cmdLog, "helm template dev-inf1-my-service")
 	require.Contains(t, cmdLog, "--set global.replicaCount=3")
 	require.Contains(t, cmdLog, filepath.Join(clonesDir, "clone-1", "stable", "my-service", ".helm"))
+
+	// Проверяем JSON-отчет о запуске
+	reportContent, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(reportContent, &runReport))
+	require.Equal(t, report.Summary{Total: 1, Succeeded: 1}, runReport.Summary)
+	require.Len(t, runReport.Applications, 1)
+	appReport := runReport.Applications[0]
+	require.Equal(t, "dev-inf1-my-service", appReport.Name)
+	require.Equal(t, "master", appReport.Revision)
+	require.Len(t, appReport.CommitSHA, 40)
+	require.Equal(t, "3", appReport.Setters["global.replicaCount"])
+	require.Equal(t, "inf1", appReport.Setters["global.instance"])
+	require.Equal(t, []string{expectedOutputFile}, appReport.OutputFiles)
+	require.Equal(t, map[string]int{"FakedHelmOutputForApp": 1}, appReport.Resources)
+	require.Empty(t, appReport.Error)
+}
+
+func TestAppRun_Prune_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	appOfAppsDir := filepath.Join(testRootDir, "app-of-apps-chart")
+	fakeRepoPath := createFakeGitRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+
+	writeApp := func(name string) {
+		template := fmt.Sprintf(`
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %s
+  labels:
+    env: dev
+  annotations:
+    rawRepository: "%s"
+    rawPath: "stable/my-service"
+spec:
+  source:
+    targetRevision: master
+`, name, fakeRepoPath)
+		require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "app.yaml"), []byte(template), 0644))
+	}
+
+	// Первый запуск: рендерим старое приложение
+	writeApp("old-service")
+	require.NoError(t, Run(Config{ChartPath: appOfAppsDir, OutputDir: outputDir, tempDir_: t.TempDir()}))
+	oldFile := filepath.Join(outputDir, "dev", "old-service.yaml")
+	require.FileExists(t, oldFile)
+
+	// Приложение переименовано: без --prune старый файл остается
+	writeApp("new-service")
+	require.NoError(t, Run(Config{ChartPath: appOfAppsDir, OutputDir: outputDir, tempDir_: t.TempDir()}))
+	require.FileExists(t, oldFile)
+
+	// В режиме dry-run файл только попадает в превью
+	var preview bytes.Buffer
+	require.NoError(t, Run(Config{ChartPath: appOfAppsDir, OutputDir: outputDir, PruneDryRun: true, Stdout: &preview, tempDir_: t.TempDir()}))
+	require.FileExists(t, oldFile)
+	require.Contains(t, preview.String(), "would prune dev/old-service.yaml (application old-service)")
+
+	// С --prune старый файл удаляется, новый остается
+	require.NoError(t, Run(Config{ChartPath: appOfAppsDir, OutputDir: outputDir, Prune: true, tempDir_: t.TempDir()}))
+	require.NoFileExists(t, oldFile)
+	require.FileExists(t, filepath.Join(outputDir, "dev", "new-service.yaml"))
+}
+
+func TestAppDiff_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	appOfAppsDir := filepath.Join(testRootDir, "app-of-apps-chart")
+	fakeRepoPath := createFakeGitRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+
+	writeApp := func(name string) {
+		template := fmt.Sprintf(`
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %s
+  labels:
+    env: dev
+  annotations:
+    rawRepository: "%s"
+    rawPath: "stable/my-service"
+spec:
+  source:
+    targetRevision: master
+`, name, fakeRepoPath)
+		require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "app.yaml"), []byte(template), 0644))
+	}
+
+	writeApp("my-service")
+	require.NoError(t, Run(Config{ChartPath: appOfAppsDir, OutputDir: outputDir, tempDir_: t.TempDir()}))
+
+	// Повторный рендеринг того же чарта не дает изменений
+	changes, err := Diff(DiffConfig{Render: Config{ChartPath: appOfAppsDir}, AgainstDir: outputDir})
+	require.NoError(t, err)
+	require.False(t, changes.HasChanges())
+
+	// Переименованное приложение видно как удаленное и добавленное
+	writeApp("renamed-service")
+	changes, err = Diff(DiffConfig{Render: Config{ChartPath: appOfAppsDir}, AgainstDir: outputDir})
+	require.NoError(t, err)
+	require.True(t, changes.HasChanges())
+	require.Equal(t, 1, changes.Count(diff.StatusAdded))
+	require.Equal(t, 1, changes.Count(diff.StatusRemoved))
+}
+
+func TestAppCompare_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	fakeRepoPath := createFakeGitRepo(t)
+
+	// GitOps-репозиторий с app-of-apps чартом в поддиректории
+	gitopsRepo := t.TempDir()
+	r, err := git.PlainInit(gitopsRepo, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	chartDir := filepath.Join(gitopsRepo, "charts", "app-of-apps")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+
+	appTemplate := func(name, tag string) string {
+		return fmt.Sprintf(`---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %s
+  labels:
+    env: dev
+  annotations:
+    rawRepository: "%s"
+    rawPath: "stable/my-service"
+spec:
+  source:
+    targetRevision: master
+    plugin:
+      env:
+        - name: WERF_SET_IMAGE_TAG
+          value: "global.image.tag=%s"
+`, name, fakeRepoPath, tag)
+	}
+	commit := func(content, message string) {
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "apps.yaml"), []byte(content), 0644))
+		_, err := w.Add(".")
+		require.NoError(t, err)
+		_, err = w.Commit(message, &git.CommitOptions{Author: &object.Signature{Name: "Test Author", Email: "test@example.com"}})
+		require.NoError(t, err)
+	}
+
+	broken := strings.Replace(appTemplate("fixed-service", "1.0"), fakeRepoPath, filepath.Join(t.TempDir(), "missing"), 1)
+	commit(appTemplate("my-service", "1.0")+appTemplate("old-service", "1.0")+broken, "base")
+	baseRef, err := r.Head()
+	require.NoError(t, err)
+	require.NoError(t, r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("base"), baseRef.Hash())))
+	commit(appTemplate("my-service", "1.1")+appTemplate("new-service", "1.0")+appTemplate("fixed-service", "1.0"), "head")
+
+	changes, err := Compare(CompareConfig{Render: Config{ChartPath: chartDir}, Base: "base", Head: "HEAD"})
+	require.NoError(t, err)
+	require.True(t, changes.HasChanges())
+
+	statuses := map[string]diff.Status{}
+	for _, app := range changes.Apps {
+		statuses[app.Name] = app.Status
+	}
+	require.Equal(t, map[string]diff.Status{
+		"fixed-service": diff.StatusAdded,
+		"my-service":    diff.StatusModified,
+		"new-service":   diff.StatusAdded,
+		"old-service":   diff.StatusRemoved,
+	}, statuses)
+	require.Zero(t, changes.Count(diff.StatusFailed))
+
+	for _, app := range changes.Apps {
+		switch app.Name {
+		case "my-service":
+			require.Equal(t, []diff.ParamChange{{Name: "set global.image.tag", Base: "1.0", Head: "1.1"}}, app.Params)
+		case "fixed-service":
+			require.NotEmpty(t, app.BaseError)
+		default:
+			require.Empty(t, app.BaseError)
+		}
+	}
+}
+
+func TestAppRun_Filter_Integration(t *testing.T) {
+	cmdLogPath, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	appOfAppsDir := filepath.Join(testRootDir, "app-of-apps-chart")
+	fakeRepoPath := createFakeGitRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+
+	var templates string
+	for _, app := range []struct{ name, env, team string }{
+		{"dev-frontend", "dev", "web"},
+		{"dev-backend", "dev", "core"},
+		{"prod-backend", "prod", "core"},
+	} {
+		templates += fmt.Sprintf(`---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %s
+  labels:
+    env: %s
+    team: %s
+  annotations:
+    rawRepository: "%s"
+    rawPath: "stable/my-service"
+spec:
+  source:
+    targetRevision: master
+`, app.name, app.env, app.team, fakeRepoPath)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "apps.yaml"), []byte(templates), 0644))
+
+	// --list выводит выбранные приложения и ничего не клонирует и не рендерит
+	var listOutput bytes.Buffer
+	err := Run(Config{
+		ChartPath: appOfAppsDir,
+		OutputDir: outputDir,
+		Filter:    argo.Filter{Selector: "team=core"},
+		List:      true,
+		Stdout:    &listOutput,
+	})
+	require.NoError(t, err)
+	require.Contains(t, listOutput.String(), "NAME")
+	require.Contains(t, listOutput.String(), "dev-backend")
+	require.Contains(t, listOutput.String(), "prod-backend")
+	require.NotContains(t, listOutput.String(), "dev-frontend")
+	require.NoDirExists(t, outputDir)
+	cmdLog, err := os.ReadFile(cmdLogPath)
+	require.NoError(t, err)
+	require.NotContains(t, string(cmdLog), "helm template dev-backend")
+
+	// Рендерятся только отфильтрованные приложения, остальные пропускаются
+	junitFile := filepath.Join(testRootDir, "junit.xml")
+	err = Run(Config{
+		ChartPath: appOfAppsDir,
+		OutputDir: outputDir,
+		Filter:    argo.Filter{Envs: []string{"dev"}, Apps: []string{"*-backend"}},
+		JUnitFile: junitFile,
+		tempDir_:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(outputDir, "dev", "dev-backend.yaml"))
+	require.NoFileExists(t, filepath.Join(outputDir, "dev", "dev-frontend.yaml"))
+	require.NoFileExists(t, filepath.Join(outputDir, "prod", "prod-backend.yaml"))
+
+	junit, err := os.ReadFile(junitFile)
+	require.NoError(t, err)
+	require.Contains(t, string(junit), `tests="3" failures="0" skipped="2"`)
+}
+
+func TestAppRun_Incremental_Integration(t *testing.T) {
+	cmdLogPath, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+	// app-of-apps чарт лежит в том же репозитории, что и сервисы frontend и
+	// backend, а сервис external — в отдельном репозитории
+	appOfAppsDir := filepath.Join(fakeRepoPath, "app-of-apps-chart")
+	otherRepoPath := createFakeGitRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+
+	writeApps := func(replicas string) {
+		var templates string
+		for _, app := range []struct{ name, repo, path string }{
+			{"frontend", fakeRepoPath, "stable/frontend"},
+			{"backend", fakeRepoPath, "stable/backend"},
+			{"external", otherRepoPath, "stable/my-service"},
+		} {
+			templates += fmt.Sprintf(`---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %s
+  annotations:
+    rawRepository: "%s"
+    rawPath: "%s"
+spec:
+  source:
+    targetRevision: master
+    plugin:
+      env:
+        - name: WERF_SET_REPLICAS
+          value: "replicas=%s"
+`, app.name, app.repo, app.path, replicas)
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "apps.yaml"), []byte(templates), 0644))
+	}
+	renderCount := func(name string) int {
+		cmdLog, err := os.ReadFile(cmdLogPath)
+		require.NoError(t, err)
+		return bytes.Count(cmdLog, []byte("helm template "+name+" "))
+	}
+
+	// Первый запуск рендерит всё, так как предыдущего индекса нет
+	writeApps("1")
+	reportFile := filepath.Join(testRootDir, "report.json")
+	cfg := Config{
+		ChartPath: appOfAppsDir,
+		OutputDir: outputDir,
+		// Путь stable/my-service относится к репозиторию app-of-apps и не
+		// затрагивает сервис external из другого репозитория
+		ChangedFiles: []string{"stable/frontend/.helm/values.yaml", "stable/my-service/.helm/Chart.yaml"},
+		ReportFile:   reportFile,
+		tempDir_:     t.TempDir(),
+	}
+	require.NoError(t, Run(cfg))
+	require.Equal(t, 1, renderCount("frontend"))
+	require.Equal(t, 1, renderCount("backend"))
+	require.Equal(t, 1, renderCount("external"))
+
+	// Повторно рендерится только приложение, чья директория изменилась
+	cfg.tempDir_ = t.TempDir()
+	require.NoError(t, Run(cfg))
+	require.Equal(t, 2, renderCount("frontend"))
+	require.Equal(t, 1, renderCount("backend"))
+	require.Equal(t, 1, renderCount("external"))
+	require.FileExists(t, filepath.Join(outputDir, "backend.yaml"))
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 2, runReport.Summary.Reused)
+	require.Equal(t, 3, runReport.Summary.Succeeded)
+
+	// Новый коммит в ветке другого репозитория перерисовывает его приложение
+	r, err := git.PlainOpen(otherRepoPath)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(otherRepoPath, "stable", "my-service", ".helm", "values.yaml"), []byte("replicas: 3\n"), 0644))
+	_, err = w.Add(".")
+	require.NoError(t, err)
+	_, err = w.Commit("Change values", &git.CommitOptions{Author: &object.Signature{Name: "Test Author", Email: "test@example.com"}})
+	require.NoError(t, err)
+	cfg.tempDir_ = t.TempDir()
+	cfg.ChangedFiles = []string{"README.md"}
+	require.NoError(t, Run(cfg))
+	require.Equal(t, 2, renderCount("frontend"))
+	require.Equal(t, 1, renderCount("backend"))
+	require.Equal(t, 2, renderCount("external"))
+
+	// Изменение Application в app-of-apps затрагивает все приложения
+	writeApps("2")
+	cfg.tempDir_ = t.TempDir()
+	cfg.ChangedFiles = []string{"app-of-apps-chart/templates/apps.yaml"}
+	require.NoError(t, Run(cfg))
+	require.Equal(t, 3, renderCount("frontend"))
+	require.Equal(t, 2, renderCount("backend"))
+	require.Equal(t, 3, renderCount("external"))
+}
+
+func TestAppRun_RenderCache_Integration(t *testing.T) {
+	cmdLogPath, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	appOfAppsDir := filepath.Join(testRootDir, "app-of-apps-chart")
+	fakeRepoPath := createFakeGitRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+	appOfAppsTemplate := fmt.Sprintf(`
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: my-service
+  annotations:
+    rawRepository: "%s"
+    rawPath: "stable/my-service"
+spec:
+  source:
+    targetRevision: master
+`, fakeRepoPath)
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "app.yaml"), []byte(appOfAppsTemplate), 0644))
+
+	reportFile := filepath.Join(testRootDir, "report.json")
+	cfg := Config{
+		ChartPath:  appOfAppsDir,
+		OutputDir:  outputDir,
+		CacheDir:   filepath.Join(testRootDir, "cache"),
+		ReportFile: reportFile,
+	}
+
+	// Второй запуск с теми же входными данными берет результат из кэша,
+	// даже если директория с результатом удалена
+	for run := 0; run < 2; run++ {
+		require.NoError(t, os.RemoveAll(outputDir))
+		cfg.tempDir_ = t.TempDir()
+		require.NoError(t, Run(cfg))
+
+		content, err := os.ReadFile(filepath.Join(outputDir, "my-service.yaml"))
+		require.NoError(t, err)
+		require.Contains(t, string(content), "name: my-service")
+	}
+
+	cmdLog, err := os.ReadFile(cmdLogPath)
+	require.NoError(t, err)
+	require.Equal(t, 1, bytes.Count(cmdLog, []byte("helm template my-service ")))
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 1, runReport.Summary.CacheHits)
+	require.Equal(t, 0, runReport.Summary.CacheMisses)
+	require.Equal(t, report.RenderCacheHit, runReport.Applications[0].RenderCache)
+}
+
+func TestAppRun_Concurrency_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	fakeRepoPath := createFakeGitRepo(t)
+	templates := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("service-%d", i)
+		templates[name] = fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", name)
+	}
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, templates, nil)
+
+	// Отчет и индекс не зависят от числа одновременно рендерящихся приложений
+	run := func(concurrency int) (report.RunReport, []byte) {
+		outputDir := filepath.Join(testRootDir, fmt.Sprintf("output-%d", concurrency))
+		reportFile := filepath.Join(testRootDir, fmt.Sprintf("report-%d.json", concurrency))
+		cfg := Config{
+			ChartPath:   appOfAppsDir,
+			OutputDir:   outputDir,
+			ReportFile:  reportFile,
+			Concurrency: concurrency,
+			tempDir_:    t.TempDir(),
+		}
+		require.NoError(t, Run(cfg))
+
+		data, err := os.ReadFile(reportFile)
+		require.NoError(t, err)
+		var runReport report.RunReport
+		require.NoError(t, json.Unmarshal(data, &runReport))
+		index, err := os.ReadFile(filepath.Join(outputDir, output.IndexFile))
+		require.NoError(t, err)
+		return runReport, index
+	}
+	sequential, sequentialIndex := run(1)
+	concurrent, concurrentIndex := run(4)
+
+	require.Equal(t, report.Summary{Total: 8, Succeeded: 8}, concurrent.Summary)
+	require.Equal(t, string(sequentialIndex), string(concurrentIndex))
+	require.Len(t, concurrent.Applications, len(sequential.Applications))
+	cloned := 0
+	for i, appReport := range concurrent.Applications {
+		require.Equal(t, sequential.Applications[i].Name, appReport.Name)
+		require.Equal(t, sequential.Applications[i].CommitSHA, appReport.CommitSHA)
+		require.Equal(t, map[string]int{"ConfigMap": 1}, appReport.Resources)
+		if !appReport.CloneCached {
+			cloned++
+		}
+	}
+	// Репозиторий клонируется один раз, остальные приложения ждут этот клон
+	require.Equal(t, 1, cloned)
+}
+
+func TestAppValidateExplain_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	fakeRepoPath := createFakeGitRepo(t)
+	appOfAppsDir := filepath.Join(t.TempDir(), "app-of-apps-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+	appOfAppsTemplate := fmt.Sprintf(`
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: dev-my-service
+  labels:
+    env: dev
+  annotations:
+    rawRepository: "%s"
+spec:
+  source:
+    repoURL: https://gitlab.example.com/org/my-service.git
+    path: stable/my-service
+    targetRevision: master
+    plugin:
+      env:
+        - name: WERF_SET_REPLICA_COUNT
+          value: "replicaCount=3"
+        - name: WERF_SET_ENV
+          value: "global.env=dev"
+        - name: WERF_VALUES_0
+          value: ".helm/values-dev.yaml"
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: remote-service
+spec:
+  source:
+    repoURL: https://gitlab.example.com/org/remote-service.git
+    path: stable/remote-service
+    targetRevision: master
+`, fakeRepoPath)
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "app.yaml"), []byte(appOfAppsTemplate), 0644))
+
+	// validate проверяет app-of-apps без клонирования репозиториев
+	var out bytes.Buffer
+	cfg := Config{ChartPath: appOfAppsDir, OutputDir: "rendered", Stdout: &out}
+	require.NoError(t, Validate(cfg))
+	require.Equal(t, "2 Applications are valid, 2 selected\n", out.String())
+
+	// explain показывает происхождение параметров и проверяет файлы в клоне
+	out.Reset()
+	require.NoError(t, Explain(ExplainConfig{Render: cfg, App: "dev-my-service"}))
+	explained := out.String()
+	require.Regexp(t, `Env:\s+dev\s+metadata.labels.env`, explained)
+	require.Regexp(t, `Repository:\s+\S+\s+metadata.annotations.rawRepository`, explained)
+	require.Regexp(t, `Path:\s+stable/my-service\s+spec.source.path \(annotation fallback\)`, explained)
+	require.Regexp(t, `Chart:\s+\$REPO/stable/my-service/.helm\s+found`, explained)
+	require.Regexp(t, `1. \$REPO/stable/my-service/.helm/values-dev.yaml\s+MISSING\s+spec.source.plugin.env.WERF_VALUES_0`, explained)
+	require.Regexp(t, `global.env=dev\s+injected from env \(metadata.labels.env\)`, explained)
+	require.Regexp(t, `replicaCount=3\s+spec.source.plugin.env.WERF_SET_REPLICA_COUNT`, explained)
+	require.Contains(t, explained, "helm template dev-my-service $REPO/stable/my-service/.helm --values $REPO/stable/my-service/.helm/values-dev.yaml --set global.env=dev --set replicaCount=3")
+	require.Contains(t, explained, "missing 'rawPath' annotation")
+	require.Contains(t, explained, "values file stable/my-service/.helm/values-dev.yaml does not exist")
+	require.Contains(t, explained, filepath.Join("rendered", "dev", "dev-my-service.yaml"))
+
+	// без клонирования файлы не проверяются
+	out.Reset()
+	require.NoError(t, Explain(ExplainConfig{Render: cfg, App: "remote-service", Offline: true}))
+	require.Contains(t, out.String(), "cloned as git@gitlab.example.com:org/remote-service.git")
+	require.Regexp(t, `Chart:\s+\S+\s+not checked`, out.String())
+	require.Contains(t, out.String(), "missing 'rawRepository' annotation")
+
+	require.ErrorContains(t, Explain(ExplainConfig{Render: cfg, App: "missing"}), "application 'missing' not found")
+}
+
+func TestAppRun_ValidateSchemas_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+
+	// Два сервиса: с корректными манифестами и с ошибками в типах полей
+	templates := map[string]string{
+		"valid": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: valid
+data:
+  key: value
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: valid
+spec:
+  size: 3
+`,
+		"broken": `# Source: broken/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: broken
+spec:
+  replicas: "2"
+  selector:
+    matchLabels: {app: broken}
+  template:
+    spec:
+      containers:
+        - name: app
+          image: nginx
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: broken
+spec:
+  size: large
+`,
+	}
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, templates, nil)
+
+	// Схема пользовательского ресурса берется из CRD в отдельной директории
+	schemaDir := filepath.Join(testRootDir, "schemas")
+	require.NoError(t, os.MkdirAll(schemaDir, 0755))
+	crd := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+    - name: v1
+      served: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                size: {type: integer}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(schemaDir, "widget.yaml"), []byte(crd), 0644))
+
+	reportFile := filepath.Join(testRootDir, "report.json")
+	err := Run(Config{
+		ChartPath:  appOfAppsDir,
+		OutputDir:  outputDir,
+		SchemaDirs: []string{schemaDir},
+		ReportFile: reportFile,
+		tempDir_:   t.TempDir(),
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "schema validation failed for 1 applications: broken")
+
+	require.FileExists(t, filepath.Join(outputDir, "valid.yaml"))
+	require.NoFileExists(t, filepath.Join(outputDir, "broken.yaml"))
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 1, runReport.Summary.Failed)
+	for _, app := range runReport.Applications {
+		if app.Name != "broken" {
+			require.Empty(t, app.SchemaErrors)
+			continue
+		}
+		require.Equal(t, []report.SchemaError{
+			{Resource: "Deployment broken", Source: "broken/templates/deployment.yaml", Path: "spec.replicas", Message: "expected integer, got string"},
+			{Resource: "Widget broken", Path: "spec.size", Message: "expected integer, got string"},
+		}, app.SchemaErrors)
+	}
+}
+
+func TestAppRun_Policies_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+
+	deployment := func(name, image string) string {
+		return fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: %s
+`, name, image)
+	}
+	// Сервис legacy использует тег latest, но освобожден от этой политики аннотацией
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{
+		"pinned":   deployment("pinned", "nginx:1.25"),
+		"unpinned": deployment("unpinned", "nginx:latest"),
+		"legacy":   deployment("legacy", "nginx:latest"),
+	}, map[string]string{
+		"legacy": "    policyExemptions: no-latest-tag",
+	})
+
+	policyDir := filepath.Join(testRootDir, "policies")
+	require.NoError(t, os.MkdirAll(policyDir, 0755))
+	policies := `policies:
+  - name: no-latest-tag
+    kinds: [Deployment]
+    rule: object.spec.template.spec.containers.all(c, !c.image.endsWith(":latest"))
+    message: images must be pinned
+  - name: resource-limits
+    severity: warn
+    kinds: [Deployment]
+    rule: object.spec.template.spec.containers.all(c, has(c.resources))
+`
+	require.NoError(t, os.WriteFile(filepath.Join(policyDir, "org.yaml"), []byte(policies), 0644))
+
+	reportFile := filepath.Join(testRootDir, "report.json")
+	cfg := Config{
+		ChartPath:  appOfAppsDir,
+		OutputDir:  outputDir,
+		PolicyDirs: []string{policyDir},
+		ReportFile: reportFile,
+		tempDir_:   t.TempDir(),
+	}
+	err := Run(cfg)
+	require.Error(t, err)
+	require.Equal(t, "policy checks failed for 1 applications: unpinned", err.Error())
+
+	require.FileExists(t, filepath.Join(outputDir, "pinned.yaml"))
+	require.FileExists(t, filepath.Join(outputDir, "legacy.yaml"))
+	require.NoFileExists(t, filepath.Join(outputDir, "unpinned.yaml"))
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 3, runReport.Summary.PolicyWarnings)
+	require.Equal(t, 1, runReport.Summary.PolicyDenials)
+	for _, app := range runReport.Applications {
+		if app.Name == "legacy" {
+			require.Equal(t, []report.PolicyResult{
+				{Policy: "no-latest-tag", Severity: "deny", Resource: "Deployment legacy", Message: "images must be pinned", Exempt: true},
+				{Policy: "resource-limits", Severity: "warn", Resource: "Deployment legacy", Message: "violates policy resource-limits"},
+			}, app.Policies)
+		}
+	}
+
+	// Без изменений приложения pinned и legacy переиспользуются, но политики
+	// проверяются на их предыдущем выводе так же, как при полном запуске
+	cfg.tempDir_ = t.TempDir()
+	cfg.ChangedFiles = []string{"README.md"}
+	err = Run(cfg)
+	require.Error(t, err)
+	require.Equal(t, "policy checks failed for 1 applications: unpinned", err.Error())
+
+	data, err = os.ReadFile(reportFile)
+	require.NoError(t, err)
+	runReport = report.RunReport{}
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 2, runReport.Summary.Reused)
+	require.Equal(t, 3, runReport.Summary.PolicyWarnings)
+	require.Equal(t, 1, runReport.Summary.PolicyDenials)
+}
+
+func TestAppRun_DeprecatedAPIs_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{
+		"legacy": `apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+`,
+		"current": `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+`,
+	}, nil)
+
+	// Проверка для версии 1.22: Ingress из extensions/v1beta1 уже удален,
+	// CronJob из batch/v1beta1 только объявлен устаревшим
+	var stdout bytes.Buffer
+	reportFile := filepath.Join(testRootDir, "report.json")
+	cfg := Config{
+		ChartPath:           appOfAppsDir,
+		OutputDir:           outputDir,
+		CheckDeprecatedAPIs: true,
+		TargetKubeVersion:   "1.22",
+		ReportFile:          reportFile,
+		Stdout:              &stdout,
+		tempDir_:            t.TempDir(),
+	}
+	err := Run(cfg)
+	require.Error(t, err)
+	require.Equal(t, "1 applications use removed Kubernetes APIs: legacy", err.Error())
+	require.FileExists(t, filepath.Join(outputDir, "legacy.yaml"))
+
+	require.Contains(t, stdout.String(), "Deprecated Kubernetes APIs as of 1.22:")
+	require.Regexp(t, `legacy\s+Ingress web\s+extensions/v1beta1\s+removed in 1.22\s+networking.k8s.io/v1`, stdout.String())
+	require.Regexp(t, `legacy\s+CronJob cleanup\s+batch/v1beta1\s+deprecated in 1.21\s+batch/v1`, stdout.String())
+	require.NotContains(t, stdout.String(), "current")
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 1, runReport.Summary.DeprecatedAPIs)
+	require.Equal(t, 1, runReport.Summary.RemovedAPIs)
+
+	// Вывод переиспользованных и отфильтрованных приложений проверяется так же
+	for _, change := range []func(*Config){
+		func(cfg *Config) { cfg.ChangedFiles = []string{"README.md"} },
+		func(cfg *Config) { cfg.ChangedFiles, cfg.Filter = nil, argo.Filter{Apps: []string{"current"}} },
+	} {
+		change(&cfg)
+		cfg.tempDir_ = t.TempDir()
+		stdout.Reset()
+		err = Run(cfg)
+		require.Error(t, err)
+		require.Equal(t, "1 applications use removed Kubernetes APIs: legacy", err.Error())
+		require.Regexp(t, `legacy\s+Ingress web\s+extensions/v1beta1\s+removed in 1.22`, stdout.String())
+	}
+}
+
+func TestAppRun_Duplicates_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+
+	sharedConfig := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: shared-config
+  namespace: default
+`
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{
+		"first":  sharedConfig,
+		"second": sharedConfig,
+		// Без namespace ресурс попадает в namespace назначения приложения,
+		// а не в default
+		"third": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: shared-config
+`,
+	}, nil)
+
+	var stdout bytes.Buffer
+	reportFile := filepath.Join(testRootDir, "report.json")
+	err := Run(Config{
+		ChartPath:       appOfAppsDir,
+		OutputDir:       outputDir,
+		CheckDuplicates: true,
+		ReportFile:      reportFile,
+		Stdout:          &stdout,
+		tempDir_:        t.TempDir(),
+	})
+	require.Error(t, err)
+	require.Equal(t, "1 resources are rendered by more than one application", err.Error())
+	require.FileExists(t, filepath.Join(outputDir, "first.yaml"))
+	require.FileExists(t, filepath.Join(outputDir, "second.yaml"))
+	require.Contains(t, stdout.String(), "Resources rendered by more than one application:")
+	require.Regexp(t, `ConfigMap default/shared-config\s+first, second`, stdout.String())
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 1, runReport.Summary.Duplicates)
+	require.Equal(t, []report.Duplicate{{
+		Kind:         "ConfigMap",
+		Namespace:    "default",
+		Name:         "shared-config",
+		Resource:     "ConfigMap default/shared-config",
+		Applications: []string{"first", "second"},
+	}}, runReport.Duplicates)
+
+	// Отфильтрованные приложения сохраняют вывод прошлого запуска, и
+	// дубликаты с ним по-прежнему находятся
+	stdout.Reset()
+	err = Run(Config{
+		ChartPath:       appOfAppsDir,
+		OutputDir:       outputDir,
+		Filter:          argo.Filter{Apps: []string{"first"}},
+		CheckDuplicates: true,
+		Stdout:          &stdout,
+		tempDir_:        t.TempDir(),
+	})
+	require.Error(t, err)
+	require.Regexp(t, `ConfigMap default/shared-config\s+first, second`, stdout.String())
+}
+
+func TestAppRun_Lenient_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+	configMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{"web": configMap, "api": configMap}, nil)
+	cfg := Config{ChartPath: appOfAppsDir, OutputDir: outputDir, Prune: true, tempDir_: t.TempDir()}
+	require.NoError(t, Run(cfg))
+
+	// Документ api становится некорректным, и добавляется документ с
+	// невалидным YAML
+	templatePath := filepath.Join(appOfAppsDir, "templates", "apps.yaml")
+	template, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	template = bytes.Replace(template, []byte(`rawPath: "stable/api"`), []byte(`rawPath: "../../api"`), 1)
+	template = append(template, []byte(`---
+# Source: fake-chart/templates/broken.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: broken: yes
+`)...)
+	require.NoError(t, os.WriteFile(templatePath, template, 0644))
+
+	// Без --lenient запуск прерывается до рендеринга
+	err = Run(cfg)
+	require.ErrorContains(t, err, "initialization failed")
+
+	cfg.Lenient = true
+	cfg.ReportFile = filepath.Join(testRootDir, "report.json")
+	cfg.tempDir_ = t.TempDir()
+	err = Run(cfg)
+	require.EqualError(t, err, "2 invalid Application documents were skipped")
+	require.FileExists(t, filepath.Join(outputDir, "web.yaml"))
+	// Вывод пропущенного приложения не удаляется --prune
+	require.FileExists(t, filepath.Join(outputDir, "api.yaml"))
+
+	data, err := os.ReadFile(cfg.ReportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Equal(t, 2, runReport.Summary.InvalidDocuments)
+	require.Equal(t, 1, runReport.Summary.Succeeded)
+	require.Len(t, runReport.InvalidDocuments, 2)
+	require.Equal(t, "api", runReport.InvalidDocuments[0].Application)
+	require.Equal(t, "metadata.annotations.rawPath", runReport.InvalidDocuments[0].Field)
+	require.Equal(t, report.InvalidDocument{
+		Document: 2,
+		Source:   "fake-chart/templates/broken.yaml",
+		Message:  "yaml: line 4: mapping values are not allowed in this context",
+	}, runReport.InvalidDocuments[1])
+
+	// validate в нестрогом режиме перечисляет проблемы всех документов
+	var stdout bytes.Buffer
+	cfg.Stdout = &stdout
+	err = Validate(cfg)
+	var validationErr *argo.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Problems, 2)
+	require.Equal(t, "1 Applications are valid, 1 selected\n", stdout.String())
+}
+
+func TestAppRun_NamespacedApplications_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{"web": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`}, nil)
+
+	// Три Application с именем web: в namespace Argo CD, в team-a и в team-b
+	// с другой версией API
+	templatePath := filepath.Join(appOfAppsDir, "templates", "apps.yaml")
+	template, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	inNamespace := func(namespace string) []byte {
+		return bytes.Replace(template, []byte(`rawPath: "stable/web"`), []byte("rawPath: \"stable/web\"\n  namespace: "+namespace), 1)
+	}
+	apps := append(append([]byte{}, inNamespace("argocd")...), inNamespace("team-a")...)
+	apps = append(apps, bytes.Replace(inNamespace("team-b"), []byte("argoproj.io/v1alpha1"), []byte("argoproj.io/v1beta1"), 1)...)
+	require.NoError(t, os.WriteFile(templatePath, apps, 0644))
+
+	reportFile := filepath.Join(testRootDir, "report.json")
+	cfg := Config{
+		ChartPath:    appOfAppsDir,
+		OutputDir:    outputDir,
+		Applications: argo.ParseOptions{APIVersions: []string{"argoproj.io/v1alpha1", "argoproj.io/v1beta1"}},
+		ReportFile:   reportFile,
+		tempDir_:     t.TempDir(),
+	}
+	require.NoError(t, Run(cfg))
+
+	require.FileExists(t, filepath.Join(outputDir, "web.yaml"))
+	require.FileExists(t, filepath.Join(outputDir, "team-a", "web.yaml"))
+	require.FileExists(t, filepath.Join(outputDir, "team-b", "web.yaml"))
+
+	index, err := output.ReadIndex(outputDir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"team-a/web.yaml"}, index.Applications["team-a/web"])
+	require.Equal(t, []string{"web.yaml"}, index.Applications["web"])
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	var names []string
+	for _, app := range runReport.Applications {
+		names = append(names, app.Name)
+	}
+	require.Equal(t, []string{"web", "team-a/web", "team-b/web"}, names)
+
+	// Без v1beta1 в списке версий третий документ не считается Application
+	cfg.Applications.APIVersions = nil
+	cfg.tempDir_ = t.TempDir()
+	var stdout bytes.Buffer
+	cfg.Stdout = &stdout
+	require.NoError(t, Validate(cfg))
+	require.Equal(t, "2 Applications are valid, 2 selected\n", stdout.String())
+}
+
+func TestAppRun_RepoRewrites_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{"web": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`}, nil)
+
+	// Application ссылается на недоступный репозиторий, а клонируется из
+	// локального зеркала
+	templatePath := filepath.Join(appOfAppsDir, "templates", "apps.yaml")
+	template, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	template = bytes.Replace(template, []byte(fakeRepoPath), []byte("https://git.example.invalid/team/web.git"), 1)
+	require.NoError(t, os.WriteFile(templatePath, template, 0644))
+
+	reportFile := filepath.Join(testRootDir, "report.json")
+	cfg := Config{
+		ChartPath:    appOfAppsDir,
+		OutputDir:    outputDir,
+		RepoRewrites: map[string]string{"https://git.example.invalid/team/web.git": fakeRepoPath},
+		ReportFile:   reportFile,
+		tempDir_:     t.TempDir(),
+	}
+	require.NoError(t, Run(cfg))
+	require.FileExists(t, filepath.Join(outputDir, "web.yaml"))
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	require.Len(t, runReport.Applications, 1)
+	require.Equal(t, fakeRepoPath, runReport.Applications[0].RepoURL)
+
+	// Ненайденный SSH-ключ прерывает запуск до клонирования
+	cfg.SSHKeyFile = filepath.Join(testRootDir, "id_ed25519")
+	cfg.tempDir_ = t.TempDir()
+	require.ErrorContains(t, Run(cfg), "failed to load SSH key")
+}
+
+func TestAppRun_SyncOrder_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+	configMap := func(name string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n"
+	}
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{
+		"web": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: late
+  annotations:
+    argocd.argoproj.io/sync-wave: "2"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+		"db":    configMap("db"),
+		"api":   configMap("api"),
+		"cache": configMap("cache"),
+	}, map[string]string{
+		"db":  `    argocd.argoproj.io/sync-wave: "-1"`,
+		"api": `    argocd.argoproj.io/sync-wave: "1"`,
+	})
+
+	reportFile := filepath.Join(testRootDir, "report.json")
+	require.NoError(t, Run(Config{
+		ChartPath:  appOfAppsDir,
+		OutputDir:  outputDir,
+		SyncOrder:  true,
+		ReportFile: reportFile,
+		tempDir_:   t.TempDir(),
+	}))
+
+	// Ресурсы записываются в порядке синхронизации: хуки PreSync, затем
+	// ресурсы волны 0 по виду, затем следующие волны
+	content, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+	require.NoError(t, err)
+	require.Regexp(t, `(?s)name: migrate.*kind: Service.*kind: Deployment.*name: late`, string(content))
+
+	index, err := output.ReadIndex(outputDir)
+	require.NoError(t, err)
+	require.Equal(t, []output.SyncWave{
+		{Wave: -1, Applications: []string{"db"}},
+		{Wave: 0, Applications: []string{"cache", "web"}},
+		{Wave: 1, Applications: []string{"api"}},
+	}, index.SyncWaves)
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var runReport report.RunReport
+	require.NoError(t, json.Unmarshal(data, &runReport))
+	var names []string
+	for _, app := range runReport.Applications {
+		names = append(names, app.Name)
+	}
+	require.Equal(t, []string{"db", "cache", "web", "api"}, names)
+
+	// Без --sync-order порядок ресурсов и индекс остаются прежними
+	require.NoError(t, Run(Config{ChartPath: appOfAppsDir, OutputDir: outputDir, tempDir_: t.TempDir()}))
+	content, err = os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+	require.NoError(t, err)
+	require.Regexp(t, `(?s)kind: Deployment.*name: migrate.*name: late.*kind: Service`, string(content))
+	index, err = output.ReadIndex(outputDir)
+	require.NoError(t, err)
+	require.Empty(t, index.SyncWaves)
+}
+
+func TestImages_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	fakeRepoPath := createFakeGitRepo(t)
+
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{
+		"web": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: registry.example.com/web/migrate:1.4
+      containers:
+      - name: app
+        image: registry.example.com/web@sha256:0123abcd
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: canary
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.example.com/web:canary
+`,
+		"config": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`,
+	}, nil)
+
+	var stdout bytes.Buffer
+	err := Images(ImagesConfig{
+		Render:      Config{ChartPath: appOfAppsDir, Stdout: &stdout, tempDir_: t.TempDir()},
+		Paths:       []string{"Rollout=.spec.template.spec.containers[*].image"},
+		Format:      images.FormatCSV,
+		FlagMutable: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, `env,instance,application,resource,container,type,image,repository,tag,digest,mutable
+,,web,Deployment web,migrate,initContainer,registry.example.com/web/migrate:1.4,registry.example.com/web/migrate,1.4,,true
+,,web,Deployment web,app,container,registry.example.com/web@sha256:0123abcd,registry.example.com/web,,sha256:0123abcd,false
+,,web,Rollout canary,app,custom,registry.example.com/web:canary,registry.example.com/web,canary,,true
+`, stdout.String())
+
+	// JSON-инвентарь в файл; приложения без образов тоже перечисляются
+	inventoryFile := filepath.Join(testRootDir, "images.json")
+	err = Images(ImagesConfig{
+		Render:     Config{ChartPath: appOfAppsDir, tempDir_: t.TempDir()},
+		Format:     images.FormatJSON,
+		OutputFile: inventoryFile,
+	})
+	require.NoError(t, err)
+	data, err := os.ReadFile(inventoryFile)
+	require.NoError(t, err)
+	var inventory images.Inventory
+	require.NoError(t, json.Unmarshal(data, &inventory))
+	require.Len(t, inventory.Applications, 2)
+	require.Equal(t, "config", inventory.Applications[0].Name)
+	require.Empty(t, inventory.Applications[0].Images)
+	require.Len(t, inventory.Applications[1].Images, 2)
+	require.Equal(t, []string{"registry.example.com/web/migrate:1.4", "registry.example.com/web@sha256:0123abcd"}, inventory.Images)
+}
+
+func TestValidate_InvalidApplications_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	appOfAppsDir := filepath.Join(t.TempDir(), "app-of-apps-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "apps.yaml"), []byte(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations:
+    rawRepository: https://gitlab.example.com/org/web.git
+    rawPath: ../../etc
+spec:
+  source:
+    plugin:
+      env:
+        - name: WERF_VALUES_first
+          value: values.yaml
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations:
+    rawRepository: https://gitlab.example.com/org/web.git
+spec:
+  source:
+    targetRevision: main
+`), 0644))
+
+	// Все проблемы выводятся сразу, до клонирования репозиториев
+	cfg := Config{ChartPath: appOfAppsDir, OutputDir: t.TempDir(), tempDir_: t.TempDir()}
+	err := Validate(cfg)
+	var validationErr *argo.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Problems, 3)
+	require.Contains(t, err.Error(), "document #0, line 7, column 14, application 'web': metadata.annotations.rawPath: path \"../../etc\" points outside of the repository")
+	require.Contains(t, err.Error(), "spec.source.targetRevision: is missing")
+	// Нечисловой индекс — предупреждение, а не ошибка
+	require.NotContains(t, err.Error(), "WERF_VALUES_first")
+	require.Contains(t, err.Error(), "document #1, line 18, column 9, application 'web': metadata.name: duplicate Application name")
+
+	err = Run(cfg)
+	require.ErrorAs(t, err, &validationErr)
+}
+
+func TestAppRun_SkippedPluginEnv_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	testRootDir := t.TempDir()
+	outputDir := filepath.Join(testRootDir, "output")
+	fakeRepoPath := createFakeGitRepo(t)
+	appOfAppsDir := createAppsWithTemplates(t, testRootDir, fakeRepoPath, map[string]string{"web": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`}, nil)
+
+	// Переменные, которые нельзя использовать, выводятся как предупреждения и
+	// пропускаются, но не прерывают запуск и в строгом режиме
+	templatePath := filepath.Join(appOfAppsDir, "templates", "apps.yaml")
+	template, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	template = bytes.Replace(template, []byte("targetRevision: master"), []byte(`targetRevision: master
+    plugin:
+      env:
+        - {name: WERF_VALUES_first, value: values.yaml}
+        - {name: WERF_SET_BROKEN, value: no-equals-sign}`), 1)
+	require.NoError(t, os.WriteFile(templatePath, template, 0644))
+
+	var stdout bytes.Buffer
+	cfg := Config{ChartPath: appOfAppsDir, OutputDir: outputDir, Stdout: &stdout, tempDir_: t.TempDir()}
+	require.NoError(t, Validate(cfg))
+	require.Regexp(t, `warning: document #0, line \d+, column \d+, application 'web': spec.source.plugin.env.WERF_VALUES_first: invalid values file index "first"`, stdout.String())
+	require.Regexp(t, `warning: document #0, line \d+, column \d+, application 'web': spec.source.plugin.env.WERF_SET_BROKEN: invalid value "no-equals-sign", expected key=value`, stdout.String())
+	require.Contains(t, stdout.String(), "1 Applications are valid, 1 selected")
+
+	cfg.tempDir_ = t.TempDir()
+	require.NoError(t, Run(cfg))
+	require.FileExists(t, filepath.Join(outputDir, "web.yaml"))
+
+	// В нестрогом режиме документ с предупреждениями не пропускается
+	cfg.Lenient = true
+	cfg.tempDir_ = t.TempDir()
+	require.NoError(t, Run(cfg))
+}
+
+func TestValidate_TemplateSources_Integration(t *testing.T) {
+	_, cleanup := setupIntegrationTest(t)
+	defer cleanup()
+
+	// Фейковый helm выводит шаблон как есть, поэтому комментарии `# Source:`
+	// добавляются в сам шаблон, как их вставил бы настоящий helm template
+	appOfAppsDir := filepath.Join(t.TempDir(), "app-of-apps-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(appOfAppsDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "Chart.yaml"), []byte("apiVersion: v2\nname: fake-chart\nversion: 0.1.0"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "apps.yaml"), []byte(`---
+# Source: fake-chart/templates/api.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: api
+  annotations:
+    rawRepository: https://gitlab.example.com/org/api.git
+spec:
+  source:
+    targetRevision: main
+---
+# Source: fake-chart/templates/web.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations:
+    rawRepository: https://gitlab.example.com/org/web.git
+    rawPath: ../../etc
+spec:
+  source:
+    targetRevision: main
+`), 0644))
+
+	cfg := Config{ChartPath: appOfAppsDir, OutputDir: t.TempDir(), tempDir_: t.TempDir()}
+	err := Validate(cfg)
+	var validationErr *argo.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Problems, 1)
+	require.Equal(t, "fake-chart/templates/web.yaml", validationErr.Problems[0].Source)
+	require.Contains(t, err.Error(), "document #1 (fake-chart/templates/web.yaml), line 7, column 14, application 'web': metadata.annotations.rawPath")
+
+	// Ошибка разбора YAML тоже указывает на шаблон и строку в нем
+	require.NoError(t, os.WriteFile(filepath.Join(appOfAppsDir, "templates", "apps.yaml"), []byte(`---
+# Source: fake-chart/templates/web.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web: broken
+`), 0644))
+	err = Run(cfg)
+	require.ErrorContains(t, err, "failed to decode yaml document #0 (fake-chart/templates/web.yaml): yaml: line 4: mapping values are not allowed")
 }
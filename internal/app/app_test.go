@@ -6,6 +6,16 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestConfigCheckKubeVersions(t *testing.T) {
+	require.NoError(t, Config{}.CheckKubeVersions())
+	require.NoError(t, Config{KubeVersion: "v1.29.3", TargetKubeVersion: "1.30"}.CheckKubeVersions())
+
+	err := Config{KubeVersion: "1.29", TargetKubeVersion: "latest"}.CheckKubeVersions()
+	require.ErrorContains(t, err, "--target-kube-version (validate.targetKubeVersion): invalid Kubernetes version 'latest'")
+	err = Config{KubeVersion: "1,29"}.CheckKubeVersions()
+	require.ErrorContains(t, err, "--kube-version (render.kubeVersion): invalid Kubernetes version '1,29'")
+}
+
 func TestConvertHTTPtoSSH(t *testing.T) {
 	tests := []struct {
 		name     string
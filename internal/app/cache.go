@@ -0,0 +1,73 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/cache"
+	"roar/internal/pkg/helm"
+	"roar/internal/pkg/logger"
+	"roar/internal/pkg/report"
+)
+
+// renderChart runs `helm template` for app, or returns the output of an
+// identical earlier render from the render cache. Cache failures are logged
+// and never fail the render.
+func renderChart(app argo.Application, repo clonedRepo, opts helm.RenderOptions, state *appState, appReport *report.ApplicationReport) ([]byte, error) {
+	if state.cache == nil {
+		return helm.Template(opts)
+	}
+	logCtx := logger.Log.WithField("application", app.QualifiedName())
+
+	key, err := renderCacheKey(repo, opts, state)
+	if err != nil {
+		// helm reports a missing values file far better than we could.
+		logCtx.Debugf("Not using the render cache: %v", err)
+		return helm.Template(opts)
+	}
+	if rendered, ok, err := state.cache.Get(key); err != nil {
+		logCtx.Warnf("Could not read the render cache: %v", err)
+	} else if ok {
+		logCtx.Infof("Using cached render %s", key)
+		appReport.RenderCache = report.RenderCacheHit
+		return rendered, nil
+	}
+
+	appReport.RenderCache = report.RenderCacheMiss
+	rendered, err := helm.Template(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.cache.Put(key, rendered); err != nil {
+		logCtx.Warnf("Could not update the render cache: %v", err)
+	}
+	return rendered, nil
+}
+
+func renderCacheKey(repo clonedRepo, opts helm.RenderOptions, state *appState) (string, error) {
+	chartPath, err := filepath.Rel(repo.path, opts.ChartPath)
+	if err != nil {
+		return "", err
+	}
+	inputs := cache.Inputs{
+		CommitSHA:       repo.sha,
+		ChartPath:       filepath.ToSlash(chartPath),
+		ReleaseName:     opts.ReleaseName,
+		Setters:         opts.SetValues,
+		KubeVersion:     opts.KubeVersion,
+		RendererVersion: state.version,
+	}
+	for _, file := range opts.ValuesFiles {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(repo.path, file)
+		if err != nil {
+			return "", err
+		}
+		inputs.ValuesFiles = append(inputs.ValuesFiles, cache.ValuesFile{Path: filepath.ToSlash(rel), Content: content})
+	}
+	return inputs.Key(), nil
+}
@@ -0,0 +1,387 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/deprecation"
+	"roar/internal/pkg/duplicates"
+	"roar/internal/pkg/kubeversion"
+	"roar/internal/pkg/logger"
+	"roar/internal/pkg/manifest"
+	"roar/internal/pkg/output"
+	"roar/internal/pkg/policy"
+	"roar/internal/pkg/report"
+	"roar/internal/pkg/schema"
+
+	"github.com/sirupsen/logrus"
+)
+
+// schemaError is returned for an application whose rendered resources do not
+// match their schemas; its output is not written.
+type schemaError struct {
+	violations []report.SchemaError
+}
+
+func (e *schemaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "schema validation failed with %d violations:", len(e.violations))
+	for _, v := range e.violations {
+		fmt.Fprintf(&b, "\n  %s", v)
+	}
+	return b.String()
+}
+
+// policyError is returned for an application that does not comply with a
+// policy with the deny severity; its output is not written.
+type policyError struct {
+	denials []report.PolicyResult
+}
+
+func (e *policyError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "denied by %d policy results:", len(e.denials))
+	for _, d := range e.denials {
+		fmt.Fprintf(&b, "\n  %s", d)
+	}
+	return b.String()
+}
+
+func loadSchemas(dirs []string, kubeVersion string) (*schema.Set, error) {
+	schemas, err := schema.Bundled()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := schemas.LoadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	bundled := schemas.KubernetesVersion(kubeVersion)
+	if kubeVersion != "" && (kubeversion.Less(kubeVersion, bundled) || kubeversion.Less(bundled, kubeVersion)) {
+		logger.Log.Warnf("There are no bundled schemas for Kubernetes %s, built-in resources are validated against those of Kubernetes %s.", kubeVersion, bundled)
+	}
+	logger.Log.Infof("Validating rendered resources against %d schemas (built-in resources of Kubernetes %s).", schemas.Kinds(), bundled)
+	return schemas, nil
+}
+
+func loadPolicies(dirs []string) (*policy.Set, error) {
+	policies, err := policy.Load(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof("Evaluating %d policies on rendered resources.", policies.Len())
+	return policies, nil
+}
+
+// checksResources reports whether any check of the rendered resources is
+// enabled for the run.
+func (s *appState) checksResources() bool {
+	return s.schemas != nil || s.policies != nil
+}
+
+// checkResources runs the schema validation and the policies enabled for the
+// run on the rendered resources of an application. Both checks run even if
+// the first one fails, so that all problems are reported at once.
+func checkResources(app argo.Application, resources []manifest.Resource, state *appState, appReport *report.ApplicationReport, logCtx *logrus.Entry) error {
+	var errs []error
+	if state.schemas != nil {
+		errs = append(errs, validateSchemas(resources, state, appReport, logCtx))
+	}
+	if state.policies != nil {
+		errs = append(errs, evaluatePolicies(app, resources, state, appReport, logCtx))
+	}
+	return errors.Join(errs...)
+}
+
+// checkReusedOutput runs checkResources on the previous output of an
+// application that was not rendered again, so that incremental runs report
+// and fail on the same findings as a full run.
+func checkReusedOutput(app argo.Application, outputDir string, files []string, state *appState, appReport *report.ApplicationReport, logCtx *logrus.Entry) error {
+	resources, err := readOutputResources(outputDir, files)
+	if err != nil {
+		return err
+	}
+	return checkResources(app, resources, state, appReport, logCtx)
+}
+
+// readOutputResources parses the resources in the output files of an
+// application, given relative to outputDir as listed in the output index.
+func readOutputResources(outputDir string, files []string) ([]manifest.Resource, error) {
+	var all []manifest.Resource
+	for _, file := range files {
+		if filepath.Base(file) == output.KustomizationFile {
+			continue
+		}
+		path := filepath.Join(outputDir, filepath.FromSlash(file))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output file %s: %w", path, err)
+		}
+		resources, err := manifest.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse output file %s: %w", path, err)
+		}
+		all = append(all, resources...)
+	}
+	return all, nil
+}
+
+// findDeprecatedAPIs checks the output files of every application, whether
+// rendered, reused or carried over from a previous run, for deprecated or
+// removed Kubernetes APIs as of target and records them in the report of the
+// application.
+func findDeprecatedAPIs(applications []argo.Application, outputDir string, index *output.Index, runReport *report.RunReport, target string) error {
+	reports := make(map[string]*report.ApplicationReport, len(runReport.Applications))
+	for i := range runReport.Applications {
+		reports[runReport.Applications[i].Name] = &runReport.Applications[i]
+	}
+	for _, app := range applications {
+		name := app.QualifiedName()
+		appReport, ok := reports[name]
+		if !ok {
+			continue
+		}
+		resources, err := readOutputResources(outputDir, index.Applications[name])
+		if err != nil {
+			return err
+		}
+		checkDeprecatedAPIs(resources, target, appReport, logger.Log.WithField("application", name))
+	}
+	return nil
+}
+
+// checkDeprecatedAPIs records the resources that use deprecated or removed
+// Kubernetes APIs in appReport. They do not fail the application: the output
+// is still valid for the cluster it is rendered for, the run reports removed
+// APIs at the end.
+func checkDeprecatedAPIs(resources []manifest.Resource, target string, appReport *report.ApplicationReport, logCtx *logrus.Entry) {
+	for _, resource := range resources {
+		finding, found := deprecation.Check(resource, target)
+		if !found {
+			continue
+		}
+		api := report.DeprecatedAPI{
+			Resource:     resource.String(),
+			Source:       resource.Source,
+			APIVersion:   resource.APIVersion,
+			Status:       string(finding.Status),
+			DeprecatedIn: finding.DeprecatedIn,
+			RemovedIn:    finding.RemovedIn,
+			Replacement:  finding.Replacement,
+			Note:         finding.Note,
+		}
+		appReport.DeprecatedAPIs = append(appReport.DeprecatedAPIs, api)
+		logCtx.Warnf("%s uses %s API %s, %s.", api.Resource, api.Status, api.APIVersion, replacementOf(api))
+	}
+}
+
+func replacementOf(api report.DeprecatedAPI) string {
+	switch {
+	case api.Replacement != "":
+		return "migrate to " + api.Replacement
+	case api.Note != "":
+		return api.Note
+	}
+	return "no replacement"
+}
+
+// writeDeprecatedAPIs prints the applications using deprecated or removed
+// APIs as a table.
+func writeDeprecatedAPIs(cfg Config, runReport *report.RunReport) {
+	w := cfg.Stdout
+	if w == nil {
+		w = os.Stdout
+	}
+	target := cfg.TargetKubeVersion
+	if target == "" {
+		target = cfg.KubeVersion
+	}
+	if target == "" {
+		target = "any version"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	found := false
+	for _, app := range runReport.Applications {
+		for _, api := range app.DeprecatedAPIs {
+			if !found {
+				fmt.Fprintf(w, "Deprecated Kubernetes APIs as of %s:\n", target)
+				fmt.Fprintln(tw, "APPLICATION\tRESOURCE\tAPI VERSION\tSTATUS\tREPLACEMENT")
+				found = true
+			}
+			status := api.Status
+			if api.Status == report.APIRemoved {
+				status += " in " + api.RemovedIn
+			} else {
+				status += " in " + api.DeprecatedIn
+			}
+			replacement := api.Replacement
+			if replacement == "" {
+				replacement = dash(api.Note)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", app.Name, api.Resource, api.APIVersion, status, replacement)
+		}
+	}
+	if !found {
+		fmt.Fprintf(w, "No deprecated Kubernetes APIs as of %s.\n", target)
+		return
+	}
+	tw.Flush()
+}
+
+// findDuplicates indexes the resources in the output files of every
+// application, whether rendered, reused or carried over from a previous run,
+// and records the resources rendered by more than one application.
+func findDuplicates(applications []argo.Application, outputDir string, index *output.Index, runReport *report.RunReport) error {
+	owners := duplicates.NewIndex()
+	for _, app := range applications {
+		resources, err := readOutputResources(outputDir, index.Applications[app.QualifiedName()])
+		if err != nil {
+			return err
+		}
+		owners.Add(app, resources)
+	}
+	for _, d := range owners.Duplicates() {
+		duplicate := report.Duplicate{
+			Cluster:      d.Cluster,
+			Group:        d.Group,
+			Kind:         d.Kind,
+			Namespace:    d.Namespace,
+			Name:         d.Name,
+			Resource:     d.Key.String(),
+			Applications: d.Applications,
+		}
+		runReport.Duplicates = append(runReport.Duplicates, duplicate)
+		logger.Log.Warnf("%s in cluster %s is rendered by %d applications: %s.", duplicate.Resource, duplicate.Cluster, len(d.Applications), strings.Join(d.Applications, ", "))
+	}
+	return nil
+}
+
+// writeDuplicates prints the resources rendered by more than one application
+// as a table.
+func writeDuplicates(cfg Config, runReport *report.RunReport) {
+	w := cfg.Stdout
+	if w == nil {
+		w = os.Stdout
+	}
+	if len(runReport.Duplicates) == 0 {
+		fmt.Fprintln(w, "No resources are rendered by more than one application.")
+		return
+	}
+	fmt.Fprintln(w, "Resources rendered by more than one application:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tRESOURCE\tAPPLICATIONS")
+	for _, d := range runReport.Duplicates {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", dash(d.Cluster), d.Resource, strings.Join(d.Applications, ", "))
+	}
+	tw.Flush()
+}
+
+// validateSchemas checks every rendered resource of an application and records
+// the violations in appReport. Resources without a known schema are skipped.
+func validateSchemas(resources []manifest.Resource, state *appState, appReport *report.ApplicationReport, logCtx *logrus.Entry) error {
+	for _, resource := range resources {
+		violations, found := state.schemas.Validate(resource, state.kubeVersion)
+		if !found {
+			logCtx.Debugf("No schema for %s %s, not validating %s.", resource.APIVersion, resource.Kind, resource)
+			continue
+		}
+		for _, v := range violations {
+			appReport.SchemaErrors = append(appReport.SchemaErrors, report.SchemaError{
+				Resource: resource.String(),
+				Source:   resource.Source,
+				Path:     v.Path,
+				Message:  v.Message,
+			})
+		}
+	}
+	if len(appReport.SchemaErrors) > 0 {
+		return &schemaError{violations: appReport.SchemaErrors}
+	}
+	return nil
+}
+
+// evaluatePolicies records the policy results of every rendered resource in
+// appReport and fails the application if a policy with the deny severity is
+// not complied with. Warnings and exempted results are only logged.
+func evaluatePolicies(app argo.Application, resources []manifest.Resource, state *appState, appReport *report.ApplicationReport, logCtx *logrus.Entry) error {
+	var denials []report.PolicyResult
+	for _, resource := range resources {
+		results, err := state.policies.Evaluate(resource, app)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			result := report.PolicyResult{
+				Policy:   r.Policy,
+				Severity: string(r.Severity),
+				Resource: resource.String(),
+				Source:   resource.Source,
+				Message:  r.Message,
+				Exempt:   r.Exempt,
+			}
+			appReport.Policies = append(appReport.Policies, result)
+			if result.Denies() {
+				denials = append(denials, result)
+			} else {
+				logCtx.Warnf("Policy %s: %s", result.Severity, result)
+			}
+		}
+	}
+	if len(denials) > 0 {
+		return &policyError{denials: denials}
+	}
+	return nil
+}
+
+// checkFailures returns an error when applications failed the schema
+// validation or the policies, use removed APIs or render the same resources,
+// or when invalid Application documents were skipped, so that the run exits
+// with an error even though other applications were rendered.
+func (r *runResult) checkFailures() error {
+	if r.report == nil {
+		return nil
+	}
+	var schemaFailed, policyFailed, removedAPIs []string
+	for _, app := range r.report.Applications {
+		if len(app.SchemaErrors) > 0 {
+			schemaFailed = append(schemaFailed, app.Name)
+		}
+		if app.Denied() {
+			policyFailed = append(policyFailed, app.Name)
+		}
+		for _, api := range app.DeprecatedAPIs {
+			if api.Status == report.APIRemoved {
+				removedAPIs = append(removedAPIs, app.Name)
+				break
+			}
+		}
+	}
+	var errs []error
+	if len(schemaFailed) > 0 {
+		errs = append(errs, fmt.Errorf("schema validation failed for %d applications: %s", len(schemaFailed), strings.Join(schemaFailed, ", ")))
+	}
+	if len(policyFailed) > 0 {
+		errs = append(errs, fmt.Errorf("policy checks failed for %d applications: %s", len(policyFailed), strings.Join(policyFailed, ", ")))
+	}
+	if len(removedAPIs) > 0 {
+		errs = append(errs, fmt.Errorf("%d applications use removed Kubernetes APIs: %s", len(removedAPIs), strings.Join(removedAPIs, ", ")))
+	}
+	if len(r.report.Duplicates) > 0 {
+		errs = append(errs, fmt.Errorf("%d resources are rendered by more than one application", len(r.report.Duplicates)))
+	}
+	if len(r.report.InvalidDocuments) > 0 {
+		documents := make(map[int]bool)
+		for _, document := range r.report.InvalidDocuments {
+			documents[document.Document] = true
+		}
+		errs = append(errs, fmt.Errorf("%d invalid Application documents were skipped", len(documents)))
+	}
+	return errors.Join(errs...)
+}
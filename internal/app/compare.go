@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"roar/internal/pkg/diff"
+	"roar/internal/pkg/git"
+	"roar/internal/pkg/logger"
+)
+
+type CompareConfig struct {
+	Render Config
+	Base   string
+	Head   string
+}
+
+// Compare checks out two revisions of the repository that contains the
+// app-of-apps chart, renders both and reports added and removed applications,
+// changed rendering parameters and per-resource manifest diffs.
+func Compare(cfg CompareConfig) (*diff.ChangeSet, error) {
+	chartPath, err := filepath.Abs(cfg.Render.ChartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve chart path %s: %w", cfg.Render.ChartPath, err)
+	}
+	repoRoot, err := git.RepoRoot(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if repoRoot, err = filepath.Abs(repoRoot); err != nil {
+		return nil, fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+
+	scratchDir, err := os.MkdirTemp("", "roar-compare-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	baseFiles, baseResult, err := renderRevision(cfg.Render, repoRoot, cfg.Base, filepath.Join(scratchDir, "base"))
+	if err != nil {
+		return nil, fmt.Errorf("base revision %s: %w", cfg.Base, err)
+	}
+	headFiles, headResult, err := renderRevision(cfg.Render, repoRoot, cfg.Head, filepath.Join(scratchDir, "head"))
+	if err != nil {
+		return nil, fmt.Errorf("head revision %s: %w", cfg.Head, err)
+	}
+
+	// Only failures at the head revision fail the comparison; an application
+	// that is broken at the base revision and fixed at head is reported with
+	// its base error for information.
+	merged := &runResult{failed: headResult.failed, paths: make(map[string]string)}
+	baseFailed := make(map[string]error)
+	for app, err := range baseResult.failed {
+		if _, ok := headResult.failed[app]; !ok {
+			logger.Log.Warnf("Application '%s' failed to render at base revision %s: %v", app, cfg.Base, err)
+			baseFailed[app] = err
+		}
+	}
+	for _, result := range []*runResult{baseResult, headResult} {
+		for app, path := range result.paths {
+			merged.paths[app] = path
+		}
+		merged.skipped = append(merged.skipped, result.skipped...)
+	}
+
+	changes, err := compareOutputs(baseFiles, headFiles, merged)
+	if err != nil {
+		return nil, err
+	}
+	changes.AddBaseErrors(baseFailed)
+
+	baseApps := make(map[string]int, len(baseResult.apps))
+	for i, app := range baseResult.apps {
+		baseApps[app.QualifiedName()] = i
+	}
+	for _, headApp := range headResult.apps {
+		if i, ok := baseApps[headApp.QualifiedName()]; ok {
+			changes.AddParams(headApp.QualifiedName(), diff.CompareParams(baseResult.apps[i], headApp))
+		}
+	}
+	return changes, nil
+}
+
+// renderRevision exports revision of the repository at repoRoot into
+// workDir and runs the full render there. Chart and values file paths inside
+// the repository are mapped into the exported tree.
+func renderRevision(cfg Config, repoRoot, revision, workDir string) (map[string][]byte, *runResult, error) {
+	treeDir := filepath.Join(workDir, "tree")
+	sha, err := git.Export(repoRoot, revision, treeDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Log.Infof("Rendering revision %s (%s)", revision, sha)
+
+	cfg.ChartPath = mapIntoTree(cfg.ChartPath, repoRoot, treeDir)
+	valuesFiles := make([]string, len(cfg.ValuesFiles))
+	for i, file := range cfg.ValuesFiles {
+		valuesFiles[i] = mapIntoTree(file, repoRoot, treeDir)
+	}
+	cfg.ValuesFiles = valuesFiles
+	cfg.OutputDir = filepath.Join(workDir, "output")
+	cfg.Prune = false
+	cfg.PruneDryRun = false
+	cfg.CheckDeprecatedAPIs = false
+	cfg.CheckDuplicates = false
+
+	result, err := run(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := diff.ReadDir(cfg.OutputDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, result, nil
+}
+
+func mapIntoTree(path, repoRoot, treeDir string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(repoRoot, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(treeDir, rel)
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"roar/internal/pkg/diff"
+	"roar/internal/pkg/git"
+	"roar/internal/pkg/logger"
+)
+
+type DiffConfig struct {
+	Render Config
+	// AgainstDir is an existing output directory to compare with.
+	AgainstDir string
+	// AgainstRef, when set, selects a revision of the rendered-manifests
+	// repository at AgainstRepo instead; AgainstPath is the output directory
+	// inside that repository.
+	AgainstRepo string
+	AgainstRef  string
+	AgainstPath string
+}
+
+// Diff renders the app-of-apps chart into a scratch directory and compares the
+// result per application and per resource with a previous output.
+func Diff(cfg DiffConfig) (*diff.ChangeSet, error) {
+	scratchDir, err := os.MkdirTemp("", "roar-diff-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	renderCfg := cfg.Render
+	renderCfg.OutputDir = scratchDir
+	renderCfg.Prune = false
+	renderCfg.PruneDryRun = false
+	// The checks of the whole output are only run by render.
+	renderCfg.CheckDeprecatedAPIs = false
+	renderCfg.CheckDuplicates = false
+	result, err := run(renderCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	headFiles, err := diff.ReadDir(scratchDir)
+	if err != nil {
+		return nil, err
+	}
+	baseFiles, err := readDiffBase(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareOutputs(baseFiles, headFiles, result)
+}
+
+func readDiffBase(cfg DiffConfig) (map[string][]byte, error) {
+	if cfg.AgainstRef != "" {
+		repo := cfg.AgainstRepo
+		if repo == "" {
+			repo = "."
+		}
+		logger.Log.Infof("Reading previous output from %s at %s", path.Join(repo, cfg.AgainstPath), cfg.AgainstRef)
+		return git.ReadFiles(repo, cfg.AgainstRef, cfg.AgainstPath)
+	}
+	if cfg.AgainstDir == "" {
+		return nil, fmt.Errorf("either a directory or a git ref to compare against is required")
+	}
+	logger.Log.Infof("Reading previous output from %s", cfg.AgainstDir)
+	return diff.ReadDir(cfg.AgainstDir)
+}
+
+func compareOutputs(baseFiles, headFiles map[string][]byte, result *runResult) (*diff.ChangeSet, error) {
+	owners, err := diff.Owners(baseFiles)
+	if err != nil {
+		return nil, fmt.Errorf("previous output: %w", err)
+	}
+	headOwners, err := diff.Owners(headFiles)
+	if err != nil {
+		return nil, err
+	}
+	for file, app := range headOwners {
+		owners[file] = app
+	}
+	// Outputs without an index (or of applications that failed now) are
+	// attributed to applications by their layout path.
+	for file := range baseFiles {
+		if _, ok := owners[file]; ok {
+			continue
+		}
+		for app, appPath := range result.paths {
+			if file == appPath || strings.HasPrefix(file, strings.TrimSuffix(appPath, path.Ext(appPath))+"/") {
+				owners[file] = app
+				break
+			}
+		}
+	}
+
+	baseTree, err := diff.LoadTree(baseFiles, owners)
+	if err != nil {
+		return nil, fmt.Errorf("previous output: %w", err)
+	}
+	headTree, err := diff.LoadTree(headFiles, owners)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range result.skipped {
+		delete(baseTree, app)
+		delete(headTree, app)
+	}
+	return diff.Compare(baseTree, headTree, result.failed), nil
+}
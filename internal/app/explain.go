@@ -0,0 +1,292 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/helm"
+	"roar/internal/pkg/output"
+)
+
+// repoPlaceholder stands for the checkout of the Application repository in
+// the printed helm invocation.
+const repoPlaceholder = "$REPO"
+
+type ExplainConfig struct {
+	Render Config
+	App    string
+	// Offline skips cloning the Application repository, so the existence of
+	// the chart and values files is not checked.
+	Offline bool
+}
+
+// explanation is everything Explain prints about one Application.
+type explanation struct {
+	app         argo.Application
+	provenance  argo.Provenance
+	outputFile  string
+	selected    bool
+	kubeVersion string
+	access      repositoryAccess
+	setters     map[string]string
+	sources     map[string]string
+	// checkout is the clone of the Application repository and sha its
+	// commit; both are empty in offline mode.
+	checkout string
+	sha      string
+	warnings []string
+}
+
+// Explain prints how one Application is going to be rendered: where each of
+// its parameters came from, its values files and the helm invocation.
+func Explain(cfg ExplainConfig) error {
+	render := cfg.Render
+	if render.Stdout == nil {
+		render.Stdout = os.Stdout
+	}
+	layout, err := output.NewLayout(render.OutputDir, render.Layout)
+	if err != nil {
+		return err
+	}
+	matcher, err := render.Filter.Compile()
+	if err != nil {
+		return err
+	}
+
+	manifests, err := renderAppOfApps(render.ChartPath, render.ValuesFiles)
+	if err != nil {
+		return fmt.Errorf("initialization failed: %w", err)
+	}
+	applications, provenances, err := argo.ExplainApplications(manifests, render.Applications)
+	if err != nil {
+		return fmt.Errorf("failed to parse Argo applications: %w", err)
+	}
+	// Problems of other Applications do not matter here, those of the
+	// explained one are shown as warnings.
+	problems, err := argo.ValidateApplications(manifests, render.Applications)
+	if err != nil {
+		return fmt.Errorf("failed to parse Argo applications: %w", err)
+	}
+
+	i, err := findApplication(applications, cfg.App)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, render.ChartPath)
+	}
+	app := applications[i]
+	access, err := newRepositoryAccess(render)
+	if err != nil {
+		return err
+	}
+	e := &explanation{app: app, provenance: provenances[i], selected: matcher.Matches(app), kubeVersion: render.KubeVersion, access: access}
+	if e.outputFile, err = layout.Path(app); err != nil {
+		return err
+	}
+	e.resolveSetters()
+	e.warnings = append(e.warnings, e.provenance.Warnings...)
+	for _, p := range problems {
+		if p.Application == app.QualifiedName() {
+			e.warnings = append(e.warnings, p.String())
+		}
+	}
+
+	if !cfg.Offline {
+		tempDir, err := os.MkdirTemp("", "argo-charts-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		if err := e.clone(tempDir); err != nil {
+			e.warnings = append(e.warnings, err.Error())
+		}
+	}
+	return e.write(render.Stdout)
+}
+
+// findApplication returns the index of the Application with the qualified
+// name, or with the bare name if that is unambiguous.
+func findApplication(applications []argo.Application, name string) (int, error) {
+	var matches []int
+	for i, app := range applications {
+		if app.QualifiedName() == name {
+			return i, nil
+		}
+		if app.Name == name {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("application '%s' not found", name)
+	case 1:
+		return matches[0], nil
+	}
+	var names []string
+	for _, i := range matches {
+		names = append(names, applications[i].QualifiedName())
+	}
+	return 0, fmt.Errorf("application name '%s' is ambiguous, use one of %s", name, strings.Join(names, ", "))
+}
+
+// resolveSetters computes the --set parameters renderApplication passes to
+// helm and where each of them came from, without modifying the Application.
+func (e *explanation) resolveSetters() {
+	e.setters = make(map[string]string, len(e.app.Setters)+2)
+	e.sources = make(map[string]string, len(e.app.Setters)+2)
+	for key, value := range e.app.Setters {
+		e.setters[key] = value
+		e.sources[key] = e.provenance.Setters[key]
+	}
+	inject := func(key, field, value string) {
+		if value == "" {
+			return
+		}
+		if old, ok := e.setters[key]; ok && old != value {
+			e.warnings = append(e.warnings, fmt.Sprintf("%s=%s from %s is overridden by the injected %s", key, old, e.sources[key], field))
+		}
+		e.setters[key] = value
+		e.sources[key] = fmt.Sprintf("injected from %s (%s)", field, e.provenance.Fields[field])
+	}
+	inject("global.instance", "instance", e.app.Instance)
+	inject("global.env", "env", e.app.Env)
+}
+
+// clone clones the Application repository into dir so that the chart and the
+// values files can be checked.
+func (e *explanation) clone(dir string) error {
+	cloneURL, err := e.access.cloneURL(e.app.RepoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repo URL '%s': %w", e.app.RepoURL, err)
+	}
+	sha, err := e.access.clone(cloneURL, e.app.TargetRevision, dir)
+	if err != nil {
+		return fmt.Errorf("could not clone the repository, files were not checked: %w", err)
+	}
+	e.checkout, e.sha = dir, sha
+
+	if !e.exists(e.chartPath()) {
+		e.warnings = append(e.warnings, fmt.Sprintf("chart %s does not exist", e.chartPath()))
+	}
+	for _, file := range e.valuesFiles() {
+		if !e.exists(file) {
+			e.warnings = append(e.warnings, fmt.Sprintf("values file %s does not exist", file))
+		}
+	}
+	return nil
+}
+
+func (e *explanation) chartPath() string {
+	return path.Join(filepath.ToSlash(e.app.Path), ".helm")
+}
+
+// valuesFiles returns the values files relative to the repository root.
+func (e *explanation) valuesFiles() []string {
+	files := make([]string, len(e.app.ValuesFiles))
+	for i, file := range e.app.ValuesFiles {
+		files[i] = path.Join(filepath.ToSlash(e.app.Path), filepath.ToSlash(file))
+	}
+	return files
+}
+
+func (e *explanation) exists(repoPath string) bool {
+	_, err := os.Stat(filepath.Join(e.checkout, filepath.FromSlash(repoPath)))
+	return err == nil
+}
+
+func (e *explanation) fileStatus(repoPath string) string {
+	switch {
+	case e.checkout == "":
+		return "not checked"
+	case e.exists(repoPath):
+		return "found"
+	default:
+		return "MISSING"
+	}
+}
+
+func (e *explanation) write(w io.Writer) error {
+	app := e.app
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Application:\t%s\t\n", app.QualifiedName())
+	fmt.Fprintf(tw, "Env:\t%s\t%s\n", dash(app.Env), e.provenance.Fields["env"])
+	fmt.Fprintf(tw, "Instance:\t%s\t%s\n", dash(app.Instance), e.provenance.Fields["instance"])
+	repoURL := app.RepoURL
+	if cloneURL, err := e.access.cloneURL(app.RepoURL); err == nil && cloneURL != app.RepoURL {
+		repoURL += " (cloned as " + cloneURL + ")"
+	}
+	fmt.Fprintf(tw, "Repository:\t%s\t%s\n", repoURL, e.provenance.Fields["repoURL"])
+	fmt.Fprintf(tw, "Revision:\t%s\t%s\n", dash(app.TargetRevision), e.provenance.Fields["targetRevision"])
+	if e.sha != "" {
+		fmt.Fprintf(tw, "Commit:\t%s\t\n", e.sha)
+	}
+	fmt.Fprintf(tw, "Path:\t%s\t%s\n", app.Path, e.provenance.Fields["path"])
+	fmt.Fprintf(tw, "Chart:\t%s/%s\t%s\n", repoPlaceholder, e.chartPath(), e.fileStatus(e.chartPath()))
+	fmt.Fprintf(tw, "Output:\t%s\t\n", e.outputFile)
+	fmt.Fprintf(tw, "Selected:\t%t\t\n", e.selected)
+
+	fmt.Fprintln(tw, "\nValues files:")
+	if len(app.ValuesFiles) == 0 {
+		fmt.Fprintln(tw, "  (none)")
+	}
+	for i, file := range e.valuesFiles() {
+		fmt.Fprintf(tw, "  %d. %s/%s\t%s\t%s\n", i+1, repoPlaceholder, file, e.fileStatus(file), e.provenance.ValuesFiles[i])
+	}
+
+	fmt.Fprintln(tw, "\nParameters:")
+	keys := make([]string, 0, len(e.setters))
+	for key := range e.setters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		fmt.Fprintln(tw, "  (none)")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(tw, "  %s=%s\t%s\t\n", key, e.setters[key], e.sources[key])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	opts := helm.RenderOptions{
+		ReleaseName: app.Name,
+		ChartPath:   repoPlaceholder + "/" + e.chartPath(),
+		SetValues:   e.setters,
+		KubeVersion: e.kubeVersion,
+	}
+	for _, file := range e.valuesFiles() {
+		opts.ValuesFiles = append(opts.ValuesFiles, repoPlaceholder+"/"+file)
+	}
+	fmt.Fprintf(w, "\nHelm invocation (%s is the checkout of %s at %s):\n", repoPlaceholder, app.RepoURL, dash(app.TargetRevision))
+	fmt.Fprintf(w, "  helm %s\n", shellJoin(helm.Args(opts)))
+
+	if len(e.warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range e.warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+	return nil
+}
+
+// shellJoin quotes args for a POSIX shell where needed. The repository
+// placeholder is left unquoted so the printed command can be used after
+// setting REPO.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		rest := strings.TrimPrefix(arg, repoPlaceholder+"/")
+		if rest != "" && strings.Trim(rest, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@+%") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
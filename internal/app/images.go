@@ -0,0 +1,129 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/images"
+	"roar/internal/pkg/logger"
+	"roar/internal/pkg/manifest"
+	"roar/internal/pkg/output"
+)
+
+type ImagesConfig struct {
+	Render Config
+	// Paths are additional KIND=JSONPATH image locations, for custom
+	// resources that run containers.
+	Paths  []string
+	Format string
+	// FlagMutable marks the images that are not pinned by digest.
+	FlagMutable bool
+	// OutputFile is the file the inventory is written to; stdout when empty
+	// or "-".
+	OutputFile string
+}
+
+// Images renders the app-of-apps chart into a scratch directory and writes
+// the inventory of the container images used by the selected Applications.
+// Applications that fail to render are missing from the inventory and make
+// Images return an error after it is written.
+func Images(cfg ImagesConfig) error {
+	if cfg.Format != images.FormatJSON && cfg.Format != images.FormatCSV {
+		return fmt.Errorf("unknown inventory format %q, expected json or csv", cfg.Format)
+	}
+	extractor, err := images.NewExtractor(cfg.Paths)
+	if err != nil {
+		return err
+	}
+
+	scratchDir, err := os.MkdirTemp("", "roar-images-*")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	renderCfg := cfg.Render
+	renderCfg.OutputDir = scratchDir
+	renderCfg.Prune = false
+	renderCfg.PruneDryRun = false
+	renderCfg.CheckDeprecatedAPIs = false
+	renderCfg.CheckDuplicates = false
+	result, err := run(renderCfg)
+	if err != nil {
+		return err
+	}
+
+	inventory := &images.Inventory{FlagMutable: cfg.FlagMutable}
+	for _, app := range result.report.Applications {
+		if app.Skipped || app.Error != "" || result.failed[app.Name] != nil {
+			continue
+		}
+		var found []images.Image
+		for _, file := range app.OutputFiles {
+			if filepath.Base(file) == output.KustomizationFile {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read rendered file %s: %w", file, err)
+			}
+			resources, err := manifest.Parse(data)
+			if err != nil {
+				return fmt.Errorf("failed to parse rendered file %s: %w", file, err)
+			}
+			for _, resource := range resources {
+				extracted, err := extractor.Extract(resource)
+				if err != nil {
+					return fmt.Errorf("application %s: %w", app.Name, err)
+				}
+				found = append(found, extracted...)
+			}
+		}
+		inventory.Add(app.Env, app.Instance, app.Name, found)
+	}
+
+	if err := writeInventory(cfg, inventory); err != nil {
+		return err
+	}
+	if cfg.FlagMutable {
+		if n := inventory.Mutable(); n > 0 {
+			logger.Log.Warnf("%d images are not pinned by digest.", n)
+		}
+	}
+	var errs []error
+	if len(result.failed) > 0 {
+		var failed []string
+		for name := range result.failed {
+			failed = append(failed, name)
+		}
+		sort.Strings(failed)
+		errs = append(errs, fmt.Errorf("%d applications could not be rendered and are missing from the inventory: %s", len(failed), strings.Join(failed, ", ")))
+	}
+	if n := len(result.report.InvalidDocuments); n > 0 {
+		errs = append(errs, fmt.Errorf("%d problems in skipped Application documents, their Applications are missing from the inventory", n))
+	}
+	return errors.Join(errs...)
+}
+
+func writeInventory(cfg ImagesConfig, inventory *images.Inventory) error {
+	if cfg.OutputFile == "" || cfg.OutputFile == "-" {
+		w := cfg.Render.Stdout
+		if w == nil {
+			w = os.Stdout
+		}
+		return inventory.Write(w, cfg.Format)
+	}
+	var buf bytes.Buffer
+	if err := inventory.Write(&buf, cfg.Format); err != nil {
+		return err
+	}
+	if err := os.WriteFile(cfg.OutputFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write image inventory to %s: %w", cfg.OutputFile, err)
+	}
+	return nil
+}
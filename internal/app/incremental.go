@@ -0,0 +1,253 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/git"
+	"roar/internal/pkg/logger"
+	"roar/internal/pkg/output"
+)
+
+// incremental decides which applications have to be rendered again when only
+// a known set of files changed since the previous run.
+type incremental struct {
+	enabled         bool
+	changed         []string
+	outputDir       string
+	previous        *output.Index
+	settingsChanged bool
+	// repositories identifies the repository of the app-of-apps chart, which
+	// the changed files belong to. Applications from other repositories are
+	// compared by the commit their branch points to, found with resolve.
+	repositories map[string]bool
+	cloneURL     func(repoURL string) (string, error)
+	resolve      func(repoURL, revision string) (string, error)
+	resolved     map[string]resolvedBranch
+}
+
+type resolvedBranch struct {
+	sha string
+	err error
+}
+
+func newIncremental(cfg Config, previous *output.Index, settings string, access repositoryAccess) (*incremental, error) {
+	inc := &incremental{
+		outputDir:    cfg.OutputDir,
+		previous:     previous,
+		repositories: make(map[string]bool),
+		cloneURL:     access.cloneURL,
+		resolve:      access.resolveBranch,
+		resolved:     make(map[string]resolvedBranch),
+	}
+	if len(cfg.ChangedFiles) == 0 && cfg.ChangedBase == "" {
+		return inc, nil
+	}
+	inc.enabled = true
+	inc.settingsChanged = previous.Settings != settings
+
+	repoRoot, err := git.RepoRoot(cfg.ChartPath)
+	if err == nil {
+		inc.repositories[repositoryKey(repoRoot)] = true
+		urls, err := git.RemoteURLs(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, url := range urls {
+			inc.repositories[repositoryKey(url)] = true
+		}
+	} else {
+		logger.Log.Debugf("The app-of-apps chart is not in a git repository, changed files are not matched: %v", err)
+	}
+
+	changed := append([]string{}, cfg.ChangedFiles...)
+	if cfg.ChangedBase != "" {
+		if err != nil {
+			return nil, err
+		}
+		head := cfg.ChangedHead
+		if head == "" {
+			head = "HEAD"
+		}
+		files, err := git.ChangedFiles(repoRoot, cfg.ChangedBase, head)
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.Infof("Found %d changed files between %s and %s", len(files), cfg.ChangedBase, head)
+		changed = append(changed, files...)
+	}
+	for _, file := range changed {
+		if file = cleanRepoPath(file); file != "" {
+			inc.changed = append(inc.changed, file)
+		}
+	}
+	return inc, nil
+}
+
+// needsRender reports whether app has to be rendered and why. definition is
+// the fingerprint of the Application as parsed from the app-of-apps chart, so
+// any change of its inputs in the app-of-apps chart is detected through it.
+func (inc *incremental) needsRender(app argo.Application, definition string) (bool, string) {
+	if !inc.enabled {
+		return true, ""
+	}
+	if inc.settingsChanged {
+		return true, "output settings changed"
+	}
+	if inc.previous.Definitions[app.QualifiedName()] != definition {
+		return true, "Application definition changed"
+	}
+	files := inc.previous.Applications[app.QualifiedName()]
+	if len(files) == 0 {
+		return true, "no previous output"
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(inc.outputDir, filepath.FromSlash(file))); err != nil {
+			return true, "previous output is missing"
+		}
+	}
+	if !inc.repositories[repositoryKey(app.RepoURL)] {
+		return inc.revisionChanged(app)
+	}
+
+	serviceDir := cleanRepoPath(app.Path)
+	for _, file := range inc.changed {
+		if serviceDir == "" || file == serviceDir || strings.HasPrefix(file, serviceDir+"/") {
+			return true, "service directory changed: " + file
+		}
+		for _, valuesFile := range app.ValuesFiles {
+			if file == cleanRepoPath(path.Join(serviceDir, valuesFile)) {
+				return true, "values file changed: " + file
+			}
+		}
+	}
+	return false, ""
+}
+
+// revisionChanged compares the commit the branch of an Application from
+// another repository points to with the commit its previous output was
+// rendered from; the changed files say nothing about such repositories.
+func (inc *incremental) revisionChanged(app argo.Application) (bool, string) {
+	previous := inc.previous.Revisions[app.QualifiedName()]
+	if previous == "" {
+		return true, "no previous revision"
+	}
+	repoURL, err := inc.cloneURL(app.RepoURL)
+	if err != nil {
+		return true, fmt.Sprintf("invalid repo URL: %v", err)
+	}
+	key := repoURL + "@" + app.TargetRevision
+	branch, ok := inc.resolved[key]
+	if !ok {
+		branch.sha, branch.err = inc.resolve(repoURL, app.TargetRevision)
+		inc.resolved[key] = branch
+	}
+	if branch.err != nil {
+		return true, fmt.Sprintf("could not resolve %s: %v", app.TargetRevision, branch.err)
+	}
+	if branch.sha != previous {
+		return true, fmt.Sprintf("%s moved from %s to %s", app.TargetRevision, shortSHA(previous), shortSHA(branch.sha))
+	}
+	return false, ""
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+// repositoryKey normalizes a repository URL or local path, so that the
+// HTTPS, SSH and scp-like URLs of a repository compare equal.
+func repositoryKey(repo string) string {
+	repo = strings.TrimSpace(repo)
+	if filepath.IsAbs(repo) {
+		return filepath.Clean(repo)
+	}
+	if sshURL, err := convertHTTPtoSSH(repo); err == nil {
+		repo = sshURL
+	}
+	if u, err := url.Parse(repo); err == nil && u.Scheme == "ssh" {
+		repo = u.Hostname() + ":" + strings.TrimPrefix(u.Path, "/")
+		if u.User != nil {
+			repo = u.User.Username() + "@" + repo
+		}
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+}
+
+// cleanRepoPath normalizes a repository-relative path; the repository root is
+// returned as an empty string.
+func cleanRepoPath(p string) string {
+	p = path.Clean("/" + filepath.ToSlash(strings.TrimSpace(p)))
+	return strings.TrimPrefix(p, "/")
+}
+
+func fingerprint(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// filesFingerprint hashes the names and contents of the .yaml and .yml files
+// in dirs and their subdirectories, the files schemas and policies are loaded
+// from. Unreadable files are left out: loading them fails the run anyway.
+func filesFingerprint(dirs []string) string {
+	h := sha256.New()
+	for _, dir := range dirs {
+		_ = filepath.WalkDir(dir, func(file string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			ext := filepath.Ext(file)
+			if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				return nil
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(file), len(data))
+			h.Write(data)
+			return nil
+		})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// settingsFingerprint covers every option that changes the written output
+// independently of the applications themselves, including the checks that
+// decide whether it is written at all and the schema and policy files they
+// use.
+func settingsFingerprint(cfg Config) string {
+	return fingerprint(struct {
+		Version       string
+		KubeVersion   string
+		Layout        string
+		OutputMode    string
+		Kustomization bool
+		Normalize     bool
+		StripLabels   []string
+		StripAnnots   []string
+		Schemas       bool
+		SchemaDirs    []string
+		SchemaFiles   string
+		PolicyDirs    []string
+		PolicyFiles   string
+		CheckAPIs     bool
+		APITarget     string
+		SyncOrder     bool
+	}{cfg.Version, cfg.KubeVersion, cfg.Layout, cfg.OutputMode, cfg.Kustomization, cfg.Normalize, cfg.NormalizeOpts.StripLabels, cfg.NormalizeOpts.StripAnnotations, cfg.ValidateSchemas, cfg.SchemaDirs, filesFingerprint(cfg.SchemaDirs), cfg.PolicyDirs, filesFingerprint(cfg.PolicyDirs), cfg.CheckDeprecatedAPIs, cfg.TargetKubeVersion, cfg.SyncOrder})
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalNeedsRender(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "api.yaml"), []byte("kind: A\n"), 0644))
+
+	app := argo.Application{
+		Name:        "api",
+		RepoURL:     "https://git.example.com/team/gitops.git",
+		Path:        "stable/api",
+		ValuesFiles: []string{".helm/values.yaml", "../shared/values-dev.yaml"},
+	}
+	definition := fingerprint(app)
+	settings := settingsFingerprint(Config{})
+
+	previous := output.NewIndex()
+	previous.Settings = settings
+	previous.Applications["api"] = []string{"api.yaml"}
+	previous.Definitions["api"] = definition
+
+	tests := []struct {
+		name       string
+		changed    []string
+		settings   string
+		definition string
+		previous   []string
+		want       bool
+	}{
+		{name: "unrelated change", changed: []string{"stable/web/.helm/values.yaml", "README.md"}, want: false},
+		{name: "chart file changed", changed: []string{"stable/api/.helm/templates/deployment.yaml"}, want: true},
+		{name: "values file outside the service directory", changed: []string{"./stable/shared/values-dev.yaml"}, want: true},
+		{name: "other values file in shared directory", changed: []string{"stable/shared/values-prod.yaml"}, want: false},
+		{name: "prefix of another service", changed: []string{"stable/api-gateway/Chart.yaml"}, want: false},
+		{name: "definition changed", definition: "other", want: true},
+		{name: "settings changed", settings: "other", want: true},
+		{name: "previous output is missing", previous: []string{"gone.yaml"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := output.NewIndex()
+			prev.Settings = previous.Settings
+			prev.Applications["api"] = previous.Applications["api"]
+			prev.Definitions["api"] = previous.Definitions["api"]
+			if tt.previous != nil {
+				prev.Applications["api"] = tt.previous
+			}
+
+			currentSettings := settings
+			if tt.settings != "" {
+				currentSettings = tt.settings
+			}
+			currentDefinition := definition
+			if tt.definition != "" {
+				currentDefinition = tt.definition
+			}
+
+			inc, err := newIncremental(Config{OutputDir: outputDir, ChangedFiles: append([]string{"docs/unrelated.md"}, tt.changed...)}, prev, currentSettings, repositoryAccess{})
+			require.NoError(t, err)
+			inc.repositories = map[string]bool{repositoryKey("git@git.example.com:team/gitops"): true}
+			got, reason := inc.needsRender(app, currentDefinition)
+			require.Equal(t, tt.want, got, reason)
+		})
+	}
+}
+
+func TestSettingsFingerprintFiles(t *testing.T) {
+	policyDir := t.TempDir()
+	policyFile := filepath.Join(policyDir, "org.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte("policies: []\n"), 0644))
+	cfg := Config{PolicyDirs: []string{policyDir}}
+
+	before := settingsFingerprint(cfg)
+	require.NoError(t, os.WriteFile(filepath.Join(policyDir, "README.md"), []byte("notes\n"), 0644))
+	require.Equal(t, before, settingsFingerprint(cfg))
+
+	require.NoError(t, os.WriteFile(policyFile, []byte("policies:\n  - name: x\n    rule: \"true\"\n"), 0644))
+	require.NotEqual(t, before, settingsFingerprint(cfg))
+}
+
+func TestIncrementalDisabled(t *testing.T) {
+	inc, err := newIncremental(Config{}, output.NewIndex(), "", repositoryAccess{})
+	require.NoError(t, err)
+	got, _ := inc.needsRender(argo.Application{Name: "api"}, "")
+	require.True(t, got)
+}
+
+func TestIncrementalOtherRepository(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "api.yaml"), []byte("kind: A\n"), 0644))
+
+	app := argo.Application{Name: "api", RepoURL: "https://git.example.com/team/api.git", TargetRevision: "main", Path: "stable/api"}
+	previous := output.NewIndex()
+	previous.Applications["api"] = []string{"api.yaml"}
+	previous.Definitions["api"] = fingerprint(app)
+	previous.Revisions["api"] = "1111111111"
+
+	tests := []struct {
+		name    string
+		changed []string
+		head    string
+		err     error
+		want    bool
+	}{
+		// The changed files belong to the app-of-apps repository, not to the
+		// repository of the Application.
+		{name: "same path changed in the app-of-apps repository", changed: []string{"stable/api/values.yaml"}, head: "1111111111", want: false},
+		{name: "branch moved", changed: []string{"README.md"}, head: "2222222222", want: true},
+		{name: "branch cannot be resolved", changed: []string{"README.md"}, err: errors.New("unreachable"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inc, err := newIncremental(Config{OutputDir: outputDir, ChangedFiles: tt.changed}, previous, "", repositoryAccess{})
+			require.NoError(t, err)
+			inc.repositories = map[string]bool{repositoryKey("git@git.example.com:team/gitops.git"): true}
+			var resolved []string
+			inc.resolve = func(repoURL, revision string) (string, error) {
+				resolved = append(resolved, repoURL+"@"+revision)
+				return tt.head, tt.err
+			}
+			got, reason := inc.needsRender(app, fingerprint(app))
+			require.Equal(t, tt.want, got, reason)
+			require.Equal(t, []string{"git@git.example.com:team/api.git@main"}, resolved)
+		})
+	}
+}
+
+func TestRepositoryKey(t *testing.T) {
+	for _, repo := range []string{
+		"https://git.example.com/team/gitops.git",
+		"https://git.example.com/team/gitops",
+		"git@git.example.com:team/gitops.git",
+		"ssh://git@git.example.com:22/team/gitops.git",
+	} {
+		require.Equal(t, "git@git.example.com:team/gitops", repositoryKey(repo), repo)
+	}
+	require.Equal(t, "/srv/git/gitops", repositoryKey("/srv/git/gitops/"))
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"roar/internal/pkg/argo"
+)
+
+// listApplications prints the applications selected by matcher without
+// cloning or rendering anything.
+func listApplications(w io.Writer, apps []argo.Application, matcher *argo.Matcher) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tREPO\tPATH\tREVISION\tENV\tINSTANCE")
+	for _, app := range apps {
+		if !matcher.Matches(app) {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", app.QualifiedName(), app.RepoURL, app.Path, app.TargetRevision, dash(app.Env), dash(app.Instance))
+	}
+	return tw.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"roar/internal/pkg/logger"
+	"roar/internal/pkg/output"
+)
+
+// pruneStaleFiles compares the files produced by this run with the index of
+// the previous run and, when pruning is enabled, removes files that are no
+// longer generated. Files that are not removed stay in the index so a later
+// --prune run can still clean them up.
+func pruneStaleFiles(cfg Config, previous, current *output.Index) error {
+	stale := current.Stale(previous)
+
+	apps := make([]string, 0, len(stale))
+	for app := range stale {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+
+	for _, app := range apps {
+		files := stale[app]
+		sort.Strings(files)
+		logCtx := logger.Log.WithField("application", app)
+		switch {
+		case cfg.PruneDryRun:
+			for _, file := range files {
+				fmt.Fprintf(cfg.Stdout, "would prune %s (application %s)\n", file, app)
+			}
+			current.Applications[app] = append(current.Applications[app], files...)
+		case cfg.Prune:
+			for _, file := range files {
+				logCtx.Infof("Pruning stale file %s", file)
+			}
+			if err := output.Prune(cfg.OutputDir, files); err != nil {
+				return err
+			}
+		default:
+			logCtx.Debugf("Found %d stale files; run with --prune to remove them.", len(files))
+			current.Applications[app] = append(current.Applications[app], files...)
+		}
+	}
+
+	return current.Write(cfg.OutputDir)
+}
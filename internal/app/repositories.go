@@ -0,0 +1,53 @@
+package app
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/git"
+)
+
+// SSHKeyPassphraseEnv is the environment variable with the passphrase of
+// Config.SSHKeyFile; secrets are not taken from flags or the config file.
+const SSHKeyPassphraseEnv = "ROAR_SSH_KEY_PASSPHRASE"
+
+// repositoryAccess is how Application repositories are cloned: the URL
+// rewrites and the credentials.
+type repositoryAccess struct {
+	rewrites map[string]string
+	auth     git.Auth
+}
+
+func newRepositoryAccess(cfg Config) (repositoryAccess, error) {
+	access := repositoryAccess{
+		rewrites: cfg.RepoRewrites,
+		auth:     git.Auth{SSHKeyFile: cfg.SSHKeyFile, SSHKeyPassphrase: os.Getenv(SSHKeyPassphraseEnv)},
+	}
+	return access, access.auth.Check()
+}
+
+// cloneURL returns the URL an Application repository is cloned from. The
+// longest matching prefix of RepoRewrites is replaced and the result is used
+// as is; URLs without a rewrite are cloned over SSH.
+func (a repositoryAccess) cloneURL(repoURL string) (string, error) {
+	prefixes := make([]string, 0, len(a.rewrites))
+	for prefix := range a.rewrites {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(repoURL, prefix) {
+			return a.rewrites[prefix] + strings.TrimPrefix(repoURL, prefix), nil
+		}
+	}
+	return convertHTTPtoSSH(repoURL)
+}
+
+func (a repositoryAccess) clone(repoURL, revision, dir string) (string, error) {
+	return git.Clone(repoURL, revision, dir, a.auth)
+}
+
+func (a repositoryAccess) resolveBranch(repoURL, revision string) (string, error) {
+	return git.ResolveBranch(repoURL, revision, a.auth)
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryAccessCloneURL(t *testing.T) {
+	access := repositoryAccess{rewrites: map[string]string{
+		"https://github.com/":         "https://mirror.example.com/github/",
+		"https://github.com/my-org/":  "/srv/git/my-org/",
+		"https://gitlab.com/internal": "git@gitlab.internal:internal",
+	}}
+
+	tests := []struct {
+		name     string
+		inputURL string
+		wantURL  string
+	}{
+		{name: "rewritten", inputURL: "https://github.com/other/repo.git", wantURL: "https://mirror.example.com/github/other/repo.git"},
+		{name: "longest prefix wins", inputURL: "https://github.com/my-org/repo.git", wantURL: "/srv/git/my-org/repo.git"},
+		{name: "rewritten to ssh", inputURL: "https://gitlab.com/internal/repo.git", wantURL: "git@gitlab.internal:internal/repo.git"},
+		{name: "no rewrite is cloned over ssh", inputURL: "https://gitlab.com/team/repo.git", wantURL: "git@gitlab.com:team/repo.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotURL, err := access.cloneURL(tt.inputURL)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantURL, gotURL)
+		})
+	}
+}
+
+func TestNewRepositoryAccessMissingKey(t *testing.T) {
+	_, err := newRepositoryAccess(Config{SSHKeyFile: "/nonexistent/id_ed25519"})
+	require.ErrorContains(t, err, "failed to load SSH key")
+}
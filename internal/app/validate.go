@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/output"
+)
+
+// Validate renders the app-of-apps chart and checks that its Applications can
+// be parsed, selected and placed in the output layout, without cloning or
+// rendering the Applications themselves. In lenient mode all documents are
+// checked and the problems of the skipped ones are returned together.
+// Warnings are printed and do not fail the validation.
+func Validate(cfg Config) error {
+	if cfg.Stdout == nil {
+		cfg.Stdout = os.Stdout
+	}
+	layout, err := output.NewLayout(cfg.OutputDir, cfg.Layout)
+	if err != nil {
+		return err
+	}
+	outputMode, err := output.ParseMode(cfg.OutputMode)
+	if err != nil {
+		return err
+	}
+	matcher, err := cfg.Filter.Compile()
+	if err != nil {
+		return err
+	}
+
+	applications, problems, err := renderAndParseAppOfApps(cfg)
+	if err != nil {
+		return fmt.Errorf("initialization failed: %w", err)
+	}
+	invalid, warnings := argo.SplitWarnings(problems)
+	if _, err := layout.Resolve(applications, outputMode); err != nil {
+		return fmt.Errorf("failed to resolve output layout: %w", err)
+	}
+
+	selected := 0
+	for _, app := range applications {
+		if matcher.Matches(app) {
+			selected++
+		}
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(cfg.Stdout, warning)
+	}
+	fmt.Fprintf(cfg.Stdout, "%d Applications are valid, %d selected\n", len(applications), selected)
+	if len(invalid) > 0 {
+		return &argo.ValidationError{Problems: invalid}
+	}
+	return nil
+}
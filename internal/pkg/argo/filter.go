@@ -0,0 +1,88 @@
+package argo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter selects which applications are rendered. Every non-empty criterion
+// must match; within a criterion any of the listed values may match.
+type Filter struct {
+	// Apps and Repos are glob patterns where `*` matches any sequence of
+	// characters (including `/`) and `?` matches a single character. Apps
+	// match the name or the qualified name of an Application.
+	Apps      []string
+	Repos     []string
+	Envs      []string
+	Instances []string
+	Selector  string
+}
+
+// Matcher is a compiled Filter.
+type Matcher struct {
+	apps      []*regexp.Regexp
+	repos     []*regexp.Regexp
+	envs      []string
+	instances []string
+	selector  Selector
+}
+
+func (f Filter) IsEmpty() bool {
+	return len(f.Apps) == 0 && len(f.Repos) == 0 && len(f.Envs) == 0 && len(f.Instances) == 0 && strings.TrimSpace(f.Selector) == ""
+}
+
+func (f Filter) Compile() (*Matcher, error) {
+	selector, err := ParseSelector(f.Selector)
+	if err != nil {
+		return nil, err
+	}
+	compiled := &Matcher{envs: f.Envs, instances: f.Instances, selector: selector}
+	if compiled.apps, err = compileGlobs(f.Apps); err != nil {
+		return nil, err
+	}
+	if compiled.repos, err = compileGlobs(f.Repos); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+func (c *Matcher) Matches(app Application) bool {
+	if len(c.apps) > 0 && !matchesAnyGlob(c.apps, app.Name) && !matchesAnyGlob(c.apps, app.QualifiedName()) {
+		return false
+	}
+	if len(c.repos) > 0 && !matchesAnyGlob(c.repos, app.RepoURL) {
+		return false
+	}
+	if len(c.envs) > 0 && !contains(c.envs, app.Env) {
+		return false
+	}
+	if len(c.instances) > 0 && !contains(c.instances, app.Instance) {
+		return false
+	}
+	return c.selector.Matches(app.Labels)
+}
+
+func compileGlobs(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, `.*`)
+		expr = strings.ReplaceAll(expr, `\?`, `.`)
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func matchesAnyGlob(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package argo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelector(t *testing.T) {
+	labels := map[string]string{"env": "dev", "team": "core", "app.kubernetes.io/part-of": "shop", "flags": "a=b"}
+
+	testCases := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"env=dev", true},
+		{"env==dev", true},
+		{"env=prod", false},
+		{"env!=prod", true},
+		{"missing!=x", true},
+		{"team in (core, infra)", true},
+		{"team notin (core,infra)", false},
+		{"team in (infra)", false},
+		{"team in(core)", true},
+		{"flags in (a=b)", true},
+		{"flags notin (a=b,c)", false},
+		{"team notin (x=y)", true},
+		{"app.kubernetes.io/part-of", true},
+		{"!legacy", true},
+		{"!team", false},
+		{"env=dev,team in (core,infra),!legacy", true},
+		{"env=dev,team=infra", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.selector, func(t *testing.T) {
+			selector, err := ParseSelector(tc.selector)
+			require.NoError(t, err)
+			require.Equal(t, tc.matches, selector.Matches(labels))
+		})
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, s := range []string{"team in core", "team in ()", "bad key=x", "-x=y"} {
+		_, err := ParseSelector(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	apps := []Application{
+		{Name: "dev-inf1-frontend", Env: "dev", Instance: "inf1", RepoURL: "https://gitlab.com/org/frontend.git", Labels: map[string]string{"team": "web"}},
+		{Name: "dev-inf2-backend", Env: "dev", Instance: "inf2", RepoURL: "https://gitlab.com/org/backend.git", Labels: map[string]string{"team": "core"}},
+		{Name: "prod-inf1-backend", Env: "prod", Instance: "inf1", RepoURL: "https://gitlab.com/org/backend.git"},
+	}
+
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"empty filter", Filter{}, []string{"dev-inf1-frontend", "dev-inf2-backend", "prod-inf1-backend"}},
+		{"app glob", Filter{Apps: []string{"*-backend"}}, []string{"dev-inf2-backend", "prod-inf1-backend"}},
+		{"several app globs", Filter{Apps: []string{"dev-inf1-*", "prod-*"}}, []string{"dev-inf1-frontend", "prod-inf1-backend"}},
+		{"env and instance", Filter{Envs: []string{"dev"}, Instances: []string{"inf2"}}, []string{"dev-inf2-backend"}},
+		{"repo glob", Filter{Repos: []string{"*/org/frontend*"}}, []string{"dev-inf1-frontend"}},
+		{"selector", Filter{Selector: "team"}, []string{"dev-inf1-frontend", "dev-inf2-backend"}},
+		{"combined", Filter{Apps: []string{"*backend"}, Selector: "!team"}, []string{"prod-inf1-backend"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matcher, err := tc.filter.Compile()
+			require.NoError(t, err)
+			var selected []string
+			for _, app := range apps {
+				if matcher.Matches(app) {
+					selected = append(selected, app.Name)
+				}
+			}
+			require.Equal(t, tc.expected, selected)
+		})
+	}
+}
+
+func TestFilter_QualifiedNames(t *testing.T) {
+	apps := []Application{{Name: "web"}, {Name: "web", Namespace: "team-a"}, {Name: "api", Namespace: "team-a"}}
+
+	testCases := []struct {
+		apps     []string
+		expected []string
+	}{
+		{[]string{"web"}, []string{"web", "team-a/web"}},
+		{[]string{"team-a/web"}, []string{"team-a/web"}},
+		{[]string{"team-a/*"}, []string{"team-a/web", "team-a/api"}},
+	}
+
+	for _, tc := range testCases {
+		matcher, err := Filter{Apps: tc.apps}.Compile()
+		require.NoError(t, err)
+		var selected []string
+		for _, app := range apps {
+			if matcher.Matches(app) {
+				selected = append(selected, app.QualifiedName())
+			}
+		}
+		require.Equal(t, tc.expected, selected, tc.apps)
+	}
+}
@@ -1,21 +1,25 @@
 package argo
 
 import (
-	"bytes"
 	"fmt"
-	"io"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
 	"roar/internal/pkg/logger"
+	"roar/internal/pkg/manifest"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
 type Application struct {
-	Name           string
+	Name string
+	// Namespace is metadata.namespace of an Application outside of the Argo
+	// CD control plane namespace; it is empty for Applications in the
+	// control plane namespace.
+	Namespace      string
 	Instance       string
 	Env            string
 	RepoURL        string
@@ -23,6 +27,68 @@ type Application struct {
 	TargetRevision string
 	Setters        map[string]string
 	ValuesFiles    []string
+	Labels         map[string]string
+	Annotations    map[string]string
+	Destination    Destination
+}
+
+// QualifiedName identifies the Application the way Argo CD does: by its name
+// in the control plane namespace and by <namespace>/<name> in any other one.
+func (a Application) QualifiedName() string {
+	if a.Namespace == "" {
+		return a.Name
+	}
+	return a.Namespace + "/" + a.Name
+}
+
+const (
+	DefaultAPIVersion            = "argoproj.io/v1alpha1"
+	DefaultControlPlaneNamespace = "argocd"
+)
+
+// ParseOptions select the Application documents in the app-of-apps output
+// and how they are identified.
+type ParseOptions struct {
+	// APIVersions are the accepted apiVersions of documents of kind
+	// Application; only DefaultAPIVersion when empty.
+	APIVersions []string
+	// ControlPlaneNamespace is the namespace Argo CD runs in;
+	// DefaultControlPlaneNamespace when empty. Applications without
+	// metadata.namespace belong to it.
+	ControlPlaneNamespace string
+}
+
+func (o ParseOptions) isApplication(apiVersion, kind string) bool {
+	if kind != "Application" {
+		return false
+	}
+	if len(o.APIVersions) == 0 {
+		return apiVersion == DefaultAPIVersion
+	}
+	for _, accepted := range o.APIVersions {
+		if apiVersion == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// namespace returns the Application.Namespace for metadata.namespace.
+func (o ParseOptions) namespace(namespace string) string {
+	controlPlane := o.ControlPlaneNamespace
+	if controlPlane == "" {
+		controlPlane = DefaultControlPlaneNamespace
+	}
+	if namespace == controlPlane {
+		return ""
+	}
+	return namespace
+}
+
+type Destination struct {
+	Server    string `yaml:"server"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
 }
 
 type EnvVar struct {
@@ -35,6 +101,7 @@ type rawApplication struct {
 	Kind       string `yaml:"kind"`
 	Metadata   struct {
 		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
 		Labels      map[string]string `yaml:"labels"`
 		Annotations map[string]string `yaml:"annotations"`
 	} `yaml:"metadata"`
@@ -47,42 +114,146 @@ type rawApplication struct {
 				Env []EnvVar `yaml:"env"`
 			} `yaml:"plugin"`
 		} `yaml:"source"`
+		Destination Destination `yaml:"destination"`
 	} `yaml:"spec"`
 }
 
-func ParseApplications(yamlData []byte) ([]Application, error) {
+// Provenance records where the resolved fields of an Application came from
+// and the warnings raised while resolving them.
+type Provenance struct {
+	// Fields maps "env", "instance", "repoURL", "path" and "targetRevision"
+	// to the manifest field they were taken from.
+	Fields map[string]string
+	// Setters maps every setter key to the plugin env variable defining it.
+	Setters map[string]string
+	// ValuesFiles holds the plugin env variable of each values file, in the
+	// order of Application.ValuesFiles.
+	ValuesFiles []string
+	Warnings    []string
+}
+
+func ParseApplications(yamlData []byte, opts ParseOptions) ([]Application, error) {
+	apps, _, err := ExplainApplications(yamlData, opts)
+	return apps, err
+}
+
+// ExplainApplications is ParseApplications that also returns the provenance
+// of every Application, in the same order.
+func ExplainApplications(yamlData []byte, opts ParseOptions) ([]Application, []Provenance, error) {
 	var finalApps []Application
-	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+	var provenances []Provenance
 
-	for {
+	for index, doc := range manifest.Documents(yamlData) {
 		var rawApp rawApplication
-		err := decoder.Decode(&rawApp)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode yaml document: %w", err)
+		if err := yaml.Unmarshal(doc.Raw, &rawApp); err != nil {
+			return nil, nil, decodeError(index, doc, err)
 		}
 
-		if rawApp.ApiVersion == "argoproj.io/v1alpha1" && rawApp.Kind == "Application" {
-			logCtx := logger.Log.WithField("application", rawApp.Metadata.Name)
-			cleanApp, err := newApplicationFromRaw(rawApp, logCtx)
+		if opts.isApplication(rawApp.ApiVersion, rawApp.Kind) {
+			rawApp.Metadata.Namespace = opts.namespace(rawApp.Metadata.Namespace)
+			name := Application{Name: rawApp.Metadata.Name, Namespace: rawApp.Metadata.Namespace}.QualifiedName()
+			cleanApp, provenance, err := resolveApplication(rawApp, logger.Log.WithField("application", name))
 			if err != nil {
-				return nil, fmt.Errorf("application '%s' is invalid: %w", rawApp.Metadata.Name, err)
+				return nil, nil, fmt.Errorf("%s: application '%s' is invalid: %w", location(index, doc), name, err)
 			}
 			finalApps = append(finalApps, cleanApp)
+			provenances = append(provenances, provenance)
 		}
 	}
 
-	return finalApps, nil
+	return finalApps, provenances, nil
+}
+
+// location describes a document of the app-of-apps output by its index and
+// the template that rendered it.
+func location(index int, doc manifest.Document) string {
+	if doc.Source != "" {
+		return fmt.Sprintf("document #%d (%s)", index, doc.Source)
+	}
+	return fmt.Sprintf("document #%d", index)
+}
+
+var yamlErrorLine = regexp.MustCompile(`\bline (\d+)\b`)
+
+// decodeError reports a YAML error in a document with its lines translated
+// from the document to the template output.
+func decodeError(index int, doc manifest.Document, err error) error {
+	return fmt.Errorf("failed to decode yaml %s: %s", location(index, doc), templateLines(doc, err))
+}
+
+func templateLines(doc manifest.Document, err error) string {
+	return yamlErrorLine.ReplaceAllStringFunc(err.Error(), func(match string) string {
+		line, _ := strconv.Atoi(strings.TrimPrefix(match, "line "))
+		return fmt.Sprintf("line %d", doc.TemplateLine(line))
+	})
+}
+
+// ParseApplicationsLenient validates and parses every document of the
+// app-of-apps output on its own. A document that is not valid YAML, has
+// validation problems or cannot be resolved into an Application is skipped
+// and described by the returned problems, so that one broken template does
+// not prevent the other Applications from being rendered. Warnings are
+// returned along with the problems but do not skip a document.
+func ParseApplicationsLenient(yamlData []byte, opts ParseOptions) ([]Application, []Problem) {
+	var apps []Application
+	v := &validator{opts: opts, names: make(map[string]nameDefinition)}
+	for index, doc := range manifest.Documents(yamlData) {
+		v.index, v.document, v.app = index, doc, ""
+		var node yaml.Node
+		if err := yaml.Unmarshal(doc.Raw, &node); err != nil {
+			v.problems = append(v.problems, Problem{Document: index, Source: doc.Source, Message: templateLines(doc, err)})
+			continue
+		}
+		root := v.applicationRoot(&node)
+		if root == nil {
+			continue
+		}
+		found := v.errorCount()
+		v.validate(root)
+		if v.errorCount() > found {
+			continue
+		}
+		var rawApp rawApplication
+		if err := root.Decode(&rawApp); err != nil {
+			v.report(root, "", "%s", templateLines(doc, err))
+			continue
+		}
+		rawApp.Metadata.Namespace = opts.namespace(rawApp.Metadata.Namespace)
+		app, _, err := resolveApplication(rawApp, logger.Log.WithField("application", v.app))
+		if err != nil {
+			v.report(root, "", "application is invalid: %v", err)
+			continue
+		}
+		apps = append(apps, app)
+	}
+	return apps, v.problems
 }
 
 func newApplicationFromRaw(raw rawApplication, logCtx *logrus.Entry) (Application, error) {
+	app, _, err := resolveApplication(raw, logCtx)
+	return app, err
+}
+
+func resolveApplication(raw rawApplication, logCtx *logrus.Entry) (Application, Provenance, error) {
 	app := Application{
 		Name:           raw.Metadata.Name,
+		Namespace:      raw.Metadata.Namespace,
 		TargetRevision: raw.Spec.Source.TargetRevision,
 		Setters:        make(map[string]string),
 		ValuesFiles:    []string{},
+		Labels:         raw.Metadata.Labels,
+		Annotations:    raw.Metadata.Annotations,
+		Destination:    raw.Spec.Destination,
+	}
+	provenance := Provenance{
+		Fields:      map[string]string{"targetRevision": "spec.source.targetRevision"},
+		Setters:     make(map[string]string),
+		ValuesFiles: []string{},
+	}
+	warnf := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		logCtx.Warn(msg)
+		provenance.Warnings = append(provenance.Warnings, msg)
 	}
 
 	var instanceFromLabel, envFromLabel string
@@ -97,13 +268,14 @@ func newApplicationFromRaw(raw rawApplication, logCtx *logrus.Entry) (Applicatio
 
 	var instanceFromPlugin, envFromPlugin string
 	if raw.Spec.Source.Plugin != nil {
-		app.ValuesFiles = extractAndSortValuesFiles(raw.Spec.Source.Plugin.Env, logCtx)
+		app.ValuesFiles, provenance.ValuesFiles = extractAndSortValuesFiles(raw.Spec.Source.Plugin.Env, warnf)
 
 		for _, envVar := range raw.Spec.Source.Plugin.Env {
 			if strings.HasPrefix(envVar.Name, "WERF_SET_") {
 				key, value := extractKeyValueFromWerfSet(envVar.Value)
 				if key != "" {
 					app.Setters[key] = value
+					provenance.Setters[key] = pluginEnvSource(envVar.Name)
 					if envVar.Name == "WERF_SET_INSTANCE" {
 						instanceFromPlugin = value
 					}
@@ -111,57 +283,73 @@ func newApplicationFromRaw(raw rawApplication, logCtx *logrus.Entry) (Applicatio
 						envFromPlugin = value
 					}
 				} else {
-					logCtx.Warnf("Skipping invalid WERF_SET variable '%s' with value '%s'", envVar.Name, envVar.Value)
+					warnf("Skipping invalid WERF_SET variable '%s' with value '%s'", envVar.Name, envVar.Value)
 				}
 			}
 		}
 	}
 
 	if instanceFromLabel != "" && instanceFromPlugin != "" && instanceFromLabel != instanceFromPlugin {
-		return Application{}, fmt.Errorf("conflicting values for 'instance': label is '%s', plugin.env is '%s'", instanceFromLabel, instanceFromPlugin)
+		return Application{}, Provenance{}, fmt.Errorf("conflicting values for 'instance': label is '%s', plugin.env is '%s'", instanceFromLabel, instanceFromPlugin)
 	}
 	if instanceFromLabel != "" {
 		app.Instance = instanceFromLabel
-	} else {
+		provenance.Fields["instance"] = "metadata.labels.instance"
+	} else if instanceFromPlugin != "" {
 		app.Instance = instanceFromPlugin
+		provenance.Fields["instance"] = pluginEnvSource("WERF_SET_INSTANCE")
 	}
 
 	if envFromLabel != "" && envFromPlugin != "" && envFromLabel != envFromPlugin {
-		return Application{}, fmt.Errorf("conflicting values for 'env': label is '%s', plugin.env is '%s'", envFromLabel, envFromPlugin)
+		return Application{}, Provenance{}, fmt.Errorf("conflicting values for 'env': label is '%s', plugin.env is '%s'", envFromLabel, envFromPlugin)
 	}
 	if envFromLabel != "" {
 		app.Env = envFromLabel
-	} else {
+		provenance.Fields["env"] = "metadata.labels.env"
+	} else if envFromPlugin != "" {
 		app.Env = envFromPlugin
+		provenance.Fields["env"] = pluginEnvSource("WERF_SET_ENV")
 	}
 
 	repoURL, ok := raw.Metadata.Annotations["rawRepository"]
+	provenance.Fields["repoURL"] = "metadata.annotations.rawRepository"
 	if !ok || repoURL == "" {
-		logCtx.Warnf("missing 'rawRepository' annotation. Falling back to spec.source.repoURL='%s'", raw.Spec.Source.RepoURL)
+		warnf("missing 'rawRepository' annotation. Falling back to spec.source.repoURL='%s'", raw.Spec.Source.RepoURL)
 		repoURL = raw.Spec.Source.RepoURL
+		provenance.Fields["repoURL"] = "spec.source.repoURL (annotation fallback)"
 		if repoURL == "" {
-			return Application{}, fmt.Errorf("both 'rawRepository' annotation and 'spec.source.repoURL' are empty")
+			return Application{}, Provenance{}, fmt.Errorf("both 'rawRepository' annotation and 'spec.source.repoURL' are empty")
 		}
 	}
 	app.RepoURL = repoURL
 
 	path, ok := raw.Metadata.Annotations["rawPath"]
+	provenance.Fields["path"] = "metadata.annotations.rawPath"
 	if !ok {
-		logCtx.Warnf("missing 'rawPath' annotation. Falling back to spec.source.path='%s'", raw.Spec.Source.Path)
+		warnf("missing 'rawPath' annotation. Falling back to spec.source.path='%s'", raw.Spec.Source.Path)
 		path = raw.Spec.Source.Path
+		provenance.Fields["path"] = "spec.source.path (annotation fallback)"
 		if path == "" {
-			logCtx.Warn("both 'rawPath' annotation and 'spec.source.path' are empty. Falling back to '.'")
+			warnf("both 'rawPath' annotation and 'spec.source.path' are empty. Falling back to '.'")
 			path = "."
+			provenance.Fields["path"] = "default"
 		}
 	}
 	app.Path = path
 
-	return app, nil
+	return app, provenance, nil
+}
+
+func pluginEnvSource(name string) string {
+	return "spec.source.plugin.env." + name
 }
 
-func extractAndSortValuesFiles(envVars []EnvVar, logCtx *logrus.Entry) []string {
+// extractAndSortValuesFiles returns the values files ordered by their
+// WERF_VALUES_<index> and, in the same order, the variables defining them.
+func extractAndSortValuesFiles(envVars []EnvVar, warnf func(string, ...any)) ([]string, []string) {
 	type indexedValueFile struct {
 		index int
+		name  string
 		path  string
 	}
 	var indexedValues []indexedValueFile
@@ -171,10 +359,10 @@ func extractAndSortValuesFiles(envVars []EnvVar, logCtx *logrus.Entry) []string
 			indexStr := strings.TrimPrefix(envVar.Name, "WERF_VALUES_")
 			index, err := strconv.Atoi(indexStr)
 			if err != nil {
-				logCtx.Warnf("Could not parse index from '%s'. Skipping.", envVar.Name)
+				warnf("Could not parse index from '%s'. Skipping.", envVar.Name)
 				continue
 			}
-			indexedValues = append(indexedValues, indexedValueFile{index: index, path: envVar.Value})
+			indexedValues = append(indexedValues, indexedValueFile{index: index, name: envVar.Name, path: envVar.Value})
 		}
 	}
 
@@ -183,11 +371,13 @@ func extractAndSortValuesFiles(envVars []EnvVar, logCtx *logrus.Entry) []string
 	})
 
 	sortedValuesFiles := make([]string, len(indexedValues))
+	sources := make([]string, len(indexedValues))
 	for i, iv := range indexedValues {
 		sortedValuesFiles[i] = iv.path
+		sources[i] = pluginEnvSource(iv.name)
 	}
 
-	return sortedValuesFiles
+	return sortedValuesFiles, sources
 }
 
 func extractKeyValueFromWerfSet(s string) (string, string) {
@@ -87,11 +87,42 @@ spec: {source: {targetRevision: "dev"}}
 			expectError:   true,
 			errorContains: "failed to decode yaml document",
 		},
+		{
+			name: "should point decode errors to the template and its line",
+			inputYAML: `---
+# Source: apps/templates/api.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: api, annotations: {rawRepository: "repo"}}
+spec: {source: {targetRevision: "main"}}
+---
+# Source: apps/templates/web.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  labels: env: dev
+`,
+			expectError:   true,
+			errorContains: "failed to decode yaml document #1 (apps/templates/web.yaml): yaml: line 5: mapping values are not allowed",
+		},
+		{
+			name: "should point invalid applications to the template",
+			inputYAML: `---
+# Source: apps/templates/web.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web}
+spec: {source: {targetRevision: "main"}}
+`,
+			expectError:   true,
+			errorContains: "document #0 (apps/templates/web.yaml): application 'web' is invalid",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			apps, err := ParseApplications([]byte(tc.inputYAML))
+			apps, err := ParseApplications([]byte(tc.inputYAML), ParseOptions{})
 
 			if tc.expectError {
 				require.Error(t, err)
@@ -151,6 +182,8 @@ func TestNewApplicationFromRaw(t *testing.T) {
 				Path:           ".", // Ожидаемый fallback
 				Setters:        map[string]string{},
 				ValuesFiles:    []string{},
+				Labels:         map[string]string{"instance": "from-label", "env": "dev-label"},
+				Annotations:    map[string]string{"rawRepository": "https://default.repo"},
 			},
 		},
 		{
@@ -179,6 +212,7 @@ func TestNewApplicationFromRaw(t *testing.T) {
 					"global.env":      "dev-plugin",
 				},
 				ValuesFiles: []string{},
+				Annotations: map[string]string{"rawRepository": "https://default.repo"},
 			},
 		},
 		{
@@ -231,6 +265,8 @@ func TestNewApplicationFromRaw(t *testing.T) {
 				Path:           ".",
 				Setters:        map[string]string{"global.instance": "same-value"},
 				ValuesFiles:    []string{},
+				Labels:         map[string]string{"instance": "same-value"},
+				Annotations:    map[string]string{"rawRepository": "https://default.repo"},
 			},
 		},
 
@@ -252,6 +288,7 @@ func TestNewApplicationFromRaw(t *testing.T) {
 				TargetRevision: "main",
 				Setters:        map[string]string{},
 				ValuesFiles:    []string{},
+				Annotations:    map[string]string{"rawRepository": "https://anno.repo", "rawPath": "anno/path"},
 			},
 		},
 		{
@@ -271,6 +308,7 @@ func TestNewApplicationFromRaw(t *testing.T) {
 				TargetRevision: "main",
 				Setters:        map[string]string{},
 				ValuesFiles:    []string{},
+				Annotations:    map[string]string{},
 			},
 		},
 		{
@@ -287,6 +325,7 @@ func TestNewApplicationFromRaw(t *testing.T) {
 				TargetRevision: "main",
 				Setters:        map[string]string{},
 				ValuesFiles:    []string{},
+				Annotations:    map[string]string{"rawRepository": "https://default.repo"},
 			},
 		},
 		{
@@ -326,6 +365,7 @@ func TestNewApplicationFromRaw(t *testing.T) {
 				TargetRevision: "main",
 				ValuesFiles:    []string{"values/common.yaml", "values/overlay.yaml", "values/prod.yaml"},
 				Setters:        map[string]string{},
+				Annotations:    map[string]string{"rawRepository": "https://default.repo"},
 			},
 		},
 		{
@@ -353,6 +393,7 @@ func TestNewApplicationFromRaw(t *testing.T) {
 					"frontend.replicaCount": "3",
 				},
 				ValuesFiles: []string{},
+				Annotations: map[string]string{"rawRepository": "https://default.repo"},
 			},
 		},
 	}
@@ -381,3 +422,148 @@ func TestNewApplicationFromRaw(t *testing.T) {
 		})
 	}
 }
+
+func TestExplainApplications(t *testing.T) {
+	inputYAML := `
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: app
+  labels:
+    env: dev
+spec:
+  source:
+    repoURL: https://example.com/repo.git
+    targetRevision: main
+    plugin:
+      env:
+        - name: WERF_SET_INSTANCE
+          value: "global.instance=inf1"
+        - name: WERF_VALUES_1
+          value: "values-dev.yaml"
+        - name: WERF_VALUES_0
+          value: "values.yaml"
+        - name: WERF_VALUES_X
+          value: "broken.yaml"
+`
+	apps, provenances, err := ExplainApplications([]byte(inputYAML), ParseOptions{})
+	require.NoError(t, err)
+	require.Len(t, apps, 1)
+	require.Len(t, provenances, 1)
+
+	p := provenances[0]
+	require.Equal(t, map[string]string{
+		"env":            "metadata.labels.env",
+		"instance":       "spec.source.plugin.env.WERF_SET_INSTANCE",
+		"repoURL":        "spec.source.repoURL (annotation fallback)",
+		"path":           "default",
+		"targetRevision": "spec.source.targetRevision",
+	}, p.Fields)
+	require.Equal(t, map[string]string{"global.instance": "spec.source.plugin.env.WERF_SET_INSTANCE"}, p.Setters)
+	require.Equal(t, []string{"values.yaml", "values-dev.yaml"}, apps[0].ValuesFiles)
+	require.Equal(t, []string{"spec.source.plugin.env.WERF_VALUES_0", "spec.source.plugin.env.WERF_VALUES_1"}, p.ValuesFiles)
+	require.Len(t, p.Warnings, 4)
+	require.Contains(t, p.Warnings[0], "WERF_VALUES_X")
+}
+
+func TestParseApplicationsLenient(t *testing.T) {
+	inputYAML := `---
+# Source: apps/templates/api.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: api, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+---
+# Source: apps/templates/broken.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: broken: yes
+---
+# Source: apps/templates/worker.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: worker, annotations: {rawRepository: repo}}
+spec: {source: {path: worker}}
+---
+# Source: apps/templates/cron.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: cron
+  labels: [env, dev]
+  annotations: {rawRepository: repo}
+spec: {source: {targetRevision: main}}
+---
+# Source: apps/templates/web.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+`
+	apps, problems := ParseApplicationsLenient([]byte(inputYAML), ParseOptions{})
+
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+	require.Equal(t, []string{"api", "web"}, names)
+
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+	require.Equal(t, []string{
+		"document #1 (apps/templates/broken.yaml): yaml: line 4: mapping values are not allowed in this context",
+		"document #2 (apps/templates/worker.yaml), line 4, column 8, application 'worker': spec.source.targetRevision: is missing, the branch or tag to clone is required",
+		"document #3 (apps/templates/cron.yaml), line 1, column 1, application 'cron': yaml: unmarshal errors:\n  line 5: cannot unmarshal !!seq into map[string]string",
+	}, messages)
+}
+
+func TestParseApplications_Namespaces(t *testing.T) {
+	inputYAML := `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, namespace: argocd, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, namespace: team-a, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+---
+apiVersion: argoproj.io/v1beta1
+kind: Application
+metadata: {name: api, namespace: team-a, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+`
+	qualifiedNames := func(apps []Application) []string {
+		var names []string
+		for _, app := range apps {
+			names = append(names, app.QualifiedName())
+		}
+		return names
+	}
+
+	apps, err := ParseApplications([]byte(inputYAML), ParseOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"web", "web", "team-a/web"}, qualifiedNames(apps))
+	require.Equal(t, "team-a", apps[2].Namespace)
+
+	apps, err = ParseApplications([]byte(inputYAML), ParseOptions{
+		APIVersions:           []string{"argoproj.io/v1alpha1", "argoproj.io/v1beta1"},
+		ControlPlaneNamespace: "team-a",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"web", "argocd/web", "web", "api"}, qualifiedNames(apps))
+
+	// Одинаковые имена в разных namespace не считаются дубликатами
+	problems, err := ValidateApplications([]byte(inputYAML), ParseOptions{APIVersions: []string{"argoproj.io/v1beta1", "argoproj.io/v1alpha1"}})
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	require.Equal(t, "document #1, line 8, column 18, application 'web': metadata.name: duplicate Application name, first defined in document #0 at line 3", problems[0].String())
+}
@@ -0,0 +1,162 @@
+package argo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type selectorOp string
+
+const (
+	opEquals       selectorOp = "="
+	opNotEquals    selectorOp = "!="
+	opIn           selectorOp = "in"
+	opNotIn        selectorOp = "notin"
+	opExists       selectorOp = "exists"
+	opDoesNotExist selectorOp = "!"
+)
+
+type requirement struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+// Selector is a Kubernetes label selector, e.g.
+// `env=dev,team in (core,infra),!legacy`.
+type Selector []requirement
+
+var labelKeyPattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// setRequirementPattern matches `key in (...)` and `key notin (...)`. Set
+// operators are recognised before equality, so values may contain `=`.
+var setRequirementPattern = regexp.MustCompile(`^(\S+?)\s+(in|notin)\s*(.*)$`)
+
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, part := range splitRequirements(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector '%s': %w", s, err)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// Matches reports whether labels satisfy every requirement of the selector.
+// An empty selector matches everything.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		switch req.op {
+		case opEquals:
+			if !ok || value != req.values[0] {
+				return false
+			}
+		case opNotEquals:
+			if ok && value == req.values[0] {
+				return false
+			}
+		case opIn:
+			if !ok || !contains(req.values, value) {
+				return false
+			}
+		case opNotIn:
+			if ok && contains(req.values, value) {
+				return false
+			}
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opDoesNotExist:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitRequirements splits on commas that are not inside a value set.
+func splitRequirements(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseRequirement(part string) (requirement, error) {
+	if strings.HasPrefix(part, "!") {
+		key := strings.TrimSpace(part[1:])
+		return requirement{key: key, op: opDoesNotExist}, validateKey(key)
+	}
+	if m := setRequirementPattern.FindStringSubmatch(part); m != nil {
+		return parseSetRequirement(m[1], selectorOp(m[2]), strings.TrimSpace(m[3]))
+	}
+	for _, op := range []selectorOp{"!=", "==", "="} {
+		if key, value, ok := strings.Cut(part, string(op)); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			resolved := opEquals
+			if op == "!=" {
+				resolved = opNotEquals
+			}
+			return requirement{key: key, op: resolved, values: []string{value}}, validateKey(key)
+		}
+	}
+	fields := strings.Fields(part)
+	if len(fields) == 1 {
+		return requirement{key: fields[0], op: opExists}, validateKey(fields[0])
+	}
+	return requirement{}, fmt.Errorf("cannot parse requirement '%s'", part)
+}
+
+func parseSetRequirement(key string, op selectorOp, set string) (requirement, error) {
+	if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+		return requirement{}, fmt.Errorf("values of '%s' must be enclosed in parentheses", key)
+	}
+	var values []string
+	for _, v := range strings.Split(set[1:len(set)-1], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return requirement{}, fmt.Errorf("empty value set for '%s'", key)
+	}
+	return requirement{key: key, op: op, values: values}, validateKey(key)
+}
+
+func validateKey(key string) error {
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key '%s'", key)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,339 @@
+package argo
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"roar/internal/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is an issue found in an Application manifest. Document is the index
+// of the YAML document in the app-of-apps output and Source the template that
+// rendered it. Line and Column point to the offending node, counted from 1 in
+// the rendered output of Source or, without a Source, in the whole output;
+// they are zero for documents that are not valid YAML. Warnings describe
+// values the parser skips; they are reported but do not make the document
+// invalid.
+type Problem struct {
+	Document    int
+	Source      string
+	Line        int
+	Column      int
+	Application string
+	Field       string
+	Message     string
+	Warning     bool
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("document #%d", p.Document)
+	if p.Warning {
+		s = "warning: " + s
+	}
+	if p.Source != "" {
+		s += " (" + p.Source + ")"
+	}
+	if p.Line > 0 {
+		s += fmt.Sprintf(", line %d, column %d", p.Line, p.Column)
+	}
+	if p.Application != "" {
+		s += fmt.Sprintf(", application '%s'", p.Application)
+	}
+	s += ": "
+	if p.Field != "" {
+		s += p.Field + ": "
+	}
+	return s + p.Message
+}
+
+// ValidationError lists all problems found in the Application manifests.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems in Application manifests:", len(e.Problems))
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  %s", p)
+	}
+	return b.String()
+}
+
+// SplitWarnings separates the warnings from the problems that make a
+// document invalid.
+func SplitWarnings(problems []Problem) (errs, warnings []Problem) {
+	for _, p := range problems {
+		if p.Warning {
+			warnings = append(warnings, p)
+		} else {
+			errs = append(errs, p)
+		}
+	}
+	return errs, warnings
+}
+
+// unsupportedSources are the source types that roar cannot render: it always
+// renders spec.source.path as a werf chart configured by the plugin env.
+var unsupportedSources = []struct {
+	field   string
+	message string
+}{
+	{"chart", "Helm repository charts are not supported, only charts in git repositories"},
+	{"helm", "helm sources are not supported, pass values with WERF_VALUES_<n> and WERF_SET_<name> plugin env variables"},
+	{"kustomize", "kustomize sources are not supported"},
+	{"directory", "directory sources are not supported"},
+}
+
+// ValidateApplications checks the Application manifests in the output of the
+// app-of-apps chart for problems that ParseApplications accepts but that make
+// rendering fail or produce wrong output: missing fields, invalid or
+// duplicate WERF_VALUES indices, invalid or conflicting setters, paths leaving
+// the repository, unsupported source types and duplicate names. Invalid
+// indices and setters are warnings, see validatePluginEnv. It returns an
+// error only when the output is not valid YAML; all problems are returned,
+// not just the first one.
+func ValidateApplications(yamlData []byte, opts ParseOptions) ([]Problem, error) {
+	v := &validator{opts: opts, names: make(map[string]nameDefinition)}
+	for index, doc := range manifest.Documents(yamlData) {
+		var node yaml.Node
+		if err := yaml.Unmarshal(doc.Raw, &node); err != nil {
+			return nil, decodeError(index, doc, err)
+		}
+		if root := v.applicationRoot(&node); root != nil {
+			v.index, v.document = index, doc
+			v.validate(root)
+		}
+	}
+	return v.problems, nil
+}
+
+// applicationRoot returns the mapping of a decoded document if it is an
+// Application, or nil.
+func (v *validator) applicationRoot(node *yaml.Node) *yaml.Node {
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := node.Content[0]
+	if !v.opts.isApplication(scalar(lookup(root, "apiVersion")), scalar(lookup(root, "kind"))) {
+		return nil
+	}
+	return root
+}
+
+type nameDefinition struct {
+	location string
+	line     int
+}
+
+type validator struct {
+	opts     ParseOptions
+	names    map[string]nameDefinition
+	problems []Problem
+	// index, document and app identify the Application being validated.
+	index    int
+	document manifest.Document
+	app      string
+}
+
+func (v *validator) report(node *yaml.Node, field, format string, args ...any) {
+	v.problems = append(v.problems, v.problem(node, field, format, args...))
+}
+
+func (v *validator) warn(node *yaml.Node, field, format string, args ...any) {
+	p := v.problem(node, field, format, args...)
+	p.Warning = true
+	v.problems = append(v.problems, p)
+}
+
+func (v *validator) problem(node *yaml.Node, field, format string, args ...any) Problem {
+	return Problem{
+		Document:    v.index,
+		Source:      v.document.Source,
+		Line:        v.document.TemplateLine(node.Line),
+		Column:      node.Column,
+		Application: v.app,
+		Field:       field,
+		Message:     fmt.Sprintf(format, args...),
+	}
+}
+
+// errorCount returns the number of problems that are not warnings.
+func (v *validator) errorCount() int {
+	n := 0
+	for _, p := range v.problems {
+		if !p.Warning {
+			n++
+		}
+	}
+	return n
+}
+
+func (v *validator) validate(root *yaml.Node) {
+	metadata := lookup(root, "metadata")
+	nameNode := lookup(metadata, "name")
+	v.app = ""
+	if name := scalar(nameNode); name != "" {
+		namespace := v.opts.namespace(scalar(lookup(metadata, "namespace")))
+		v.app = Application{Name: name, Namespace: namespace}.QualifiedName()
+	}
+	if v.app == "" {
+		v.report(orNode(lookupKey(root, "metadata"), root), "metadata.name", "is missing")
+	} else if first, ok := v.names[v.app]; ok {
+		v.report(nameNode, "metadata.name", "duplicate Application name, first defined in %s at line %d", first.location, first.line)
+	} else {
+		v.names[v.app] = nameDefinition{location: location(v.index, v.document), line: v.document.TemplateLine(nameNode.Line)}
+	}
+
+	spec := lookup(root, "spec")
+	source, sourceKey := lookup(spec, "source"), lookupKey(spec, "source")
+	if sources := lookup(spec, "sources"); sources != nil {
+		v.report(sources, "spec.sources", "multi-source Applications are not supported")
+	}
+	if source == nil {
+		if lookup(spec, "sources") == nil {
+			v.report(orNode(lookupKey(root, "spec"), root), "spec.source", "is missing")
+		}
+		return
+	}
+
+	if revision := lookup(source, "targetRevision"); strings.TrimSpace(scalar(revision)) == "" {
+		v.report(orNode(revision, sourceKey), "spec.source.targetRevision", "is missing, the branch or tag to clone is required")
+	}
+	for _, unsupported := range unsupportedSources {
+		if node := lookupKey(source, unsupported.field); node != nil {
+			v.report(node, "spec.source."+unsupported.field, "%s", unsupported.message)
+		}
+	}
+
+	annotations := lookup(metadata, "annotations")
+	if scalar(lookup(annotations, "rawRepository")) == "" && scalar(lookup(source, "repoURL")) == "" {
+		v.report(sourceKey, "spec.source.repoURL", "both 'rawRepository' annotation and 'spec.source.repoURL' are empty")
+	}
+
+	appPath, pathNode, pathField := ".", (*yaml.Node)(nil), ""
+	if node := lookup(annotations, "rawPath"); node != nil {
+		appPath, pathNode, pathField = scalar(node), node, "metadata.annotations.rawPath"
+	} else if node := lookup(source, "path"); node != nil && scalar(node) != "" {
+		appPath, pathNode, pathField = scalar(node), node, "spec.source.path"
+	}
+	if pathNode != nil && escapes(appPath) {
+		v.report(pathNode, pathField, "path %q points outside of the repository", appPath)
+	}
+
+	if plugin := lookup(source, "plugin"); plugin != nil {
+		v.validatePluginEnv(lookup(plugin, "env"), appPath, lookup(metadata, "labels"))
+	}
+}
+
+// validatePluginEnv checks the WERF_VALUES_<n> and WERF_SET_<name> variables.
+// An index that is not a non-negative integer and a setter without '=' are
+// reported as warnings: the parser has always skipped or used them as is, so
+// they do not abort a strict run.
+func (v *validator) validatePluginEnv(env *yaml.Node, appPath string, labels *yaml.Node) {
+	if env == nil || env.Kind != yaml.SequenceNode {
+		return
+	}
+	type definition struct {
+		name  string
+		value string
+		line  int
+	}
+	indices := make(map[int]definition)
+	setters := make(map[string]definition)
+	for _, item := range env.Content {
+		nameNode, valueNode := lookup(item, "name"), lookup(item, "value")
+		name, value := scalar(nameNode), scalar(valueNode)
+		field := "spec.source.plugin.env." + name
+		switch {
+		case strings.HasPrefix(name, "WERF_VALUES_"):
+			suffix := strings.TrimPrefix(name, "WERF_VALUES_")
+			index, err := strconv.Atoi(suffix)
+			if err != nil || index < 0 || strings.HasPrefix(suffix, "+") {
+				v.warn(nameNode, field, "invalid values file index %q, expected a non-negative integer", suffix)
+				continue
+			}
+			if first, ok := indices[index]; ok {
+				v.report(nameNode, field, "duplicate values file index %d, already used by %s at line %d", index, first.name, first.line)
+				continue
+			}
+			indices[index] = definition{name: name, line: v.document.TemplateLine(nameNode.Line)}
+			if value == "" {
+				v.report(orNode(valueNode, item), field, "values file path is empty")
+			} else if !escapes(appPath) && escapes(path.Join(appPath, value)) {
+				v.report(orNode(valueNode, item), field, "values file %q points outside of the repository", value)
+			}
+		case strings.HasPrefix(name, "WERF_SET_"):
+			key, setValue := extractKeyValueFromWerfSet(value)
+			if key == "" {
+				v.warn(orNode(valueNode, item), field, "invalid value %q, expected key=value", value)
+				continue
+			}
+			if first, ok := setters[key]; ok && first.value != setValue {
+				v.report(orNode(valueNode, item), field, "conflicting values for '%s': %s sets '%s', %s sets '%s'", key, first.name, first.value, name, setValue)
+				continue
+			}
+			setters[key] = definition{name: name, value: setValue}
+			label := strings.ToLower(strings.TrimPrefix(name, "WERF_SET_"))
+			if label == "env" || label == "instance" {
+				if fromLabel := scalar(lookup(labels, label)); fromLabel != "" && fromLabel != setValue {
+					v.report(orNode(valueNode, item), field, "conflicting values for '%s': label is '%s', plugin.env is '%s'", label, fromLabel, setValue)
+				}
+			}
+		}
+	}
+}
+
+// escapes reports whether a slash-separated path relative to the repository
+// root is absolute or leaves the repository.
+func escapes(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return true
+	}
+	clean := path.Clean(p)
+	return clean == ".." || strings.HasPrefix(clean, "../")
+}
+
+// lookupKey returns the key node of a mapping entry, or nil.
+func lookupKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// lookup returns the value node of a mapping entry, or nil.
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+func orNode(node, fallback *yaml.Node) *yaml.Node {
+	if node != nil {
+		return node
+	}
+	return fallback
+}
@@ -0,0 +1,236 @@
+package argo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateApplications(t *testing.T) {
+	testCases := []struct {
+		name      string
+		inputYAML string
+		expected  []string
+	}{
+		{
+			name: "valid application",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  labels: {env: dev}
+  annotations: {rawRepository: repo, rawPath: services/web}
+spec:
+  source:
+    targetRevision: main
+    plugin:
+      env:
+      - {name: WERF_VALUES_0, value: .helm/values.yaml}
+      - {name: WERF_VALUES_1, value: ../shared/values.yaml}
+      - {name: WERF_SET_ENV, value: global.env=dev}
+      - {name: WERF_SET_REPLICAS, value: replicas=2}
+      - {name: WERF_SET_REPLICAS_AGAIN, value: replicas=2}
+`,
+		},
+		{
+			name: "missing target revision",
+			inputYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations: {rawRepository: repo}
+spec:
+  source:
+    path: web
+`,
+			expected: []string{"document #1, line 12, column 3, application 'web': spec.source.targetRevision: is missing, the branch or tag to clone is required"},
+		},
+		{
+			name: "values file indices",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations: {rawRepository: repo}
+spec:
+  source:
+    targetRevision: main
+    plugin:
+      env:
+      - name: WERF_VALUES_1
+        value: a.yaml
+      - name: WERF_VALUES_X
+        value: b.yaml
+      - name: WERF_VALUES_-1
+        value: c.yaml
+      - name: WERF_VALUES_01
+        value: d.yaml
+      - name: WERF_VALUES_+1
+        value: e.yaml
+`,
+			expected: []string{
+				`warning: document #0, line 13, column 15, application 'web': spec.source.plugin.env.WERF_VALUES_X: invalid values file index "X", expected a non-negative integer`,
+				`warning: document #0, line 15, column 15, application 'web': spec.source.plugin.env.WERF_VALUES_-1: invalid values file index "-1", expected a non-negative integer`,
+				`document #0, line 17, column 15, application 'web': spec.source.plugin.env.WERF_VALUES_01: duplicate values file index 1, already used by WERF_VALUES_1 at line 11`,
+				`warning: document #0, line 19, column 15, application 'web': spec.source.plugin.env.WERF_VALUES_+1: invalid values file index "+1", expected a non-negative integer`,
+			},
+		},
+		{
+			name: "path traversal",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations: {rawRepository: repo, rawPath: ../../etc}
+spec:
+  source:
+    targetRevision: main
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: api
+  annotations: {rawRepository: repo}
+spec:
+  source:
+    targetRevision: main
+    path: services/api
+    plugin:
+      env:
+      - {name: WERF_VALUES_0, value: ../../../values.yaml}
+`,
+			expected: []string{
+				`document #0, line 5, column 47, application 'web': metadata.annotations.rawPath: path "../../etc" points outside of the repository`,
+				`document #1, line 21, column 38, application 'api': spec.source.plugin.env.WERF_VALUES_0: values file "../../../values.yaml" points outside of the repository`,
+			},
+		},
+		{
+			name: "duplicate names",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: dev}}
+`,
+			expected: []string{"document #1, line 8, column 18, application 'web': metadata.name: duplicate Application name, first defined in document #0 at line 3"},
+		},
+		{
+			name: "conflicting setters",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  labels: {env: prod}
+  annotations: {rawRepository: repo}
+spec:
+  source:
+    targetRevision: main
+    plugin:
+      env:
+      - {name: WERF_SET_REPLICAS, value: replicas=2}
+      - {name: WERF_SET_SCALE, value: replicas=3}
+      - {name: WERF_SET_ENV, value: global.env=dev}
+      - {name: WERF_SET_BROKEN, value: no-equals-sign}
+`,
+			// WERF_SET_BROKEN is skipped by the parser with a warning.
+			expected: []string{
+				`document #0, line 13, column 39, application 'web': spec.source.plugin.env.WERF_SET_SCALE: conflicting values for 'replicas': WERF_SET_REPLICAS sets '2', WERF_SET_SCALE sets '3'`,
+				`document #0, line 14, column 37, application 'web': spec.source.plugin.env.WERF_SET_ENV: conflicting values for 'env': label is 'prod', plugin.env is 'dev'`,
+				`warning: document #0, line 15, column 40, application 'web': spec.source.plugin.env.WERF_SET_BROKEN: invalid value "no-equals-sign", expected key=value`,
+			},
+		},
+		{
+			name: "unsupported sources",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+spec:
+  source:
+    repoURL: https://charts.example.com
+    chart: web
+    targetRevision: 1.2.0
+    helm:
+      valueFiles: [values.yaml]
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: multi
+spec:
+  sources:
+  - repoURL: https://git.example.com/a.git
+`,
+			expected: []string{
+				"document #0, line 8, column 5, application 'web': spec.source.chart: Helm repository charts are not supported, only charts in git repositories",
+				"document #0, line 10, column 5, application 'web': spec.source.helm: helm sources are not supported, pass values with WERF_VALUES_<n> and WERF_SET_<name> plugin env variables",
+				"document #1, line 19, column 3, application 'multi': spec.sources: multi-source Applications are not supported",
+			},
+		},
+		{
+			name: "missing name and repository",
+			inputYAML: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  labels: {env: dev}
+spec:
+  source:
+    targetRevision: main
+`,
+			expected: []string{
+				"document #0, line 3, column 1: metadata.name: is missing",
+				"document #0, line 6, column 3: spec.source.repoURL: both 'rawRepository' annotation and 'spec.source.repoURL' are empty",
+			},
+		},
+		{
+			name: "template sources",
+			inputYAML: `---
+# Source: apps/templates/web.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata: {name: web, annotations: {rawRepository: repo}}
+spec: {source: {targetRevision: main}}
+---
+# Source: apps/templates/web-copy.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  annotations: {rawRepository: repo}
+spec:
+  source:
+    path: web
+`,
+			expected: []string{
+				"document #1 (apps/templates/web-copy.yaml), line 4, column 9, application 'web': metadata.name: duplicate Application name, first defined in document #0 (apps/templates/web.yaml) at line 3",
+				"document #1 (apps/templates/web-copy.yaml), line 7, column 3, application 'web': spec.source.targetRevision: is missing, the branch or tag to clone is required",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems, err := ValidateApplications([]byte(tc.inputYAML), ParseOptions{})
+			require.NoError(t, err)
+			var messages []string
+			for _, p := range problems {
+				messages = append(messages, p.String())
+			}
+			require.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestValidateApplications_MalformedYAML(t *testing.T) {
+	_, err := ValidateApplications([]byte("apiVersion: v1\n---\napiVersion: v1: kind: Broken"), ParseOptions{})
+	require.ErrorContains(t, err, "failed to decode yaml document #1")
+}
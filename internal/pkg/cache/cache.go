@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Inputs are everything that determines the output of `helm template` for
+// one Application. Paths are relative to the repository, so the same commit
+// rendered from different clones produces the same key. The namespaces of the
+// Application and of its destination are not passed to `helm template` and
+// are left out.
+type Inputs struct {
+	CommitSHA       string
+	ChartPath       string
+	ReleaseName     string
+	ValuesFiles     []ValuesFile
+	Setters         map[string]string
+	KubeVersion     string
+	RendererVersion string
+}
+
+type ValuesFile struct {
+	Path    string
+	Content []byte
+}
+
+// Key returns the content address of the inputs. Values files keep their
+// order because later files override earlier ones; setters are sorted.
+func (in Inputs) Key() string {
+	setters := make([][2]string, 0, len(in.Setters))
+	for key, value := range in.Setters {
+		setters = append(setters, [2]string{key, value})
+	}
+	sort.Slice(setters, func(i, j int) bool { return setters[i][0] < setters[j][0] })
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, v := range []any{in.CommitSHA, in.ChartPath, in.ReleaseName, in.ValuesFiles, setters, in.KubeVersion, in.RendererVersion} {
+		// Encoding plain values into a hash cannot fail.
+		_ = enc.Encode(v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Cache stores rendered `helm template` output on disk, one file per key.
+type Cache struct {
+	dir string
+}
+
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create render cache directory %s: %w", dir, err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".yaml")
+}
+
+// Get returns the cached output for key. A missing entry is not an error.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read render cache entry %s: %w", key, err)
+	}
+	return data, true, nil
+}
+
+// Put stores the output for key. The entry is written to a temporary file
+// first, so concurrent readers never see a partial entry.
+func (c *Cache) Put(key string, data []byte) error {
+	target := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create render cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write render cache entry %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write render cache entry %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write render cache entry %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to write render cache entry %s: %w", key, err)
+	}
+	return nil
+}
+
+// isShard and isEntry match the layout written by Put: entries are named
+// after their key and sharded by its first two hex digits.
+func isShard(name string) bool {
+	return len(name) == 2 && isHex(name)
+}
+
+func isEntry(shard, name string) bool {
+	key, ok := strings.CutSuffix(name, ".yaml")
+	return ok && len(key) == 2*sha256.Size && isHex(key) && key[:2] == shard
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// walk calls fn for every file in the shard directories that is an entry, or
+// a temporary file left behind by an interrupted Put. Anything else in the
+// directory is not part of the cache and is skipped.
+func (c *Cache) walk(fn func(path string, d fs.DirEntry, entry bool) error) error {
+	shards, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() || !isShard(shard.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(c.dir, shard.Name()))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			entry := isEntry(shard.Name(), f.Name())
+			if f.IsDir() || !entry && !strings.HasPrefix(f.Name(), ".tmp-") {
+				continue
+			}
+			if err := fn(filepath.Join(c.dir, shard.Name(), f.Name()), f, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stats returns the number of entries in the cache and their total size in
+// bytes.
+func (c *Cache) Stats() (entries int, size int64, err error) {
+	err = c.walk(func(path string, d fs.DirEntry, entry bool) error {
+		if !entry {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries++
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to inspect render cache %s: %w", c.dir, err)
+	}
+	return entries, size, nil
+}
+
+// Clean removes all entries from the cache. Only files that follow the layout
+// of the cache are removed, so a cache directory pointed at the wrong place
+// does not lose unrelated files; shard directories are removed once empty.
+func (c *Cache) Clean() error {
+	err := c.walk(func(path string, d fs.DirEntry, entry bool) error {
+		return os.Remove(path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clean render cache %s: %w", c.dir, err)
+	}
+	shards, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to clean render cache %s: %w", c.dir, err)
+	}
+	for _, shard := range shards {
+		if shard.IsDir() && isShard(shard.Name()) {
+			// A shard with foreign files in it is kept.
+			_ = os.Remove(filepath.Join(c.dir, shard.Name()))
+		}
+	}
+	return nil
+}
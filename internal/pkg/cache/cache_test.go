@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputsKey(t *testing.T) {
+	base := Inputs{
+		CommitSHA:   "abc",
+		ChartPath:   "stable/api/.helm",
+		ReleaseName: "api",
+		ValuesFiles: []ValuesFile{{Path: "a.yaml", Content: []byte("a: 1")}, {Path: "b.yaml", Content: []byte("b: 1")}},
+		Setters:     map[string]string{"x": "1", "y": "2"},
+	}
+	key := base.Key()
+	require.Len(t, key, 64)
+
+	same := base
+	same.Setters = map[string]string{"y": "2", "x": "1"}
+	require.Equal(t, key, same.Key())
+
+	tests := []struct {
+		name   string
+		modify func(in *Inputs)
+	}{
+		{"commit", func(in *Inputs) { in.CommitSHA = "def" }},
+		{"chart path", func(in *Inputs) { in.ChartPath = "stable/web/.helm" }},
+		{"release name", func(in *Inputs) { in.ReleaseName = "web" }},
+		{"values content", func(in *Inputs) {
+			in.ValuesFiles = []ValuesFile{{Path: "a.yaml", Content: []byte("a: 2")}, base.ValuesFiles[1]}
+		}},
+		{"values order", func(in *Inputs) { in.ValuesFiles = []ValuesFile{base.ValuesFiles[1], base.ValuesFiles[0]} }},
+		{"setter", func(in *Inputs) { in.Setters = map[string]string{"x": "1", "y": "3"} }},
+		{"kube version", func(in *Inputs) { in.KubeVersion = "1.29.0" }},
+		{"renderer version", func(in *Inputs) { in.RendererVersion = "v2" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base
+			tt.modify(&in)
+			require.NotEqual(t, key, in.Key())
+		})
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	key := Inputs{CommitSHA: "abc"}.Key()
+
+	_, ok, err := c.Get(key)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Put(key, []byte("kind: ConfigMap\n")))
+	data, ok, err := c.Get(key)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "kind: ConfigMap\n", string(data))
+}
+
+func TestCacheStatsClean(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, c.Put(Inputs{CommitSHA: "a"}.Key(), []byte("12345")))
+	require.NoError(t, c.Put(Inputs{CommitSHA: "b"}.Key(), []byte("123")))
+
+	entries, size, err := c.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 2, entries)
+	require.Equal(t, int64(8), size)
+
+	require.NoError(t, c.Clean())
+	entries, _, err = c.Stats()
+	require.NoError(t, err)
+	require.Zero(t, entries)
+	require.DirExists(t, c.Dir())
+}
+
+func TestCacheCleanKeepsForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir)
+	require.NoError(t, err)
+	key := Inputs{CommitSHA: "a"}.Key()
+	require.NoError(t, c.Put(key, []byte("kind: ConfigMap\n")))
+
+	foreign := []string{
+		"README.md",
+		filepath.Join("docs", "index.yaml"),
+		filepath.Join(key[:2], "notes.yaml"),
+		filepath.Join("zz", "0000.yaml"),
+	}
+	for _, name := range foreign {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0644))
+	}
+	// An entry in the wrong shard is not produced by Put either.
+	other := Inputs{CommitSHA: "b"}.Key()
+	misplaced := filepath.Join("docs", other+".yaml")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, misplaced), []byte("keep"), 0644))
+
+	entries, _, err := c.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, entries)
+
+	require.NoError(t, c.Clean())
+	require.NoFileExists(t, filepath.Join(dir, key[:2], key+".yaml"))
+	for _, name := range append(foreign, misplaced) {
+		require.FileExists(t, filepath.Join(dir, name))
+	}
+}
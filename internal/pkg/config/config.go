@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the project configuration file looked up next to
+// the app-of-apps chart and in the working directory.
+const FileName = ".roar.yaml"
+
+// File is the content of a project configuration file. Unset fields leave the
+// corresponding flag default in place.
+type File struct {
+	Values    []string  `yaml:"values,omitempty"`
+	Output    Output    `yaml:"output,omitempty"`
+	Normalize Normalize `yaml:"normalize,omitempty"`
+	Filter    Filter    `yaml:"filter,omitempty"`
+	Render    Render    `yaml:"render,omitempty"`
+	Git       Git       `yaml:"git,omitempty"`
+	// Applications selects the Application documents of the app-of-apps
+	// output and how they are identified.
+	Applications Applications `yaml:"applications,omitempty"`
+	Validate     Validate     `yaml:"validate,omitempty"`
+	Reports      Reports      `yaml:"reports,omitempty"`
+	LogLevel     string       `yaml:"logLevel,omitempty"`
+}
+
+type Output struct {
+	Dir           string `yaml:"dir,omitempty"`
+	Layout        string `yaml:"layout,omitempty"`
+	Mode          string `yaml:"mode,omitempty"`
+	Kustomization *bool  `yaml:"kustomization,omitempty"`
+	Prune         *bool  `yaml:"prune,omitempty"`
+	SyncOrder     *bool  `yaml:"syncOrder,omitempty"`
+}
+
+type Normalize struct {
+	Enabled          *bool    `yaml:"enabled,omitempty"`
+	StripLabels      []string `yaml:"stripLabels,omitempty"`
+	StripAnnotations []string `yaml:"stripAnnotations,omitempty"`
+}
+
+type Filter struct {
+	Apps      []string `yaml:"apps,omitempty"`
+	Selector  string   `yaml:"selector,omitempty"`
+	Envs      []string `yaml:"envs,omitempty"`
+	Instances []string `yaml:"instances,omitempty"`
+	Repos     []string `yaml:"repos,omitempty"`
+}
+
+type Render struct {
+	CacheDir    string `yaml:"cacheDir,omitempty"`
+	KubeVersion string `yaml:"kubeVersion,omitempty"`
+	// Concurrency is the number of Applications rendered at the same time.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// Lenient skips invalid documents of the app-of-apps output instead of
+	// aborting the run.
+	Lenient *bool `yaml:"lenient,omitempty"`
+}
+
+// Git configures how Application repositories are cloned. The passphrase of
+// SSHKeyFile is not part of the file, it is read from the environment.
+type Git struct {
+	SSHKeyFile string `yaml:"sshKeyFile,omitempty"`
+	// Rewrites maps repository URL prefixes to their replacement, e.g. a
+	// mirror.
+	Rewrites map[string]string `yaml:"rewrites,omitempty"`
+}
+
+type Applications struct {
+	APIVersions           []string `yaml:"apiVersions,omitempty"`
+	ControlPlaneNamespace string   `yaml:"controlPlaneNamespace,omitempty"`
+}
+
+type Validate struct {
+	Schemas    *bool    `yaml:"schemas,omitempty"`
+	SchemaDirs []string `yaml:"schemaDirs,omitempty"`
+	PolicyDirs []string `yaml:"policyDirs,omitempty"`
+	// DeprecatedAPIs enables the check for deprecated and removed APIs as
+	// of TargetKubeVersion.
+	DeprecatedAPIs    *bool  `yaml:"deprecatedAPIs,omitempty"`
+	TargetKubeVersion string `yaml:"targetKubeVersion,omitempty"`
+	// Duplicates enables the check for resources rendered by more than one
+	// Application.
+	Duplicates *bool `yaml:"duplicates,omitempty"`
+}
+
+type Reports struct {
+	JSON  string `yaml:"json,omitempty"`
+	JUnit string `yaml:"junit,omitempty"`
+}
+
+// Find returns the configuration file for the chart at chartPath: FileName in
+// the chart directory, then in the working directory. It returns an empty
+// string when neither exists.
+func Find(chartPath string) (string, error) {
+	for _, dir := range []string{chartPath, "."} {
+		path := filepath.Join(dir, FileName)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to check for config file %s: %w", path, err)
+		}
+	}
+	return "", nil
+}
+
+// Load reads a configuration file. Unknown keys are rejected so typos do not
+// go unnoticed. Relative paths are resolved against the directory of the file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.resolvePaths(filepath.Dir(path))
+	return f, nil
+}
+
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return f, nil
+}
+
+func (f *File) resolvePaths(dir string) {
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	for i, values := range f.Values {
+		f.Values[i] = resolve(values)
+	}
+	f.Output.Dir = resolve(f.Output.Dir)
+	f.Render.CacheDir = resolve(f.Render.CacheDir)
+	f.Git.SSHKeyFile = resolve(f.Git.SSHKeyFile)
+	for i, dir := range f.Validate.SchemaDirs {
+		f.Validate.SchemaDirs[i] = resolve(dir)
+	}
+	for i, dir := range f.Validate.PolicyDirs {
+		f.Validate.PolicyDirs[i] = resolve(dir)
+	}
+	f.Reports.JSON = resolve(f.Reports.JSON)
+	f.Reports.JUnit = resolve(f.Reports.JUnit)
+}
+
+func (f *File) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	content := `
+values: [values/dev.yaml, /abs/values.yaml]
+output:
+  dir: manifests
+  mode: split
+  prune: true
+  syncOrder: true
+normalize:
+  enabled: true
+  stripLabels: ["helm.sh/*"]
+filter:
+  envs: [dev]
+  selector: team=core
+render:
+  cacheDir: .cache
+  concurrency: 4
+  lenient: true
+git:
+  sshKeyFile: keys/id_ed25519
+  rewrites:
+    https://github.com/: https://mirror.example.com/github/
+applications:
+  apiVersions: [argoproj.io/v1alpha1, argoproj.io/v1beta1]
+reports:
+  junit: junit.xml
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	f, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "values/dev.yaml"), "/abs/values.yaml"}, f.Values)
+	require.Equal(t, filepath.Join(dir, "manifests"), f.Output.Dir)
+	require.Equal(t, "split", f.Output.Mode)
+	require.NotNil(t, f.Output.Prune)
+	require.True(t, *f.Output.Prune)
+	require.Nil(t, f.Output.Kustomization)
+	require.True(t, *f.Output.SyncOrder)
+	require.True(t, *f.Normalize.Enabled)
+	require.Equal(t, []string{"helm.sh/*"}, f.Normalize.StripLabels)
+	require.Equal(t, "team=core", f.Filter.Selector)
+	require.Equal(t, filepath.Join(dir, ".cache"), f.Render.CacheDir)
+	require.Equal(t, 4, f.Render.Concurrency)
+	require.True(t, *f.Render.Lenient)
+	require.Equal(t, filepath.Join(dir, "keys/id_ed25519"), f.Git.SSHKeyFile)
+	require.Equal(t, map[string]string{"https://github.com/": "https://mirror.example.com/github/"}, f.Git.Rewrites)
+	require.Equal(t, []string{"argoproj.io/v1alpha1", "argoproj.io/v1beta1"}, f.Applications.APIVersions)
+	require.Equal(t, filepath.Join(dir, "junit.xml"), f.Reports.JUnit)
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(""))
+	require.NoError(t, err)
+	require.Equal(t, &File{}, f)
+
+	_, err = Parse([]byte("output:\n  directory: x\n"))
+	require.ErrorContains(t, err, "field directory not found")
+}
+
+func TestFind(t *testing.T) {
+	chartDir := t.TempDir()
+	path, err := Find(chartDir)
+	require.NoError(t, err)
+	require.Empty(t, path)
+
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, FileName), nil, 0644))
+	path, err = Find(chartDir)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(chartDir, FileName), path)
+}
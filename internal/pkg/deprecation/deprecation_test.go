@@ -0,0 +1,42 @@
+package deprecation
+
+import (
+	"testing"
+
+	"roar/internal/pkg/manifest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name           string
+		apiVersion     string
+		kind           string
+		target         string
+		expectedStatus Status
+		replacement    string
+	}{
+		{name: "removed ingress", apiVersion: "extensions/v1beta1", kind: "Ingress", target: "1.22", expectedStatus: StatusRemoved, replacement: "networking.k8s.io/v1"},
+		{name: "deprecated before removal", apiVersion: "networking.k8s.io/v1beta1", kind: "Ingress", target: "v1.21.5", expectedStatus: StatusDeprecated, replacement: "networking.k8s.io/v1"},
+		{name: "not yet deprecated", apiVersion: "batch/v1beta1", kind: "CronJob", target: "1.20"},
+		{name: "removed without replacement", apiVersion: "policy/v1beta1", kind: "PodSecurityPolicy", target: "1.29", expectedStatus: StatusRemoved},
+		{name: "no target version", apiVersion: "autoscaling/v2beta2", kind: "HorizontalPodAutoscaler", expectedStatus: StatusRemoved, replacement: "autoscaling/v2"},
+		{name: "deprecated but never removed", apiVersion: "v1", kind: "ComponentStatus", expectedStatus: StatusDeprecated},
+		{name: "current api", apiVersion: "networking.k8s.io/v1", kind: "Ingress", target: "1.29"},
+		{name: "same version, other kind", apiVersion: "policy/v1beta1", kind: "Eviction", target: "1.29"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			finding, found := Check(manifest.Resource{APIVersion: tc.apiVersion, Kind: tc.kind, Name: "x"}, tc.target)
+			if tc.expectedStatus == "" {
+				require.False(t, found)
+				return
+			}
+			require.True(t, found)
+			require.Equal(t, tc.expectedStatus, finding.Status)
+			require.Equal(t, tc.replacement, finding.Replacement)
+		})
+	}
+}
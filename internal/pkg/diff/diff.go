@@ -0,0 +1,325 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/manifest"
+	"roar/internal/pkg/output"
+)
+
+type Status string
+
+const (
+	StatusAdded     Status = "added"
+	StatusRemoved   Status = "removed"
+	StatusModified  Status = "modified"
+	StatusUnchanged Status = "unchanged"
+	StatusFailed    Status = "failed"
+)
+
+// ResourceKey identifies a resource independently of its position in the
+// rendered output.
+type ResourceKey struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+type ResourceChange struct {
+	Key    ResourceKey
+	Status Status
+	Diff   string
+}
+
+type AppChange struct {
+	Name   string
+	Status Status
+	Error  string
+	// BaseError is set when the application failed to render only at the
+	// base revision. It is informational: the head resources are compared
+	// with an empty base.
+	BaseError string
+	Params    []ParamChange
+	Resources []ResourceChange
+}
+
+type ChangeSet struct {
+	Apps []AppChange
+}
+
+// Tree holds the rendered resources of an output directory grouped by
+// application. Files that are not listed in the output index are grouped by
+// their own path instead.
+type Tree map[string][]manifest.Resource
+
+func (k ResourceKey) String() string {
+	id := k.Name
+	if k.Namespace != "" {
+		id = k.Namespace + "/" + k.Name
+	}
+	return fmt.Sprintf("%s %s %s", k.APIVersion, k.Kind, id)
+}
+
+func keyOf(r manifest.Resource) ResourceKey {
+	return ResourceKey{APIVersion: r.APIVersion, Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}
+}
+
+// ReadDir loads all YAML files of an output directory, keyed by their
+// slash-separated path relative to dir.
+func ReadDir(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isTreeFile(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output directory %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// Owners returns the file-to-application mapping recorded in the output
+// index contained in files. It is empty when files has no index.
+func Owners(files map[string][]byte) (map[string]string, error) {
+	owners := make(map[string]string)
+	data, ok := files[output.IndexFile]
+	if !ok {
+		return owners, nil
+	}
+	idx, err := output.ParseIndex(data)
+	if err != nil {
+		return nil, err
+	}
+	for app, appFiles := range idx.Applications {
+		for _, file := range appFiles {
+			owners[file] = app
+		}
+	}
+	return owners, nil
+}
+
+// LoadTree groups the resources of the given files by application according
+// to owners, typically the merged Owners of both sides of a comparison.
+func LoadTree(files map[string][]byte, owners map[string]string) (Tree, error) {
+	tree := make(Tree)
+	for name, data := range files {
+		if !isTreeFile(name) || name == output.IndexFile || path.Base(name) == output.KustomizationFile {
+			continue
+		}
+		resources, err := manifest.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		app, ok := owners[name]
+		if !ok {
+			app = strings.TrimSuffix(name, path.Ext(name))
+		}
+		tree[app] = append(tree[app], resources...)
+	}
+	return tree, nil
+}
+
+// Compare matches the resources of every application in base and head by
+// apiVersion, kind, namespace and name and diffs their content. Applications
+// listed in failed are reported as failed instead of removed.
+func Compare(base, head Tree, failed map[string]error) *ChangeSet {
+	names := make(map[string]bool)
+	for app := range base {
+		names[app] = true
+	}
+	for app := range head {
+		names[app] = true
+	}
+	for app := range failed {
+		names[app] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for app := range names {
+		sorted = append(sorted, app)
+	}
+	sort.Strings(sorted)
+
+	changes := &ChangeSet{}
+	for _, app := range sorted {
+		if err, ok := failed[app]; ok {
+			changes.Apps = append(changes.Apps, AppChange{Name: app, Status: StatusFailed, Error: err.Error()})
+			continue
+		}
+		baseResources, inBase := base[app]
+		headResources, inHead := head[app]
+		change := AppChange{Name: app, Resources: compareResources(app, baseResources, headResources)}
+		switch {
+		case !inBase:
+			change.Status = StatusAdded
+		case !inHead:
+			change.Status = StatusRemoved
+		case len(change.Resources) > 0:
+			change.Status = StatusModified
+		default:
+			change.Status = StatusUnchanged
+		}
+		changes.Apps = append(changes.Apps, change)
+	}
+	return changes
+}
+
+func compareResources(app string, base, head []manifest.Resource) []ResourceChange {
+	baseByKey, baseKeys := indexResources(base)
+	headByKey, headKeys := indexResources(head)
+
+	keys := append([]ResourceKey{}, baseKeys...)
+	for _, key := range headKeys {
+		if _, ok := baseByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	var changes []ResourceChange
+	for _, key := range keys {
+		from, inBase := baseByKey[key]
+		to, inHead := headByKey[key]
+		fromName, toName := "a/"+app+"/"+key.String(), "b/"+app+"/"+key.String()
+		change := ResourceChange{Key: key}
+		switch {
+		case !inBase:
+			change.Status = StatusAdded
+			fromName = "/dev/null"
+		case !inHead:
+			change.Status = StatusRemoved
+			toName = "/dev/null"
+		default:
+			change.Status = StatusModified
+		}
+		change.Diff = Unified(fromName, toName, from, to, DefaultContext)
+		if change.Diff != "" {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func indexResources(resources []manifest.Resource) (map[ResourceKey][]byte, []ResourceKey) {
+	byKey := make(map[ResourceKey][]byte, len(resources))
+	var keys []ResourceKey
+	for _, r := range resources {
+		key := keyOf(r)
+		for n := 2; ; n++ {
+			if _, taken := byKey[key]; !taken {
+				break
+			}
+			key = keyOf(r)
+			key.Name = fmt.Sprintf("%s#%d", r.Name, n)
+		}
+		byKey[key] = r.Raw
+		keys = append(keys, key)
+	}
+	return byKey, keys
+}
+
+// HasChanges reports whether any application was added, removed or modified.
+// Applications that failed to render are not changes, see Count(StatusFailed).
+func (c *ChangeSet) HasChanges() bool {
+	for _, app := range c.Apps {
+		if app.Status != StatusUnchanged && app.Status != StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// AddBaseErrors records the errors of applications that failed to render
+// at the base revision only.
+func (c *ChangeSet) AddBaseErrors(failed map[string]error) {
+	for app, err := range failed {
+		found := false
+		for i := range c.Apps {
+			if c.Apps[i].Name == app {
+				c.Apps[i].BaseError = err.Error()
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Apps = append(c.Apps, AppChange{Name: app, Status: StatusRemoved, BaseError: err.Error()})
+		}
+	}
+	sort.SliceStable(c.Apps, func(i, j int) bool { return c.Apps[i].Name < c.Apps[j].Name })
+}
+
+func (c *ChangeSet) Count(status Status) int {
+	n := 0
+	for _, app := range c.Apps {
+		if app.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteUnified writes the per-resource diffs of all changed applications
+// followed by a one-line summary.
+func (c *ChangeSet) WriteUnified(w io.Writer) error {
+	for _, app := range c.Apps {
+		switch app.Status {
+		case StatusUnchanged:
+			continue
+		case StatusFailed:
+			if _, err := fmt.Fprintf(w, "# %s: failed to render: %s\n", app.Name, app.Error); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# %s: %s (%d resources changed)\n", app.Name, app.Status, len(app.Resources)); err != nil {
+			return err
+		}
+		if app.BaseError != "" {
+			if _, err := fmt.Fprintf(w, "#   failed to render at the base revision: %s\n", app.BaseError); err != nil {
+				return err
+			}
+		}
+		for _, p := range app.Params {
+			if _, err := fmt.Fprintf(w, "#   %s: %q -> %q\n", p.Name, p.Base, p.Head); err != nil {
+				return err
+			}
+		}
+		for _, r := range app.Resources {
+			if _, err := io.WriteString(w, r.Diff); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "# %d added, %d removed, %d modified, %d failed, %d unchanged applications\n",
+		c.Count(StatusAdded), c.Count(StatusRemoved), c.Count(StatusModified), c.Count(StatusFailed), c.Count(StatusUnchanged))
+	return err
+}
+
+func isTreeFile(name string) bool {
+	if path.Base(filepath.ToSlash(name)) == output.IndexFile {
+		return true
+	}
+	ext := path.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
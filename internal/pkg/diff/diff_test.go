@@ -0,0 +1,169 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"roar/internal/pkg/argo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+
+	expected := `--- old
++++ new
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`
+	require.Equal(t, expected, Unified("old", "new", []byte(a), []byte(b), DefaultContext))
+	require.Empty(t, Unified("old", "new", []byte(a), []byte(a), DefaultContext))
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+
+	out := Unified("old", "new", []byte(a), []byte(b), 1)
+	require.Contains(t, out, "@@ -1,2 +1,2 @@\n-1\n+one\n 2\n")
+	require.Contains(t, out, "@@ -11,2 +11,2 @@\n 11\n-12\n+twelve\n")
+}
+
+func TestCompare(t *testing.T) {
+	index := []byte("applications:\n  app-one:\n    - dev/app-one.yaml\n  app-two:\n    - dev/app-two.yaml\n")
+	base := map[string][]byte{
+		".roar-index.yaml": index,
+		"dev/app-one.yaml": []byte(`---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: gone
+`),
+		"dev/app-two.yaml": []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: same\n"),
+	}
+	head := map[string][]byte{
+		"dev/app-one.yaml": []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  port: 8080
+`),
+		"dev/app-two.yaml":   []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: same\n"),
+		"dev/app-three.yaml": []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\n"),
+	}
+
+	owners, err := Owners(base)
+	require.NoError(t, err)
+	baseTree, err := LoadTree(base, owners)
+	require.NoError(t, err)
+	headTree, err := LoadTree(head, owners)
+	require.NoError(t, err)
+
+	changes := Compare(baseTree, headTree, map[string]error{"app-four": errors.New("boom")})
+	require.True(t, changes.HasChanges())
+	require.Equal(t, 1, changes.Count(StatusFailed))
+	require.Len(t, changes.Apps, 4)
+
+	require.Equal(t, "app-four", changes.Apps[0].Name)
+	require.Equal(t, StatusFailed, changes.Apps[0].Status)
+
+	appOne := changes.Apps[1]
+	require.Equal(t, "app-one", appOne.Name)
+	require.Equal(t, StatusModified, appOne.Status)
+	require.Len(t, appOne.Resources, 3)
+	statuses := map[string]Status{}
+	for _, r := range appOne.Resources {
+		statuses[r.Key.Name] = r.Status
+	}
+	require.Equal(t, map[string]Status{"gone": StatusRemoved, "new": StatusAdded, "svc": StatusModified}, statuses)
+
+	require.Equal(t, StatusUnchanged, changes.Apps[2].Status)
+	require.Equal(t, "dev/app-three", changes.Apps[3].Name)
+	require.Equal(t, StatusAdded, changes.Apps[3].Status)
+
+	var buf bytes.Buffer
+	require.NoError(t, changes.WriteUnified(&buf))
+	require.Contains(t, buf.String(), "-  port: 80\n+  port: 8080\n")
+	require.Contains(t, buf.String(), "--- /dev/null\n+++ b/app-one/v1 ConfigMap new\n")
+	require.Contains(t, buf.String(), "# 1 added, 0 removed, 1 modified, 1 failed, 1 unchanged applications\n")
+
+	// An application that failed to render is not a change
+	failed := Compare(baseTree, baseTree, map[string]error{"app-four": errors.New("boom")})
+	require.False(t, failed.HasChanges())
+	require.Equal(t, 1, failed.Count(StatusFailed))
+}
+
+func TestAddBaseErrors(t *testing.T) {
+	head := Tree{"app-one": {{APIVersion: "v1", Kind: "ConfigMap", Name: "cm", Raw: []byte("kind: ConfigMap\n")}}}
+
+	changes := Compare(Tree{}, head, nil)
+	changes.AddBaseErrors(map[string]error{"app-one": errors.New("boom"), "app-gone": errors.New("bang")})
+	require.Equal(t, 0, changes.Count(StatusFailed))
+	require.Len(t, changes.Apps, 2)
+	require.Equal(t, AppChange{Name: "app-gone", Status: StatusRemoved, BaseError: "bang"}, changes.Apps[0])
+	require.Equal(t, StatusAdded, changes.Apps[1].Status)
+	require.Equal(t, "boom", changes.Apps[1].BaseError)
+
+	var buf bytes.Buffer
+	require.NoError(t, changes.WriteUnified(&buf))
+	require.Contains(t, buf.String(), "# app-one: added (1 resources changed)\n#   failed to render at the base revision: boom\n")
+	require.Contains(t, buf.String(), "0 failed")
+}
+
+func TestCompareParams(t *testing.T) {
+	base := argo.Application{
+		RepoURL:        "https://repo",
+		Path:           "stable/app",
+		TargetRevision: "main",
+		ValuesFiles:    []string{"values.yaml"},
+		Setters:        map[string]string{"global.image.tag": "1.0", "removed": "x"},
+	}
+	head := argo.Application{
+		RepoURL:        "https://repo",
+		Path:           "stable/app",
+		TargetRevision: "v1.1",
+		ValuesFiles:    []string{"values.yaml", "values.prod.yaml"},
+		Setters:        map[string]string{"global.image.tag": "1.1", "added": "y"},
+	}
+
+	require.Equal(t, []ParamChange{
+		{Name: "targetRevision", Base: "main", Head: "v1.1"},
+		{Name: "valuesFiles", Base: "values.yaml", Head: "values.yaml, values.prod.yaml"},
+		{Name: "set added", Base: "", Head: "y"},
+		{Name: "set global.image.tag", Base: "1.0", Head: "1.1"},
+		{Name: "set removed", Base: "x", Head: ""},
+	}, CompareParams(base, head))
+	require.Empty(t, CompareParams(base, base))
+
+	changes := &ChangeSet{Apps: []AppChange{{Name: "app", Status: StatusUnchanged}}}
+	changes.AddParams("app", CompareParams(base, head))
+	require.Equal(t, StatusModified, changes.Apps[0].Status)
+	require.True(t, changes.HasChanges())
+}
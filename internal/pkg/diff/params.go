@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/argo"
+)
+
+// ParamChange describes a rendering parameter of an application that differs
+// between two revisions of the app-of-apps chart.
+type ParamChange struct {
+	Name string
+	Base string
+	Head string
+}
+
+// CompareParams returns the differences in the source, revision, values files
+// and setters of two versions of the same application.
+func CompareParams(base, head argo.Application) []ParamChange {
+	var changes []ParamChange
+	add := func(name, from, to string) {
+		if from != to {
+			changes = append(changes, ParamChange{Name: name, Base: from, Head: to})
+		}
+	}
+
+	add("repoURL", base.RepoURL, head.RepoURL)
+	add("path", base.Path, head.Path)
+	add("targetRevision", base.TargetRevision, head.TargetRevision)
+	add("valuesFiles", strings.Join(base.ValuesFiles, ", "), strings.Join(head.ValuesFiles, ", "))
+
+	keys := make(map[string]bool)
+	for key := range base.Setters {
+		keys[key] = true
+	}
+	for key := range head.Setters {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		add("set "+key, base.Setters[key], head.Setters[key])
+	}
+	return changes
+}
+
+// AddParams attaches parameter changes to an application of the change set,
+// marking it as modified when its manifests did not change.
+func (c *ChangeSet) AddParams(app string, params []ParamChange) {
+	if len(params) == 0 {
+		return
+	}
+	for i := range c.Apps {
+		if c.Apps[i].Name != app {
+			continue
+		}
+		c.Apps[i].Params = append(c.Apps[i].Params, params...)
+		if c.Apps[i].Status == StatusUnchanged {
+			c.Apps[i].Status = StatusModified
+		}
+		return
+	}
+	c.Apps = append(c.Apps, AppChange{Name: app, Status: StatusModified, Params: params})
+	sort.SliceStable(c.Apps, func(i, j int) bool { return c.Apps[i].Name < c.Apps[j].Name })
+}
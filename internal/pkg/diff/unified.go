@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const DefaultContext = 3
+
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// Unified returns a unified diff between a and b, or an empty string when
+// they are equal. fromName and toName are used in the `---`/`+++` headers.
+func Unified(fromName, toName string, a, b []byte, context int) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	ops := lineOps(string(a), string(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	aLine, bLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for start := 0; start < len(ops); {
+		first := nextChange(ops, start)
+		if first < 0 {
+			break
+		}
+		last := first
+		for {
+			next := nextChange(ops, last+1)
+			if next < 0 || next-last > 2*context {
+				break
+			}
+			last = next
+		}
+		from := max(first-context, 0)
+		to := min(last+context+1, len(ops))
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(aLine[from], aLine[to]-aLine[from]),
+			hunkRange(bLine[from], bLine[to]-bLine[from]))
+		for _, op := range ops[from:to] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
+
+func lineOps(a, b string) []lineOp {
+	dmp := diffmatchpatch.New()
+	runesA, runesB, lines := dmp.DiffLinesToRunes(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMainRunes(runesA, runesB, false), lines)
+
+	var ops []lineOp
+	for _, d := range diffs {
+		kind := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			kind = '-'
+		case diffmatchpatch.DiffInsert:
+			kind = '+'
+		}
+		for _, line := range strings.SplitAfter(d.Text, "\n") {
+			if line == "" {
+				continue
+			}
+			ops = append(ops, lineOp{kind: kind, text: strings.TrimSuffix(line, "\n")})
+		}
+	}
+	return ops
+}
+
+func nextChange(ops []lineOp, from int) int {
+	for i := from; i < len(ops); i++ {
+		if ops[i].kind != ' ' {
+			return i
+		}
+	}
+	return -1
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,133 @@
+package duplicates
+
+import (
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/manifest"
+)
+
+// inCluster is the name Argo CD gives to the cluster it runs in; the
+// destination may refer to it by name or by server URL.
+const (
+	inCluster       = "in-cluster"
+	inClusterServer = "https://kubernetes.default.svc"
+)
+
+// Key identifies a resource in a cluster. Namespace is empty for
+// cluster-scoped resources.
+type Key struct {
+	Cluster   string
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (k Key) String() string {
+	s := k.Kind
+	if k.Group != "" {
+		s += "." + k.Group
+	}
+	s += " "
+	if k.Namespace != "" {
+		s += k.Namespace + "/"
+	}
+	return s + k.Name
+}
+
+// Duplicate is a resource rendered by more than one Application.
+type Duplicate struct {
+	Key
+	Applications []string
+}
+
+// Index maps the resources rendered by Applications to their owners.
+type Index struct {
+	owners map[Key][]string
+}
+
+func NewIndex() *Index {
+	return &Index{owners: make(map[Key][]string)}
+}
+
+// Add records the resources rendered by app. Resources without a namespace
+// are deployed to the destination namespace of the Application, unless they
+// are cluster-scoped.
+func (x *Index) Add(app argo.Application, resources []manifest.Resource) {
+	cluster := Cluster(app.Destination)
+	for _, resource := range resources {
+		if resource.Kind == "" || resource.Name == "" {
+			continue
+		}
+		key := Key{
+			Cluster: cluster,
+			Group:   manifest.Group(resource.APIVersion),
+			Kind:    resource.Kind,
+			Name:    resource.Name,
+		}
+		if !manifest.IsClusterScoped(resource.APIVersion, resource.Kind) {
+			key.Namespace = resource.Namespace
+			if key.Namespace == "" {
+				key.Namespace = app.Destination.Namespace
+			}
+		}
+		owners := x.owners[key]
+		if name := app.QualifiedName(); len(owners) == 0 || owners[len(owners)-1] != name {
+			x.owners[key] = append(owners, name)
+		}
+	}
+}
+
+// Duplicates returns the resources rendered by more than one Application,
+// sorted by key. A resource rendered twice by the same Application is not
+// reported: helm fails on such charts or they are resolved within one sync.
+func (x *Index) Duplicates() []Duplicate {
+	var duplicates []Duplicate
+	for key, owners := range x.owners {
+		apps := unique(owners)
+		if len(apps) < 2 {
+			continue
+		}
+		duplicates = append(duplicates, Duplicate{Key: key, Applications: apps})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		a, b := duplicates[i].Key, duplicates[j].Key
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.String() < b.String()
+	})
+	return duplicates
+}
+
+// Cluster returns the cluster an Application is deployed to: the destination
+// name if set, otherwise its server URL. The cluster Argo CD runs in is
+// always called in-cluster.
+func Cluster(destination argo.Destination) string {
+	cluster := destination.Name
+	if cluster == "" {
+		cluster = strings.TrimSuffix(destination.Server, "/")
+	}
+	if cluster == inClusterServer {
+		return inCluster
+	}
+	return cluster
+}
+
+func unique(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var out []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
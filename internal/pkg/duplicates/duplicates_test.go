@@ -0,0 +1,105 @@
+package duplicates
+
+import (
+	"testing"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/manifest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Duplicates(t *testing.T) {
+	application := func(name, server, namespace string) argo.Application {
+		return argo.Application{Name: name, Destination: argo.Destination{Server: server, Namespace: namespace}}
+	}
+	configMap := func(namespace, name string) manifest.Resource {
+		return manifest.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: namespace, Name: name}
+	}
+	const local = "https://kubernetes.default.svc"
+
+	testCases := []struct {
+		name     string
+		apps     []argo.Application
+		rendered [][]manifest.Resource
+		expected []Duplicate
+	}{
+		{
+			name:     "explicit namespace",
+			apps:     []argo.Application{application("a", local, "apps"), application("b", local, "other")},
+			rendered: [][]manifest.Resource{{configMap("default", "shared")}, {configMap("default", "shared")}},
+			expected: []Duplicate{{Key: Key{Cluster: "in-cluster", Kind: "ConfigMap", Namespace: "default", Name: "shared"}, Applications: []string{"a", "b"}}},
+		},
+		{
+			name:     "destination namespace",
+			apps:     []argo.Application{application("a", local, "apps"), application("b", local, "apps")},
+			rendered: [][]manifest.Resource{{configMap("", "shared")}, {configMap("apps", "shared")}},
+			expected: []Duplicate{{Key: Key{Cluster: "in-cluster", Kind: "ConfigMap", Namespace: "apps", Name: "shared"}, Applications: []string{"a", "b"}}},
+		},
+		{
+			name:     "different destination namespaces",
+			apps:     []argo.Application{application("a", local, "one"), application("b", local, "two")},
+			rendered: [][]manifest.Resource{{configMap("", "shared")}, {configMap("", "shared")}},
+		},
+		{
+			name:     "different clusters",
+			apps:     []argo.Application{application("a", local, "apps"), application("b", "https://prod.example.com", "apps")},
+			rendered: [][]manifest.Resource{{configMap("", "shared")}, {configMap("", "shared")}},
+		},
+		{
+			name: "in-cluster by name and by server",
+			apps: []argo.Application{
+				{Name: "a", Destination: argo.Destination{Name: "in-cluster", Namespace: "apps"}},
+				application("b", local+"/", "apps"),
+			},
+			rendered: [][]manifest.Resource{{configMap("", "shared")}, {configMap("", "shared")}},
+			expected: []Duplicate{{Key: Key{Cluster: "in-cluster", Kind: "ConfigMap", Namespace: "apps", Name: "shared"}, Applications: []string{"a", "b"}}},
+		},
+		{
+			name: "cluster-scoped ignores the destination namespace",
+			apps: []argo.Application{application("a", local, "one"), application("b", local, "two")},
+			rendered: [][]manifest.Resource{
+				{{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "reader"}},
+				{{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "reader"}},
+			},
+			expected: []Duplicate{{Key: Key{Cluster: "in-cluster", Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "reader"}, Applications: []string{"a", "b"}}},
+		},
+		{
+			name: "same kind in other group",
+			apps: []argo.Application{application("a", local, "apps"), application("b", local, "apps")},
+			rendered: [][]manifest.Resource{
+				{{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "web"}},
+				{{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "web"}},
+			},
+		},
+		{
+			name: "different versions of a group",
+			apps: []argo.Application{application("a", local, "apps"), application("b", local, "apps")},
+			rendered: [][]manifest.Resource{
+				{{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler", Name: "web"}},
+				{{APIVersion: "autoscaling/v1", Kind: "HorizontalPodAutoscaler", Name: "web"}},
+			},
+			expected: []Duplicate{{Key: Key{Cluster: "in-cluster", Group: "autoscaling", Kind: "HorizontalPodAutoscaler", Namespace: "apps", Name: "web"}, Applications: []string{"a", "b"}}},
+		},
+		{
+			name:     "repeated within one application",
+			apps:     []argo.Application{application("a", local, "apps")},
+			rendered: [][]manifest.Resource{{configMap("", "shared"), configMap("apps", "shared")}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			index := NewIndex()
+			for i, app := range tc.apps {
+				index.Add(app, tc.rendered[i])
+			}
+			require.Equal(t, tc.expected, index.Duplicates())
+		})
+	}
+}
+
+func TestKey_String(t *testing.T) {
+	require.Equal(t, "ConfigMap default/shared", Key{Kind: "ConfigMap", Namespace: "default", Name: "shared"}.String())
+	require.Equal(t, "ClusterRole.rbac.authorization.k8s.io reader", Key{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "reader"}.String())
+}
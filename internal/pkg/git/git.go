@@ -2,29 +2,271 @@ package git
 
 import (
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"roar/internal/pkg/logger"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-func Clone(repoURL, revision, targetPath string) error {
+// Auth configures access to remote repositories. The zero value uses
+// ssh-agent for SSH URLs and anonymous access for all others.
+type Auth struct {
+	// SSHKeyFile is a private key used for SSH URLs instead of ssh-agent.
+	SSHKeyFile       string
+	SSHKeyPassphrase string
+}
+
+// Check loads the SSH key, so that a wrong path or passphrase is reported
+// before anything is cloned.
+func (a Auth) Check() error {
+	_, err := a.sshKeys()
+	return err
+}
+
+func (a Auth) sshKeys() (transport.AuthMethod, error) {
+	if a.SSHKeyFile == "" {
+		return nil, nil
+	}
+	keys, err := gitssh.NewPublicKeysFromFile("git", a.SSHKeyFile, a.SSHKeyPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH key %s: %w", a.SSHKeyFile, err)
+	}
+	return keys, nil
+}
+
+// method returns the authentication for repoURL: the SSH key for SSH URLs,
+// nil otherwise.
+func (a Auth) method(repoURL string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil || endpoint.Protocol != "ssh" {
+		return nil, nil
+	}
+	return a.sshKeys()
+}
+
+// Clone performs a shallow clone of a single branch and returns the SHA of
+// the checked out commit.
+func Clone(repoURL, revision, targetPath string, auth Auth) (string, error) {
 	logCtx := logger.Log.WithField("repo", repoURL).WithField("revision", revision)
 	logCtx.Info("Cloning repository using go-git...")
 
+	method, err := auth.method(repoURL)
+	if err != nil {
+		return "", err
+	}
 	opts := &git.CloneOptions{
 		URL:           repoURL,
+		Auth:          method,
 		ReferenceName: plumbing.NewBranchReferenceName(revision),
 		SingleBranch:  true,
 		Depth:         1,
 		Progress:      nil,
 	}
 
-	_, err := git.PlainClone(targetPath, false, opts)
+	repo, err := git.PlainClone(targetPath, false, opts)
+	if err != nil {
+		return "", fmt.Errorf("go-git clone failed for %s (revision %s): %w", repoURL, revision, err)
+	}
+	head, err := repo.Head()
 	if err != nil {
-		return fmt.Errorf("go-git clone failed for %s (revision %s): %w", repoURL, revision, err)
+		return "", fmt.Errorf("failed to resolve HEAD of %s: %w", targetPath, err)
 	}
 
 	logCtx.Info("Successfully cloned repository.")
-	return nil
+	return head.Hash().String(), nil
+}
+
+// ReadFiles returns the content of all files below dir (relative to the
+// repository root, empty for the whole tree) at the given revision of a local
+// repository. Keys are slash-separated paths relative to dir.
+func ReadFiles(repoPath, revision, dir string) (map[string][]byte, error) {
+	tree, err := revisionTree(repoPath, revision)
+	if err != nil {
+		return nil, err
+	}
+	if dir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/"); dir != "" {
+		tree, err = tree.Tree(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s at revision %s: %w", dir, revision, err)
+		}
+	}
+
+	files := make(map[string][]byte)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		files[f.Name] = []byte(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files at revision %s: %w", revision, err)
+	}
+	return files, nil
+}
+
+// RepoRoot returns the root of the working tree of the git repository that
+// contains path.
+func RepoRoot(path string) (string, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to find git repository for %s: %w", path, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to open worktree of %s: %w", path, err)
+	}
+	return wt.Filesystem.Root(), nil
+}
+
+// RemoteURLs returns the URLs of all remotes of the git repository that
+// contains path.
+func RemoteURLs(path string) ([]string, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find git repository for %s: %w", path, err)
+	}
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remotes of %s: %w", path, err)
+	}
+	var urls []string
+	for _, remote := range remotes {
+		urls = append(urls, remote.Config().URLs...)
+	}
+	return urls, nil
+}
+
+// ResolveBranch returns the SHA of the commit a branch of a remote repository
+// points to, like `git ls-remote`, without cloning it.
+func ResolveBranch(repoURL, revision string, auth Auth) (string, error) {
+	method, err := auth.method(repoURL)
+	if err != nil {
+		return "", err
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	refs, err := remote.List(&git.ListOptions{Auth: method})
+	if err != nil {
+		return "", fmt.Errorf("failed to list references of %s: %w", repoURL, err)
+	}
+	name := plumbing.NewBranchReferenceName(revision)
+	for _, ref := range refs {
+		if ref.Name() == name {
+			return ref.Hash().String(), nil
+		}
+	}
+	return "", fmt.Errorf("branch %s not found in %s", revision, repoURL)
+}
+
+// Export writes the files of the given revision of a local repository into
+// targetPath, similar to `git worktree add --detach` without the git metadata.
+// It returns the resolved commit SHA.
+func Export(repoPath, revision, targetPath string) (string, error) {
+	logCtx := logger.Log.WithField("repo", repoPath).WithField("revision", revision)
+	logCtx.Infof("Exporting revision to %s", targetPath)
+
+	repo, hash, err := resolveRevision(repoPath, revision)
+	if err != nil {
+		return "", err
+	}
+	tree, err := commitTree(repo, hash)
+	if err != nil {
+		return "", err
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		target := filepath.Join(targetPath, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		if f.Mode == filemode.Symlink {
+			return os.Symlink(content, target)
+		}
+		perm := os.FileMode(0644)
+		if f.Mode == filemode.Executable {
+			perm = 0755
+		}
+		return os.WriteFile(target, []byte(content), perm)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to export revision %s to %s: %w", revision, targetPath, err)
+	}
+	return hash.String(), nil
+}
+
+func revisionTree(repoPath, revision string) (*object.Tree, error) {
+	repo, hash, err := resolveRevision(repoPath, revision)
+	if err != nil {
+		return nil, err
+	}
+	return commitTree(repo, hash)
+}
+
+func resolveRevision(repoPath, revision string) (*git.Repository, plumbing.Hash, error) {
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, plumbing.ZeroHash, fmt.Errorf("failed to open git repository %s: %w", repoPath, err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, plumbing.ZeroHash, fmt.Errorf("failed to resolve revision %s: %w", revision, err)
+	}
+	return repo, *hash, nil
+}
+
+func commitTree(repo *git.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit %s: %w", hash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tree of %s: %w", hash, err)
+	}
+	return tree, nil
+}
+
+// ChangedFiles returns the paths, relative to the repository root, of all
+// files that differ between two revisions of a local repository. Renamed files
+// are reported under both names.
+func ChangedFiles(repoPath, base, head string) ([]string, error) {
+	baseTree, err := revisionTree(repoPath, base)
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := revisionTree(repoPath, head)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..%s: %w", base, head, err)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	return files, nil
 }
@@ -2,9 +2,11 @@
 package helm
 
 import (
+	"bytes"
 	"fmt"
 	"os/exec"
 	"roar/internal/pkg/logger"
+	"sort"
 	"strings"
 )
 
@@ -13,9 +15,27 @@ type RenderOptions struct {
 	ChartPath   string
 	ValuesFiles []string
 	SetValues   map[string]string
+	KubeVersion string
 }
 
-func Template(opts RenderOptions) ([]byte, error) {
+// Error is returned when the helm binary fails; Stderr holds its diagnostic
+// output.
+type Error struct {
+	Err    error
+	Stderr string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("helm template failed: %v\nOutput:\n%s", e.Err, e.Stderr)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Args returns the arguments of the `helm template` call for opts. Setters
+// are sorted by key so the invocation is reproducible.
+func Args(opts RenderOptions) []string {
 	args := []string{"template"}
 	if opts.ReleaseName != "" {
 		args = append(args, opts.ReleaseName)
@@ -24,15 +44,33 @@ func Template(opts RenderOptions) ([]byte, error) {
 	for _, valuesFile := range opts.ValuesFiles {
 		args = append(args, "--values", valuesFile)
 	}
-	for key, value := range opts.SetValues {
-		setValue := strings.Join([]string{key, value}, "=")
+	keys := make([]string, 0, len(opts.SetValues))
+	for key := range opts.SetValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		setValue := strings.Join([]string{key, opts.SetValues[key]}, "=")
 		args = append(args, "--set", setValue)
 	}
+	if opts.KubeVersion != "" {
+		args = append(args, "--kube-version", opts.KubeVersion)
+	}
+	return args
+}
+
+func Template(opts RenderOptions) ([]byte, error) {
+	args := Args(opts)
 	cmd := exec.Command("helm", args...)
 	logger.Log.WithField("cmd", cmd.String()).Info("[CMD]")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("helm template failed: %w\nOutput:\n%s", err, string(output))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &Error{Err: err, Stderr: stderr.String()}
+	}
+	if stderr.Len() > 0 {
+		logger.Log.WithField("chart", opts.ChartPath).Warnf("helm template: %s", strings.TrimSpace(stderr.String()))
 	}
-	return output, nil
+	return stdout.Bytes(), nil
 }
@@ -0,0 +1,23 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgs(t *testing.T) {
+	args := Args(RenderOptions{
+		ReleaseName: "api",
+		ChartPath:   "/repo/.helm",
+		ValuesFiles: []string{"a.yaml", "b.yaml"},
+		SetValues:   map[string]string{"z": "1", "a": "2", "global.env": "dev"},
+		KubeVersion: "1.29.0",
+	})
+	require.Equal(t, []string{
+		"template", "api", "/repo/.helm",
+		"--values", "a.yaml", "--values", "b.yaml",
+		"--set", "a=2", "--set", "global.env=dev", "--set", "z=1",
+		"--kube-version", "1.29.0",
+	}, args)
+}
@@ -0,0 +1,171 @@
+package images
+
+import (
+	"fmt"
+	"strings"
+
+	"roar/internal/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TypeContainer          = "container"
+	TypeInitContainer      = "initContainer"
+	TypeEphemeralContainer = "ephemeralContainer"
+	// TypeCustom marks images selected by a configured JSONPath.
+	TypeCustom = "custom"
+)
+
+// podSpecPaths are the paths to the pod spec of the built-in workloads.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+var containerFields = []struct {
+	field string
+	kind  string
+}{
+	{"initContainers", TypeInitContainer},
+	{"containers", TypeContainer},
+	{"ephemeralContainers", TypeEphemeralContainer},
+}
+
+// Image is a container image referenced by a rendered resource.
+type Image struct {
+	Resource string `json:"resource"`
+	// Container is the name of the container; it may be empty for images
+	// selected by a JSONPath.
+	Container string `json:"container,omitempty"`
+	Type      string `json:"type"`
+	// Path is the JSONPath that selected an image of the custom type.
+	Path       string `json:"path,omitempty"`
+	Image      string `json:"image"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	// Mutable is set when mutable tags are flagged and the image is not
+	// pinned by a digest.
+	Mutable bool `json:"mutable,omitempty"`
+}
+
+// Pinned reports whether the image is referenced by digest, so that the
+// deployed content cannot change when a tag is pushed again.
+func (i Image) Pinned() bool {
+	return i.Digest != ""
+}
+
+// Extractor finds the images in rendered resources: in the pod specs of the
+// built-in workloads and at the configured JSONPaths of other kinds.
+type Extractor struct {
+	paths map[string][]Path
+}
+
+// NewExtractor returns an extractor with additional JSONPaths per kind. Every
+// spec has the form KIND=JSONPATH, e.g.
+// "Rollout=.spec.template.spec.containers[*].image"; a kind may be given
+// several times.
+func NewExtractor(specs []string) (*Extractor, error) {
+	e := &Extractor{paths: make(map[string][]Path)}
+	for _, spec := range specs {
+		kind, expr, ok := strings.Cut(spec, "=")
+		kind = strings.TrimSpace(kind)
+		if !ok || kind == "" {
+			return nil, fmt.Errorf("invalid image path %q, expected KIND=JSONPATH", spec)
+		}
+		path, err := ParsePath(expr)
+		if err != nil {
+			return nil, err
+		}
+		e.paths[kind] = append(e.paths[kind], path)
+	}
+	return e, nil
+}
+
+// Extract returns the images referenced by a rendered resource, in the order
+// they appear in it. An image used by the same container twice is listed
+// once.
+func (e *Extractor) Extract(resource manifest.Resource) ([]Image, error) {
+	specPath, builtin := podSpecPaths[resource.Kind]
+	custom := e.paths[resource.Kind]
+	if !builtin && len(custom) == 0 {
+		return nil, nil
+	}
+	var decoded any
+	if err := yaml.Unmarshal(resource.Raw, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", resource, err)
+	}
+
+	var images []Image
+	seen := make(map[string]bool)
+	add := func(image Image) {
+		key := image.Container + "\x00" + image.Image
+		if image.Image == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		image.Resource = resource.String()
+		image.Repository, image.Tag, image.Digest = ParseReference(image.Image)
+		images = append(images, image)
+	}
+
+	if builtin {
+		spec, _ := lookup(decoded, specPath).(map[string]any)
+		for _, f := range containerFields {
+			containers, _ := spec[f.field].([]any)
+			for _, c := range containers {
+				container, _ := c.(map[string]any)
+				name, _ := container["name"].(string)
+				image, _ := container["image"].(string)
+				add(Image{Container: name, Type: f.kind, Image: image})
+			}
+		}
+	}
+	for _, path := range custom {
+		for _, m := range path.evaluate(decoded) {
+			image, ok := m.value.(string)
+			if !ok {
+				continue
+			}
+			name, _ := m.parent["name"].(string)
+			add(Image{Container: name, Type: TypeCustom, Path: path.String(), Image: image})
+		}
+	}
+	return images, nil
+}
+
+func lookup(value any, path []string) any {
+	for _, key := range path {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// ParseReference splits an image reference into the repository, the tag and
+// the digest: "registry:5000/app:1.2@sha256:..." yields "registry:5000/app",
+// "1.2" and "sha256:...". A reference without tag and digest implies the
+// latest tag, which is left empty here.
+func ParseReference(ref string) (repository, tag, digest string) {
+	repository = strings.TrimSpace(ref)
+	if i := strings.IndexByte(repository, '@'); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	// A colon after the last slash separates the tag; one before it belongs
+	// to the registry port.
+	if i := strings.LastIndexByte(repository, ':'); i > strings.LastIndexByte(repository, '/') {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
@@ -0,0 +1,224 @@
+package images
+
+import (
+	"bytes"
+	"testing"
+
+	"roar/internal/pkg/manifest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseResource(t *testing.T, doc string) manifest.Resource {
+	t.Helper()
+	resources, err := manifest.Parse([]byte(doc))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	return resources[0]
+}
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		ref, repository, tag, digest string
+	}{
+		{ref: "nginx", repository: "nginx"},
+		{ref: "nginx:1.25", repository: "nginx", tag: "1.25"},
+		{ref: "registry.example.com:5000/team/app", repository: "registry.example.com:5000/team/app"},
+		{ref: "registry.example.com:5000/team/app:v2", repository: "registry.example.com:5000/team/app", tag: "v2"},
+		{ref: "app@sha256:0123abcd", repository: "app", digest: "sha256:0123abcd"},
+		{ref: "ghcr.io/org/app:1.0@sha256:0123abcd", repository: "ghcr.io/org/app", tag: "1.0", digest: "sha256:0123abcd"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			repository, tag, digest := ParseReference(tc.ref)
+			require.Equal(t, tc.repository, repository)
+			require.Equal(t, tc.tag, tag)
+			require.Equal(t, tc.digest, digest)
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	testCases := []struct {
+		expr        string
+		expectedErr string
+	}{
+		{expr: ".spec.containers[*].image"},
+		{expr: "{$.spec.template.spec.containers[0].image}"},
+		{expr: "$['spec']['image']"},
+		{expr: ".spec.*.image"},
+		{expr: "", expectedErr: "empty JSONPath"},
+		{expr: "..image", expectedErr: "recursive descent is not supported"},
+		{expr: ".spec[?(@.name)]", expectedErr: "unsupported subscript"},
+		{expr: ".spec[0", expectedErr: "unterminated ["},
+		{expr: "spec", expectedErr: "expected . or ["},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := ParsePath(tc.expr)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExtractor_Extract(t *testing.T) {
+	testCases := []struct {
+		name     string
+		paths    []string
+		doc      string
+		expected []Image
+	}{
+		{
+			name: "deployment with init containers",
+			doc: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: registry.example.com/shop/migrate:1.4
+      containers:
+      - name: app
+        image: registry.example.com/shop/web@sha256:abc
+      - name: sidecar
+        image: envoy
+`,
+			expected: []Image{
+				{Resource: "Deployment shop/web", Container: "migrate", Type: TypeInitContainer, Image: "registry.example.com/shop/migrate:1.4", Repository: "registry.example.com/shop/migrate", Tag: "1.4"},
+				{Resource: "Deployment shop/web", Container: "app", Type: TypeContainer, Image: "registry.example.com/shop/web@sha256:abc", Repository: "registry.example.com/shop/web", Digest: "sha256:abc"},
+				{Resource: "Deployment shop/web", Container: "sidecar", Type: TypeContainer, Image: "envoy", Repository: "envoy"},
+			},
+		},
+		{
+			name: "cronjob",
+			doc: `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: busybox:1.36
+`,
+			expected: []Image{
+				{Resource: "CronJob cleanup", Container: "cleanup", Type: TypeContainer, Image: "busybox:1.36", Repository: "busybox", Tag: "1.36"},
+			},
+		},
+		{
+			name: "custom resource without a path",
+			doc: `apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: web:1
+`,
+		},
+		{
+			name:  "custom resource with paths",
+			paths: []string{"Workflow=.spec.templates[*].container.image", "Workflow=.spec.templates[*].script.image", "Service=.spec.image"},
+			doc: `apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: build
+spec:
+  templates:
+  - name: compile
+    container:
+      name: main
+      image: golang:1.22
+  - name: notify
+    script:
+      image: alpine
+  - name: steps
+`,
+			expected: []Image{
+				{Resource: "Workflow build", Container: "main", Type: TypeCustom, Path: ".spec.templates[*].container.image", Image: "golang:1.22", Repository: "golang", Tag: "1.22"},
+				{Resource: "Workflow build", Type: TypeCustom, Path: ".spec.templates[*].script.image", Image: "alpine", Repository: "alpine"},
+			},
+		},
+		{
+			name: "resource without images",
+			doc: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  image: nginx
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			extractor, err := NewExtractor(tc.paths)
+			require.NoError(t, err)
+			found, err := extractor.Extract(parseResource(t, tc.doc))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, found)
+		})
+	}
+}
+
+func TestNewExtractor_InvalidSpec(t *testing.T) {
+	_, err := NewExtractor([]string{".spec.image"})
+	require.ErrorContains(t, err, "expected KIND=JSONPATH")
+	_, err = NewExtractor([]string{"Rollout=..image"})
+	require.ErrorContains(t, err, "recursive descent")
+}
+
+func TestInventory_Write(t *testing.T) {
+	newInventory := func(flagMutable bool) *Inventory {
+		inv := &Inventory{FlagMutable: flagMutable}
+		inv.Add("prod", "eu", "web", []Image{
+			{Resource: "Deployment web", Container: "app", Type: TypeContainer, Image: "web@sha256:abc", Repository: "web", Digest: "sha256:abc"},
+			{Resource: "Deployment web", Container: "proxy", Type: TypeContainer, Image: "envoy:1.29", Repository: "envoy", Tag: "1.29"},
+		})
+		inv.Add("dev", "", "web", []Image{
+			{Resource: "Deployment web", Container: "proxy", Type: TypeContainer, Image: "envoy:1.29", Repository: "envoy", Tag: "1.29"},
+		})
+		inv.Add("dev", "", "config", nil)
+		return inv
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, newInventory(false).Write(&buf, FormatCSV))
+	require.Equal(t, `env,instance,application,resource,container,type,image,repository,tag,digest
+dev,,web,Deployment web,proxy,container,envoy:1.29,envoy,1.29,
+prod,eu,web,Deployment web,app,container,web@sha256:abc,web,,sha256:abc
+prod,eu,web,Deployment web,proxy,container,envoy:1.29,envoy,1.29,
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, newInventory(true).Write(&buf, FormatCSV))
+	require.Contains(t, buf.String(), "digest,mutable\n")
+	require.Contains(t, buf.String(), "web@sha256:abc,web,,sha256:abc,false\n")
+	require.Contains(t, buf.String(), "envoy:1.29,envoy,1.29,,true\n")
+
+	buf.Reset()
+	inv := newInventory(true)
+	require.NoError(t, inv.Write(&buf, FormatJSON))
+	require.Equal(t, 2, inv.Mutable())
+	require.Equal(t, []string{"envoy:1.29", "web@sha256:abc"}, inv.Images)
+	require.Equal(t, []string{"config", "web", "web"}, []string{inv.Applications[0].Name, inv.Applications[1].Name, inv.Applications[2].Name})
+	require.Contains(t, buf.String(), `"images": []`)
+	require.Contains(t, buf.String(), `"mutable": true`)
+
+	require.ErrorContains(t, inv.Write(&buf, "xml"), "unknown inventory format")
+}
@@ -0,0 +1,132 @@
+package images
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Inventory lists the images of every Application, grouped by env, instance
+// and Application.
+type Inventory struct {
+	Applications []Application `json:"applications"`
+	// Images are the distinct image references of all Applications.
+	Images []string `json:"images"`
+	// FlagMutable marks the images not pinned by digest.
+	FlagMutable bool `json:"-"`
+}
+
+type Application struct {
+	Env      string  `json:"env,omitempty"`
+	Instance string  `json:"instance,omitempty"`
+	Name     string  `json:"name"`
+	Images   []Image `json:"images"`
+}
+
+// Add records the images of an Application.
+func (inv *Inventory) Add(env, instance, name string, images []Image) {
+	if images == nil {
+		images = []Image{}
+	}
+	inv.Applications = append(inv.Applications, Application{Env: env, Instance: instance, Name: name, Images: images})
+}
+
+// Mutable returns the number of images that are not pinned by digest.
+func (inv *Inventory) Mutable() int {
+	n := 0
+	for _, app := range inv.Applications {
+		for _, image := range app.Images {
+			if !image.Pinned() {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// finish sorts the Applications, collects the distinct images and marks the
+// mutable ones if requested.
+func (inv *Inventory) finish() {
+	sort.SliceStable(inv.Applications, func(i, j int) bool {
+		a, b := inv.Applications[i], inv.Applications[j]
+		if a.Env != b.Env {
+			return a.Env < b.Env
+		}
+		if a.Instance != b.Instance {
+			return a.Instance < b.Instance
+		}
+		return a.Name < b.Name
+	})
+	seen := make(map[string]bool)
+	inv.Images = []string{}
+	for i := range inv.Applications {
+		for j := range inv.Applications[i].Images {
+			image := &inv.Applications[i].Images[j]
+			image.Mutable = inv.FlagMutable && !image.Pinned()
+			if !seen[image.Image] {
+				seen[image.Image] = true
+				inv.Images = append(inv.Images, image.Image)
+			}
+		}
+	}
+	sort.Strings(inv.Images)
+	if inv.Applications == nil {
+		inv.Applications = []Application{}
+	}
+}
+
+// Write encodes the inventory in the format, "json" or "csv".
+func (inv *Inventory) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return inv.WriteJSON(w)
+	case FormatCSV:
+		return inv.WriteCSV(w)
+	}
+	return fmt.Errorf("unknown inventory format %q, expected json or csv", format)
+}
+
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	inv.finish()
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode image inventory: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write image inventory: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV writes one row per image of every Application. The mutable column
+// is only present when mutable tags are flagged.
+func (inv *Inventory) WriteCSV(w io.Writer) error {
+	inv.finish()
+	cw := csv.NewWriter(w)
+	header := []string{"env", "instance", "application", "resource", "container", "type", "image", "repository", "tag", "digest"}
+	if inv.FlagMutable {
+		header = append(header, "mutable")
+	}
+	rows := [][]string{header}
+	for _, app := range inv.Applications {
+		for _, image := range app.Images {
+			row := []string{app.Env, app.Instance, app.Name, image.Resource, image.Container, image.Type, image.Image, image.Repository, image.Tag, image.Digest}
+			if inv.FlagMutable {
+				row = append(row, strconv.FormatBool(image.Mutable))
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write image inventory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package images
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// segment is a step of a JSONPath: a field name, an array index or a
+// wildcard over all array elements or map values.
+type segment struct {
+	field    string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+// Path is a compiled JSONPath expression selecting image references in a
+// resource. Only the subset needed to address fields is supported: `.field`,
+// `['field']`, `[N]` and `[*]`, optionally wrapped in `{}` and prefixed with
+// `$`, as accepted by kubectl.
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+func (p Path) String() string {
+	return p.expr
+}
+
+// ParsePath compiles a JSONPath expression such as
+// `.spec.template.spec.containers[*].image`.
+func ParsePath(expr string) (Path, error) {
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	s = strings.TrimPrefix(s, "$")
+	if s == "" {
+		return Path{}, fmt.Errorf("empty JSONPath %q", expr)
+	}
+
+	p := Path{expr: expr}
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			return Path{}, fmt.Errorf("JSONPath %q: recursive descent is not supported", expr)
+		case s[0] == '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return Path{}, fmt.Errorf("JSONPath %q: empty field name", expr)
+			}
+			if name == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, segment{field: name})
+			}
+			s = s[end:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return Path{}, fmt.Errorf("JSONPath %q: unterminated [", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			if inner == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+				continue
+			}
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				p.segments = append(p.segments, segment{field: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return Path{}, fmt.Errorf("JSONPath %q: unsupported subscript [%s], expected a field, an index or *", expr, inner)
+			}
+			p.segments = append(p.segments, segment{index: index, isIndex: true})
+		default:
+			return Path{}, fmt.Errorf("JSONPath %q: expected . or [ at %q", expr, s)
+		}
+	}
+	return p, nil
+}
+
+// match is a value selected by a path together with the object holding it.
+type match struct {
+	value  any
+	parent map[string]any
+}
+
+// evaluate returns the values the path selects in a decoded YAML document.
+// Missing fields select nothing.
+func (p Path) evaluate(root any) []match {
+	current := []match{{value: root}}
+	for _, seg := range p.segments {
+		var next []match
+		for _, m := range current {
+			switch v := m.value.(type) {
+			case map[string]any:
+				switch {
+				case seg.wildcard:
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, match{value: v[key], parent: v})
+					}
+				case !seg.isIndex:
+					if child, ok := v[seg.field]; ok {
+						next = append(next, match{value: child, parent: v})
+					}
+				}
+			case []any:
+				switch {
+				case seg.wildcard:
+					for _, child := range v {
+						next = append(next, match{value: child})
+					}
+				case seg.isIndex && seg.index < len(v):
+					next = append(next, match{value: v[seg.index]})
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
@@ -0,0 +1,50 @@
+package kubeversion
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse returns the major and minor parts of a Kubernetes version such as
+// "v1.29.3", "1.29" or "1.27+" (as reported by some providers).
+func Parse(version string) (major, minor int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(strings.TrimRight(parts[1], "+"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// Check returns an error when version is not a Kubernetes version that Parse
+// understands. Versions given by users are checked up front, so that a typo
+// is reported instead of being compared as unknown by Less.
+func Check(version string) error {
+	if _, _, ok := Parse(version); !ok {
+		return fmt.Errorf("invalid Kubernetes version '%s' (expected MAJOR.MINOR[.PATCH], e.g. 1.29 or v1.29.3)", version)
+	}
+	return nil
+}
+
+// Less reports whether the minor version of a is older than the one of b.
+// Versions that cannot be parsed are never older; use Check to reject them
+// first.
+func Less(a, b string) bool {
+	aMajor, aMinor, okA := Parse(a)
+	bMajor, bMinor, okB := Parse(b)
+	if !okA || !okB {
+		return false
+	}
+	if aMajor != bMajor {
+		return aMajor < bMajor
+	}
+	return aMinor < bMinor
+}
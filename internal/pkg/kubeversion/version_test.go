@@ -0,0 +1,35 @@
+package kubeversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLess(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"1.20", "1.21", true},
+		{"v1.20.4", "1.21", true},
+		{"1.21.0", "1.21", false},
+		{"1.29", "1.21", false},
+		{"1.27+", "1.28", true},
+		{"2.0", "1.30", false},
+		{"latest", "1.21", false},
+		{"1.20", "", false},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, Less(tc.a, tc.b), "%s < %s", tc.a, tc.b)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, version := range []string{"1.29", "v1.29.3", "1.27+", "1.30.0-eks-1"} {
+		require.NoError(t, Check(version), version)
+	}
+	for _, version := range []string{"", "latest", "1", "v1.x", "1.29x"} {
+		require.ErrorContains(t, Check(version), "invalid Kubernetes version", version)
+	}
+}
@@ -0,0 +1,155 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const sourceCommentPrefix = "# Source: "
+
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	Source     string
+	Index      int
+	Raw        []byte
+}
+
+type resourceHeader struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// Parse splits a multi-document YAML stream, as produced by `helm template`,
+// into resources. The original text of every document is kept as is; empty
+// documents and documents that contain only comments are dropped.
+func Parse(data []byte) ([]Resource, error) {
+	var resources []Resource
+	for i, document := range Documents(data) {
+		doc := document.Raw
+		var header resourceHeader
+		if err := yaml.Unmarshal(doc, &header); err != nil {
+			return nil, fmt.Errorf("failed to decode yaml document #%d: %w", i, err)
+		}
+		if header.APIVersion == "" && header.Kind == "" && header.Metadata.Name == "" {
+			if isEmptyDocument(doc) {
+				continue
+			}
+		}
+		resources = append(resources, Resource{
+			APIVersion: header.APIVersion,
+			Kind:       header.Kind,
+			Name:       header.Metadata.Name,
+			Namespace:  header.Metadata.Namespace,
+			Source:     document.Source,
+			Index:      i,
+			Raw:        doc,
+		})
+	}
+	return resources, nil
+}
+
+// Document is a document of a YAML stream together with its position in the
+// stream.
+type Document struct {
+	Raw []byte
+	// Line is the line of the stream the document starts at, counted from 1.
+	Line int
+	// Source is the chart template from the `# Source:` comment helm puts
+	// at the top of every document; SourceLine is the line of that comment
+	// in Raw, 0 when there is none.
+	Source     string
+	SourceLine int
+}
+
+// TemplateLine converts a line of Raw, counted from 1, to the line in the
+// rendered output of the template the document was generated from, or to
+// the line in the whole stream for documents without a `# Source:` comment.
+func (d Document) TemplateLine(line int) int {
+	if d.Source != "" {
+		return line - d.SourceLine
+	}
+	return d.Line + line - 1
+}
+
+// SplitDocuments returns the raw text of each document of a YAML stream
+// without the `---` separators. Blank text before the first and after the last
+// separator is not counted as a document.
+func SplitDocuments(data []byte) [][]byte {
+	var docs [][]byte
+	for _, doc := range Documents(data) {
+		docs = append(docs, doc.Raw)
+	}
+	return docs
+}
+
+// Documents splits a YAML stream like SplitDocuments and records where every
+// document starts and which template it was rendered from.
+func Documents(data []byte) []Document {
+	var docs []Document
+	var current bytes.Buffer
+	start, lineNumber := 1, 0
+	flush := func() {
+		doc := Document{Raw: bytes.Clone(current.Bytes()), Line: start}
+		for i, line := range strings.Split(string(doc.Raw), "\n") {
+			if strings.HasPrefix(line, sourceCommentPrefix) {
+				doc.Source = strings.TrimSpace(strings.TrimPrefix(line, sourceCommentPrefix))
+				doc.SourceLine = i + 1
+				break
+			}
+		}
+		docs = append(docs, doc)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNumber++
+		if isSeparator(line) {
+			if len(docs) > 0 || len(bytes.TrimSpace(current.Bytes())) > 0 {
+				flush()
+			}
+			current.Reset()
+			start = lineNumber + 1
+			continue
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	if len(bytes.TrimSpace(current.Bytes())) > 0 {
+		flush()
+	}
+	return docs
+}
+
+func (r Resource) String() string {
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+func isSeparator(line string) bool {
+	line = strings.TrimRight(line, " \t\r")
+	return line == "---" || strings.HasPrefix(line, "--- ")
+}
+
+func isEmptyDocument(doc []byte) bool {
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
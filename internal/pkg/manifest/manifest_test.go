@@ -0,0 +1,68 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	input := `---
+# Source: my-chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: my-ns
+data:
+  key: value
+---
+# Source: my-chart/templates/empty.yaml
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+`
+	resources, err := Parse([]byte(input))
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+
+	require.Equal(t, "v1", resources[0].APIVersion)
+	require.Equal(t, "ConfigMap", resources[0].Kind)
+	require.Equal(t, "my-config", resources[0].Name)
+	require.Equal(t, "my-ns", resources[0].Namespace)
+	require.Equal(t, "my-chart/templates/configmap.yaml", resources[0].Source)
+	require.Equal(t, 0, resources[0].Index)
+	require.Contains(t, string(resources[0].Raw), "key: value")
+	require.NotContains(t, string(resources[0].Raw), "---")
+
+	require.Equal(t, "Deployment", resources[1].Kind)
+	require.Equal(t, 2, resources[1].Index)
+	require.Equal(t, "Deployment my-app", resources[1].String())
+}
+
+func TestParseMalformedDocument(t *testing.T) {
+	_, err := Parse([]byte("kind: ConfigMap\n---\nkind: [broken\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "document #1")
+}
+
+func TestSplitDocuments(t *testing.T) {
+	require.Len(t, SplitDocuments([]byte("a: 1\n---\nb: 2\n")), 2)
+	require.Len(t, SplitDocuments([]byte("---\na: 1\n---\n")), 1)
+	require.Empty(t, SplitDocuments([]byte("\n")))
+}
+
+func TestDocuments(t *testing.T) {
+	docs := Documents([]byte("---\n# Source: chart/templates/a.yaml\na: 1\nb: 2\n---\nc: 3\n"))
+	require.Len(t, docs, 2)
+	require.Equal(t, 2, docs[0].Line)
+	require.Equal(t, "chart/templates/a.yaml", docs[0].Source)
+	require.Equal(t, 1, docs[0].SourceLine)
+	// b: 2 is the second line of the template output
+	require.Equal(t, 2, docs[0].TemplateLine(3))
+	require.Equal(t, 6, docs[1].Line)
+	require.Empty(t, docs[1].Source)
+	require.Equal(t, 6, docs[1].TemplateLine(1))
+}
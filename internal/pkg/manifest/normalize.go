@@ -0,0 +1,153 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+type NormalizeOptions struct {
+	// StripLabels and StripAnnotations are glob patterns (path.Match syntax)
+	// of label and annotation keys removed from every metadata block,
+	// including pod templates.
+	StripLabels      []string
+	StripAnnotations []string
+}
+
+// Normalize rewrites a multi-document YAML stream into a canonical form:
+// mapping keys are sorted, indentation is two spaces, flow collections and
+// quoted scalars are emitted in block/plain style where possible, comments are
+// removed, matching labels and annotations are stripped and empty documents
+// are dropped.
+func Normalize(data []byte, opts NormalizeOptions) ([]byte, error) {
+	for _, pattern := range append(append([]string{}, opts.StripLabels...), opts.StripAnnotations...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid strip pattern '%s': %w", pattern, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode yaml document #%d: %w", i, err)
+		}
+		if isEmptyNode(&doc) {
+			continue
+		}
+
+		normalizeNode(&doc, opts)
+
+		if buf.Len() > 0 {
+			buf.WriteString("---\n")
+		}
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to encode yaml document #%d: %w", i, err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode yaml document #%d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func normalizeNode(node *yaml.Node, opts NormalizeOptions) {
+	node.HeadComment = ""
+	node.LineComment = ""
+	node.FootComment = ""
+	node.Style &^= yaml.FlowStyle | yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle
+
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "metadata" && node.Content[i+1].Kind == yaml.MappingNode {
+				stripMetadata(node.Content[i+1], opts)
+			}
+		}
+		sortMapping(node)
+	}
+	for _, child := range node.Content {
+		normalizeNode(child, opts)
+	}
+}
+
+func stripMetadata(metadata *yaml.Node, opts NormalizeOptions) {
+	var content []*yaml.Node
+	for i := 0; i+1 < len(metadata.Content); i += 2 {
+		key, value := metadata.Content[i], metadata.Content[i+1]
+		switch key.Value {
+		case "labels":
+			stripKeys(value, opts.StripLabels)
+		case "annotations":
+			stripKeys(value, opts.StripAnnotations)
+		}
+		if (key.Value == "labels" || key.Value == "annotations") && isEmptyNode(value) {
+			continue
+		}
+		content = append(content, key, value)
+	}
+	metadata.Content = content
+}
+
+func stripKeys(mapping *yaml.Node, patterns []string) {
+	if mapping.Kind != yaml.MappingNode || len(patterns) == 0 {
+		return
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !matchesAny(mapping.Content[i].Value, patterns) {
+			content = append(content, mapping.Content[i], mapping.Content[i+1])
+		}
+	}
+	mapping.Content = content
+}
+
+func sortMapping(mapping *yaml.Node) {
+	type pair struct{ key, value *yaml.Node }
+	pairs := make([]pair, 0, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		pairs = append(pairs, pair{mapping.Content[i], mapping.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].key.Value < pairs[j].key.Value
+	})
+	for i, p := range pairs {
+		mapping.Content[2*i] = p.key
+		mapping.Content[2*i+1] = p.value
+	}
+}
+
+func matchesAny(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyNode(node *yaml.Node) bool {
+	switch node.Kind {
+	case 0:
+		return true
+	case yaml.DocumentNode:
+		return len(node.Content) == 0 || isEmptyNode(node.Content[0])
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(node.Content) == 0
+	case yaml.ScalarNode:
+		return node.Tag == "!!null"
+	default:
+		return false
+	}
+}
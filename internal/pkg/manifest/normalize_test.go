@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	input := `---
+# Source: my-chart/templates/deployment.yaml
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: "my-app" # inline comment
+  labels: {helm.sh/chart: my-chart-1.0.0, app: my-app}
+  annotations:
+    checksum/config: 5f1d3c
+spec:
+  template:
+    metadata:
+      labels:
+        helm.sh/chart: my-chart-1.0.0
+      annotations:
+        checksum/secret: a8b2e9
+        keep: 'me'
+    spec:
+      containers: [{name: app, image: "nginx:1.25", args: ["--port", "8080"]}]
+---
+# Source: my-chart/templates/disabled.yaml
+---
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: my-app
+  name: my-app
+spec:
+  template:
+    metadata:
+      annotations:
+        keep: me
+    spec:
+      containers:
+        - args:
+            - --port
+            - "8080"
+          image: nginx:1.25
+          name: app
+`
+	out, err := Normalize([]byte(input), NormalizeOptions{
+		StripLabels:      []string{"helm.sh/*"},
+		StripAnnotations: []string{"checksum/*"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected, string(out))
+}
+
+func TestNormalizeIsIdempotent(t *testing.T) {
+	input := "b: 1\na:\n  d: x\n  c: |\n    multi\n    line\n---\nkind: Service\n"
+	once, err := Normalize([]byte(input), NormalizeOptions{})
+	require.NoError(t, err)
+	twice, err := Normalize(once, NormalizeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, string(once), string(twice))
+	require.Equal(t, "a:\n  c: |\n    multi\n    line\n  d: x\nb: 1\n---\nkind: Service\n", string(once))
+}
+
+func TestNormalizeInvalidPattern(t *testing.T) {
+	_, err := Normalize([]byte("a: 1\n"), NormalizeOptions{StripLabels: []string{"[broken"}})
+	require.Error(t, err)
+}
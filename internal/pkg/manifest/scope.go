@@ -0,0 +1,47 @@
+package manifest
+
+import "strings"
+
+// clusterScoped lists the built-in cluster-scoped kinds by API group.
+var clusterScoped = map[string]bool{
+	"/Namespace":                            true,
+	"/Node":                                 true,
+	"/PersistentVolume":                     true,
+	"/ComponentStatus":                      true,
+	"rbac.authorization.k8s.io/ClusterRole": true,
+	"rbac.authorization.k8s.io/ClusterRoleBinding":                  true,
+	"apiextensions.k8s.io/CustomResourceDefinition":                 true,
+	"apiregistration.k8s.io/APIService":                             true,
+	"admissionregistration.k8s.io/MutatingWebhookConfiguration":     true,
+	"admissionregistration.k8s.io/ValidatingWebhookConfiguration":   true,
+	"admissionregistration.k8s.io/ValidatingAdmissionPolicy":        true,
+	"admissionregistration.k8s.io/ValidatingAdmissionPolicyBinding": true,
+	"storage.k8s.io/StorageClass":                                   true,
+	"storage.k8s.io/CSIDriver":                                      true,
+	"storage.k8s.io/CSINode":                                        true,
+	"storage.k8s.io/VolumeAttachment":                               true,
+	"scheduling.k8s.io/PriorityClass":                               true,
+	"networking.k8s.io/IngressClass":                                true,
+	"node.k8s.io/RuntimeClass":                                      true,
+	"policy/PodSecurityPolicy":                                      true,
+	"extensions/PodSecurityPolicy":                                  true,
+	"certificates.k8s.io/CertificateSigningRequest":                 true,
+	"flowcontrol.apiserver.k8s.io/FlowSchema":                       true,
+	"flowcontrol.apiserver.k8s.io/PriorityLevelConfiguration":       true,
+}
+
+// Group returns the API group of an apiVersion: "apps" for "apps/v1" and an
+// empty string for the core group ("v1").
+func Group(apiVersion string) string {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+// IsClusterScoped reports whether resources of the kind are cluster-scoped.
+// Only built-in kinds are known; custom resources are assumed to be
+// namespaced.
+func IsClusterScoped(apiVersion, kind string) bool {
+	return clusterScoped[Group(apiVersion)+"/"+kind]
+}
@@ -0,0 +1,180 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const IndexFile = ".roar-index.yaml"
+
+// Index records which files in the output directory were written for which
+// application. Paths are relative to the output directory and slash-separated.
+// Settings and Definitions hold fingerprints of the output settings and of
+// every rendered Application, and Revisions the commit of the repository every
+// Application was rendered from; they are used by incremental rendering. SyncWaves lists
+// the Applications in the order Argo CD syncs them; it is only written with
+// --sync-order.
+type Index struct {
+	Settings     string              `yaml:"settings,omitempty"`
+	Definitions  map[string]string   `yaml:"definitions,omitempty"`
+	Revisions    map[string]string   `yaml:"revisions,omitempty"`
+	SyncWaves    []SyncWave          `yaml:"syncWaves,omitempty"`
+	Applications map[string][]string `yaml:"applications"`
+}
+
+// SyncWave is a group of Applications synced together.
+type SyncWave struct {
+	Wave         int      `yaml:"wave"`
+	Applications []string `yaml:"applications"`
+}
+
+func NewIndex() *Index {
+	return &Index{Applications: make(map[string][]string), Definitions: make(map[string]string), Revisions: make(map[string]string)}
+}
+
+// ReadIndex loads the index of a previous run. A missing index is not an
+// error and results in an empty index.
+func ReadIndex(outputDir string) (*Index, error) {
+	path := filepath.Join(outputDir, IndexFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewIndex(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output index %s: %w", path, err)
+	}
+	idx, err := ParseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return idx, nil
+}
+
+func ParseIndex(data []byte) (*Index, error) {
+	idx := NewIndex()
+	if err := yaml.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse output index: %w", err)
+	}
+	if idx.Applications == nil {
+		idx.Applications = make(map[string][]string)
+	}
+	if idx.Definitions == nil {
+		idx.Definitions = make(map[string]string)
+	}
+	if idx.Revisions == nil {
+		idx.Revisions = make(map[string]string)
+	}
+	return idx, nil
+}
+
+func (idx *Index) Write(outputDir string) error {
+	for app, files := range idx.Applications {
+		idx.Applications[app] = sortedUnique(files)
+	}
+	data, err := yaml.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode output index: %w", err)
+	}
+	return writeFile(filepath.Join(outputDir, IndexFile), data)
+}
+
+// Add records files written for app. Absolute paths or paths relative to the
+// working directory are converted to paths relative to outputDir.
+func (idx *Index) Add(outputDir, app string, files []string) error {
+	for _, file := range files {
+		rel, err := filepath.Rel(outputDir, file)
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", file, err)
+		}
+		idx.Applications[app] = append(idx.Applications[app], filepath.ToSlash(rel))
+	}
+	return nil
+}
+
+// Carry copies the entries of app from prev, used to keep the previous output
+// of applications that were not rendered in the current run.
+func (idx *Index) Carry(prev *Index, app string) {
+	if files, ok := prev.Applications[app]; ok {
+		idx.Applications[app] = append(idx.Applications[app], files...)
+	}
+	if def, ok := prev.Definitions[app]; ok {
+		idx.Definitions[app] = def
+	}
+	if revision, ok := prev.Revisions[app]; ok {
+		idx.Revisions[app] = revision
+	}
+}
+
+// Stale returns the files listed in prev that are not produced by any
+// application in idx, grouped by the application that wrote them.
+func (idx *Index) Stale(prev *Index) map[string][]string {
+	produced := make(map[string]bool)
+	for _, files := range idx.Applications {
+		for _, file := range files {
+			produced[file] = true
+		}
+	}
+	stale := make(map[string][]string)
+	for app, files := range prev.Applications {
+		for _, file := range files {
+			if !produced[file] {
+				stale[app] = append(stale[app], file)
+			}
+		}
+	}
+	return stale
+}
+
+// Prune removes the given index entries from outputDir along with any
+// directories left empty. Entries pointing outside outputDir are refused.
+func Prune(outputDir string, files []string) error {
+	for _, file := range files {
+		path, err := indexedPath(outputDir, file)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale file %s: %w", path, err)
+		}
+		removeEmptyParents(outputDir, filepath.Dir(path))
+	}
+	return nil
+}
+
+func indexedPath(outputDir, file string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(file))
+	if filepath.IsAbs(rel) || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to prune '%s': path is outside of the output directory", file)
+	}
+	return filepath.Join(outputDir, rel), nil
+}
+
+func removeEmptyParents(outputDir, dir string) {
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(outputDir, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+func sortedUnique(files []string) []string {
+	sort.Strings(files)
+	unique := files[:0]
+	for i, file := range files {
+		if i == 0 || file != files[i-1] {
+			unique = append(unique, file)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	outputDir := t.TempDir()
+
+	idx, err := ReadIndex(outputDir)
+	require.NoError(t, err)
+	require.Empty(t, idx.Applications)
+
+	require.NoError(t, idx.Add(outputDir, "app-one", []string{
+		filepath.Join(outputDir, "dev", "app-one.yaml"),
+		filepath.Join(outputDir, "dev", "app-one.yaml"),
+	}))
+	require.NoError(t, idx.Write(outputDir))
+
+	loaded, err := ReadIndex(outputDir)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"app-one": {"dev/app-one.yaml"}}, loaded.Applications)
+}
+
+func TestIndexStale(t *testing.T) {
+	previous := &Index{Applications: map[string][]string{
+		"app-one":   {"dev/app-one.yaml"},
+		"app-two":   {"dev/app-two.yaml"},
+		"app-moved": {"dev/shared.yaml"},
+	}}
+	current := &Index{Applications: map[string][]string{
+		"app-one":   {"dev/app-one.yaml"},
+		"app-three": {"dev/shared.yaml"},
+	}}
+
+	require.Equal(t, map[string][]string{"app-two": {"dev/app-two.yaml"}}, current.Stale(previous))
+}
+
+func TestPrune(t *testing.T) {
+	outputDir := t.TempDir()
+	stale := filepath.Join(outputDir, "dev", "inf1", "app-two.yaml")
+	kept := filepath.Join(outputDir, "dev", "app-one.yaml")
+	require.NoError(t, writeFile(stale, []byte("kind: ConfigMap\n")))
+	require.NoError(t, writeFile(kept, []byte("kind: ConfigMap\n")))
+
+	require.NoError(t, Prune(outputDir, []string{"dev/inf1/app-two.yaml", "dev/missing.yaml"}))
+	require.NoFileExists(t, stale)
+	require.NoDirExists(t, filepath.Join(outputDir, "dev", "inf1"))
+	require.FileExists(t, kept)
+
+	err := Prune(outputDir, []string{"../outside.yaml"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "outside of the output directory")
+
+	_, err = os.Stat(outputDir)
+	require.NoError(t, err)
+}
+
+func TestPruneRelativeOutputDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+	stale := filepath.Join("dev", "inf1", "app-two.yaml")
+	require.NoError(t, writeFile(stale, []byte("kind: ConfigMap\n")))
+
+	require.NoError(t, Prune(".", []string{"dev/inf1/app-two.yaml"}))
+	require.NoFileExists(t, stale)
+	require.NoDirExists(t, "dev")
+	require.DirExists(t, ".")
+
+	// Directories of a similar-prefix sibling are not removed
+	require.NoError(t, os.MkdirAll(filepath.Join("out2", "dev"), 0755))
+	removeEmptyParents("out", filepath.Join("out2", "dev"))
+	require.DirExists(t, filepath.Join("out2", "dev"))
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"roar/internal/pkg/argo"
+)
+
+// DefaultLayout places Applications outside of the Argo CD control plane
+// namespace into a directory named after their namespace, see
+// argo.Application.QualifiedName.
+const DefaultLayout = "{{.Env}}/{{.Instance}}/{{.QualifiedName}}.yaml"
+
+type Layout struct {
+	root string
+	tmpl *template.Template
+}
+
+func NewLayout(root, text string) (*Layout, error) {
+	if text == "" {
+		text = DefaultLayout
+	}
+	funcs := template.FuncMap{
+		"default": func(def, val string) string {
+			if val == "" {
+				return def
+			}
+			return val
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}
+	tmpl, err := template.New("layout").Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout template %q: %w", text, err)
+	}
+	return &Layout{root: root, tmpl: tmpl}, nil
+}
+
+// Path renders the layout for app and returns the output file path under the
+// layout root. Paths that resolve outside of the root are rejected.
+func (l *Layout) Path(app argo.Application) (string, error) {
+	var buf bytes.Buffer
+	if err := l.tmpl.Execute(&buf, app); err != nil {
+		return "", fmt.Errorf("failed to execute layout template: %w", err)
+	}
+	rendered := strings.TrimSpace(buf.String())
+	if rendered == "" {
+		return "", fmt.Errorf("layout template produced an empty path")
+	}
+
+	path := filepath.Join(l.root, filepath.FromSlash(rendered))
+	rel, err := filepath.Rel(l.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("layout path '%s' is outside of the output directory", rendered)
+	}
+	return path, nil
+}
+
+// Resolve computes the output path of every application and fails if two
+// applications would be written to the same file or, in split mode, to the
+// same SplitDir. In split mode the SplitDir of one application must not
+// contain the SplitDir of another either: the namespace subdirectories of the
+// first one could hold the same files as the second one.
+func (l *Layout) Resolve(apps []argo.Application, mode Mode) ([]string, error) {
+	paths := make([]string, len(apps))
+	owners := make(map[string]string, len(apps))
+	for i, app := range apps {
+		name := app.QualifiedName()
+		path, err := l.Path(app)
+		if err != nil {
+			return nil, fmt.Errorf("application '%s': %w", name, err)
+		}
+		target := path
+		if mode == ModeSplit {
+			target = SplitDir(path)
+		}
+		if owner, ok := owners[target]; ok {
+			return nil, fmt.Errorf("output path collision: applications '%s' and '%s' both resolve to %s", owner, name, target)
+		}
+		owners[target] = name
+		paths[i] = path
+	}
+	if mode == ModeSplit {
+		for i, path := range paths {
+			target, name := SplitDir(path), apps[i].QualifiedName()
+			for dir := filepath.Dir(target); dir != l.root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+				if owner, ok := owners[dir]; ok {
+					return nil, fmt.Errorf("output path collision: %s of application '%s' is inside %s of application '%s'", target, name, dir, owner)
+				}
+			}
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,158 @@
+package output
+
+import (
+	"path/filepath"
+	"testing"
+
+	"roar/internal/pkg/argo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutPath(t *testing.T) {
+	app := argo.Application{
+		Name:        "my-app",
+		Env:         "dev",
+		Instance:    "inf1",
+		Labels:      map[string]string{"team": "core"},
+		Annotations: map[string]string{"rawPath": "stable/my-app"},
+		Destination: argo.Destination{Name: "in-cluster", Namespace: "my-ns"},
+	}
+
+	testCases := []struct {
+		name          string
+		layout        string
+		app           argo.Application
+		expectedPath  string
+		errorContains string
+	}{
+		{
+			name:         "default layout",
+			layout:       "",
+			app:          app,
+			expectedPath: filepath.Join("out", "dev", "inf1", "my-app.yaml"),
+		},
+		{
+			name:         "default layout without env and instance",
+			layout:       DefaultLayout,
+			app:          argo.Application{Name: "my-app"},
+			expectedPath: filepath.Join("out", "my-app.yaml"),
+		},
+		{
+			name:         "default layout with namespace",
+			layout:       "",
+			app:          argo.Application{Name: "my-app", Namespace: "team-a", Env: "dev"},
+			expectedPath: filepath.Join("out", "dev", "team-a", "my-app.yaml"),
+		},
+		{
+			name:         "labels and destination",
+			layout:       "{{.Destination.Name}}/{{.Destination.Namespace}}/{{.Labels.team}}/{{.Name}}.yaml",
+			app:          app,
+			expectedPath: filepath.Join("out", "in-cluster", "my-ns", "core", "my-app.yaml"),
+		},
+		{
+			name:         "missing label with default",
+			layout:       `{{.Labels.owner | default "unowned"}}/{{.Name}}.yaml`,
+			app:          app,
+			expectedPath: filepath.Join("out", "unowned", "my-app.yaml"),
+		},
+		{
+			name:          "path escaping the output directory",
+			layout:        "../{{.Name}}.yaml",
+			app:           app,
+			errorContains: "outside of the output directory",
+		},
+		{
+			name:          "empty path",
+			layout:        "{{.Labels.missing}}",
+			app:           app,
+			errorContains: "empty path",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layout, err := NewLayout("out", tc.layout)
+			require.NoError(t, err)
+
+			path, err := layout.Path(tc.app)
+			if tc.errorContains != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.errorContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPath, path)
+		})
+	}
+}
+
+func TestLayoutResolveCollision(t *testing.T) {
+	layout, err := NewLayout("out", DefaultLayout)
+	require.NoError(t, err)
+
+	apps := []argo.Application{
+		{Name: "app-one", Env: "dev"},
+		{Name: "app-two", Env: "dev"},
+		{Name: "app-one", Env: "dev"},
+	}
+
+	_, err = layout.Resolve(apps[:2], ModeFile)
+	require.NoError(t, err)
+
+	_, err = layout.Resolve(apps, ModeFile)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output path collision")
+
+	// Applications with the same name in different namespaces do not collide
+	paths, err := layout.Resolve([]argo.Application{{Name: "app-one", Env: "dev"}, {Name: "app-one", Namespace: "team-a", Env: "dev"}}, ModeFile)
+	require.NoError(t, err)
+	require.NotEqual(t, paths[0], paths[1])
+}
+
+func TestLayoutResolveSplitDirCollision(t *testing.T) {
+	layout, err := NewLayout("out", "{{.Name}}")
+	require.NoError(t, err)
+
+	apps := []argo.Application{{Name: "a.yaml"}, {Name: "a.yml"}}
+
+	_, err = layout.Resolve(apps, ModeFile)
+	require.NoError(t, err)
+
+	_, err = layout.Resolve(apps, ModeSplit)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output path collision")
+
+	_, err = layout.Resolve([]argo.Application{{Name: "x"}, {Name: "x.yaml"}}, ModeSplit)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output path collision")
+}
+
+func TestLayoutResolveNestedSplitDir(t *testing.T) {
+	layout, err := NewLayout("out", DefaultLayout)
+	require.NoError(t, err)
+
+	// The resources of `team` in namespace `web` would be written to the
+	// SplitDir of `team/web`
+	apps := []argo.Application{
+		{Name: "team", Env: "dev", Instance: "i"},
+		{Name: "web", Namespace: "team", Env: "dev", Instance: "i"},
+	}
+
+	_, err = layout.Resolve(apps, ModeFile)
+	require.NoError(t, err)
+
+	_, err = layout.Resolve(apps, ModeSplit)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output path collision")
+	require.Contains(t, err.Error(), "'team/web'")
+
+	// Sibling directories sharing a prefix do not collide
+	_, err = layout.Resolve([]argo.Application{{Name: "team", Env: "dev"}, {Name: "team-web", Env: "dev"}}, ModeSplit)
+	require.NoError(t, err)
+}
+
+func TestNewLayoutInvalidTemplate(t *testing.T) {
+	_, err := NewLayout("out", "{{.Name")
+	require.Error(t, err)
+}
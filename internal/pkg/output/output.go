@@ -0,0 +1,178 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Mode string
+
+const (
+	ModeFile  Mode = "file"
+	ModeSplit Mode = "split"
+)
+
+const KustomizationFile = "kustomization.yaml"
+
+type Options struct {
+	Mode          Mode
+	Kustomization bool
+	// SyncOrder keeps the order of the rendered resources, already sorted
+	// in sync order, in the written files and kustomization.yaml instead of
+	// sorting them by file name.
+	SyncOrder bool
+}
+
+type kustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeFile:
+		return ModeFile, nil
+	case ModeSplit:
+		return ModeSplit, nil
+	default:
+		return "", fmt.Errorf("unknown output mode '%s' (expected '%s' or '%s')", s, ModeFile, ModeSplit)
+	}
+}
+
+// Write stores the rendered manifests of one application. In file mode they
+// are written to path as is, in split mode every resource is written to its own
+// file inside SplitDir(path). The paths of all written files are returned.
+func Write(path string, rendered []byte, opts Options) ([]string, error) {
+	if opts.Mode != ModeSplit {
+		if err := writeFile(path, rendered); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	resources, err := manifest.Parse(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split rendered manifests: %w", err)
+	}
+	return writeSplit(SplitDir(path), resources, opts)
+}
+
+// SplitDir returns the directory that holds the per-resource files of an
+// application whose single-file output would be file.
+func SplitDir(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
+
+// ResourceFileName returns the path of a resource file relative to the
+// application directory: `<kind>-<name>.yaml`, placed in a `<namespace>`
+// subdirectory when the resource sets metadata.namespace.
+func ResourceFileName(r manifest.Resource) string {
+	name := fmt.Sprintf("%s-%s.yaml", sanitize(strings.ToLower(r.Kind)), sanitize(r.Name))
+	if r.Namespace != "" {
+		return filepath.Join(sanitize(r.Namespace), name)
+	}
+	return name
+}
+
+func writeSplit(dir string, resources []manifest.Resource, opts Options) ([]string, error) {
+	for _, r := range resources {
+		if r.Kind == "" || r.Name == "" {
+			return nil, fmt.Errorf("document #%d has no kind or metadata.name and cannot be split", r.Index)
+		}
+	}
+
+	names := fileNames(resources)
+	order := make([]int, len(resources))
+	for i := range order {
+		order[i] = i
+	}
+	if !opts.SyncOrder {
+		sort.SliceStable(order, func(i, j int) bool {
+			return names[order[i]] < names[order[j]]
+		})
+	}
+
+	var written, entries []string
+	for _, i := range order {
+		r, name := resources[i], names[i]
+		path := filepath.Join(dir, name)
+		if err := writeFile(path, r.Raw); err != nil {
+			return nil, err
+		}
+		written = append(written, path)
+		entries = append(entries, filepath.ToSlash(name))
+	}
+
+	if opts.Kustomization {
+		data, err := yaml.Marshal(kustomization{
+			APIVersion: "kustomize.config.k8s.io/v1beta1",
+			Kind:       "Kustomization",
+			Resources:  entries,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", KustomizationFile, err)
+		}
+		path := filepath.Join(dir, KustomizationFile)
+		if err := writeFile(path, data); err != nil {
+			return nil, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// fileNames returns the file name of every resource. Resources sharing a
+// ResourceFileName, e.g. the same kind and name in different API groups, get
+// numbered suffixes in the order of their apiVersion rather than their
+// position in the output, so reordering by Helm does not swap their files.
+func fileNames(resources []manifest.Resource) []string {
+	order := make([]int, len(resources))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := resources[order[i]], resources[order[j]]
+		if nameA, nameB := ResourceFileName(a), ResourceFileName(b); nameA != nameB {
+			return nameA < nameB
+		}
+		return a.APIVersion < b.APIVersion
+	})
+
+	names := make([]string, len(resources))
+	used := make(map[string]bool)
+	for _, i := range order {
+		base := ResourceFileName(resources[i])
+		name := base
+		// A numbered suffix may match the file of another resource, e.g. a
+		// second `foo` next to a resource named `foo-2`, so keep counting
+		// until the name has not been taken yet.
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d.yaml", strings.TrimSuffix(base, ".yaml"), n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output subdirectory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest to %s: %w", path, err)
+	}
+	return nil
+}
+
+func sanitize(s string) string {
+	return strings.NewReplacer("/", "_", ":", "_", string(filepath.Separator), "_").Replace(s)
+}
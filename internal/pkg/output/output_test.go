@@ -0,0 +1,180 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const renderedManifests = `---
+# Source: chart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-app
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:my-app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: other-ns
+`
+
+func TestWriteFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev", "my-app.yaml")
+
+	files, err := Write(path, []byte(renderedManifests), Options{Mode: ModeFile})
+	require.NoError(t, err)
+	require.Equal(t, []string{path}, files)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, renderedManifests, string(content))
+}
+
+func TestWriteSplitMode(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "dev", "my-app.yaml")
+
+	files, err := Write(path, []byte(renderedManifests), Options{Mode: ModeSplit, Kustomization: true})
+	require.NoError(t, err)
+
+	appDir := filepath.Join(root, "dev", "my-app")
+	require.Equal(t, []string{
+		filepath.Join(appDir, "clusterrole-system_my-app.yaml"),
+		filepath.Join(appDir, "deployment-my-app.yaml"),
+		filepath.Join(appDir, "other-ns", "configmap-my-config.yaml"),
+		filepath.Join(appDir, "service-my-app.yaml"),
+		filepath.Join(appDir, KustomizationFile),
+	}, files)
+
+	deployment, err := os.ReadFile(filepath.Join(appDir, "deployment-my-app.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(deployment), "kind: Deployment")
+	require.NotContains(t, string(deployment), "kind: Service")
+
+	kustomization, err := os.ReadFile(filepath.Join(appDir, KustomizationFile))
+	require.NoError(t, err)
+	require.Contains(t, string(kustomization), "- other-ns/configmap-my-config.yaml")
+}
+
+func TestWriteSplitModeSyncOrder(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "dev", "my-app.yaml")
+
+	files, err := Write(path, []byte(renderedManifests), Options{Mode: ModeSplit, Kustomization: true, SyncOrder: true})
+	require.NoError(t, err)
+
+	appDir := filepath.Join(root, "dev", "my-app")
+	require.Equal(t, []string{
+		filepath.Join(appDir, "service-my-app.yaml"),
+		filepath.Join(appDir, "deployment-my-app.yaml"),
+		filepath.Join(appDir, "clusterrole-system_my-app.yaml"),
+		filepath.Join(appDir, "other-ns", "configmap-my-config.yaml"),
+		filepath.Join(appDir, KustomizationFile),
+	}, files)
+
+	kustomization, err := os.ReadFile(filepath.Join(appDir, KustomizationFile))
+	require.NoError(t, err)
+	require.Contains(t, string(kustomization), `resources:
+    - service-my-app.yaml
+    - deployment-my-app.yaml
+    - clusterrole-system_my-app.yaml
+    - other-ns/configmap-my-config.yaml
+`)
+}
+
+func TestWriteSplitModeRequiresKindAndName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "my-app.yaml")
+	_, err := Write(path, []byte("kind: FakedHelmOutputForApp\nname: my-app\n"), Options{Mode: ModeSplit})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot be split")
+}
+
+func TestWriteSplitModeDuplicateNames(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "my-app.yaml")
+	rendered := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo-2
+data:
+  id: real
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  id: first
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  id: second
+`
+
+	files, err := Write(path, []byte(rendered), Options{Mode: ModeSplit, SyncOrder: true})
+	require.NoError(t, err)
+
+	appDir := filepath.Join(root, "my-app")
+	require.Equal(t, []string{
+		filepath.Join(appDir, "configmap-foo-2.yaml"),
+		filepath.Join(appDir, "configmap-foo.yaml"),
+		filepath.Join(appDir, "configmap-foo-3.yaml"),
+	}, files)
+
+	real, err := os.ReadFile(filepath.Join(appDir, "configmap-foo-2.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(real), "id: real")
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	require.Equal(t, ModeFile, mode)
+
+	mode, err = ParseMode("split")
+	require.NoError(t, err)
+	require.Equal(t, ModeSplit, mode)
+
+	_, err = ParseMode("tree")
+	require.Error(t, err)
+}
+
+func TestWriteSplitModeSameNameInDifferentGroups(t *testing.T) {
+	certManager := "apiVersion: cert-manager.io/v1\nkind: Certificate\nmetadata:\n  name: web\n"
+	legacy := "apiVersion: certmanager.k8s.io/v1alpha1\nkind: Certificate\nmetadata:\n  name: web\n"
+
+	// The numbered file belongs to the same resource whatever the order of
+	// the Helm output is
+	for _, rendered := range []string{certManager + "---\n" + legacy, legacy + "---\n" + certManager} {
+		for _, syncOrder := range []bool{false, true} {
+			root := t.TempDir()
+			_, err := Write(filepath.Join(root, "my-app.yaml"), []byte(rendered), Options{Mode: ModeSplit, SyncOrder: syncOrder})
+			require.NoError(t, err)
+
+			first, err := os.ReadFile(filepath.Join(root, "my-app", "certificate-web.yaml"))
+			require.NoError(t, err)
+			require.Equal(t, certManager, string(first))
+			second, err := os.ReadFile(filepath.Join(root, "my-app", "certificate-web-2.yaml"))
+			require.NoError(t, err)
+			require.Equal(t, legacy, string(second))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/ext"
+)
+
+// Rules are evaluated by cel-go with the standard CEL library and version 2
+// of its strings extension (lowerAscii, upperAscii, split, join, replace,
+// trim, ...). Other extensions, including the Kubernetes libraries available
+// to ValidatingAdmissionPolicy (quantity(), url(), ...), are not enabled. The
+// variables object and application are dynamically typed, so selecting a
+// field that does not exist is an evaluation error rather than a compile
+// error.
+var environment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("application", cel.DynType),
+		ext.Strings(ext.StringsVersion(2)),
+	)
+})
+
+// compileExpression parses and type-checks a CEL expression.
+func compileExpression(src string) (*cel.Ast, cel.Program, error) {
+	env, err := environment()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(src)
+	if issues.Err() != nil {
+		return nil, nil, issues.Err()
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ast, program, nil
+}
+
+// compile compiles a policy rule, which must evaluate to a bool.
+func compile(src string) (cel.Program, error) {
+	ast, program, err := compileExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	if t := ast.OutputType(); !t.IsExactType(types.BoolType) && !t.IsExactType(types.DynType) {
+		return nil, fmt.Errorf("rule must evaluate to a bool, not %s", t)
+	}
+	return program, nil
+}
+
+func evaluateBool(program cel.Program, vars map[string]any) (bool, error) {
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("rule evaluated to %s, expected bool", out.Type().TypeName())
+	}
+	return b, nil
+}
+
+// fromYAML converts a value decoded by yaml.v3 into the values CEL works with:
+// integers are int64, timestamps are kept as the string they were written as
+// in RFC 3339, and map keys are strings.
+func fromYAML(v any) any {
+	switch t := v.(type) {
+	case int:
+		return int64(t)
+	case uint64:
+		return int64(t)
+	case time.Time:
+		return t.Format(time.RFC3339)
+	case []any:
+		items := make([]any, len(t))
+		for i, item := range t {
+			items[i] = fromYAML(item)
+		}
+		return items
+	case map[string]any:
+		obj := make(map[string]any, len(t))
+		for key, value := range t {
+			obj[key] = fromYAML(value)
+		}
+		return obj
+	case map[any]any:
+		obj := make(map[string]any, len(t))
+		for key, value := range t {
+			obj[fmt.Sprint(key)] = fromYAML(value)
+		}
+		return obj
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	object := map[string]any{
+		"kind": "Deployment",
+		"metadata": map[string]any{
+			"name":   "web",
+			"labels": map[string]any{"app": "web", "tier": "frontend"},
+		},
+		"spec": map[string]any{
+			"replicas": int64(3),
+			"containers": []any{
+				map[string]any{"name": "app", "image": "nginx:1.25", "resources": map[string]any{"limits": map[string]any{"cpu": "1"}}},
+				map[string]any{"name": "sidecar", "image": "busybox:latest"},
+			},
+		},
+	}
+	vars := map[string]any{"object": object, "application": map[string]any{"name": "web"}}
+
+	testCases := []struct {
+		expr          string
+		expected      any
+		errorContains string
+	}{
+		{expr: `object.metadata.name == "web"`, expected: true},
+		{expr: `object.spec.replicas >= 2 && object.spec.replicas < 10`, expected: true},
+		{expr: `double(object.spec.replicas) + 1.5 > 4.0`, expected: true},
+		{expr: `object.spec.replicas + 1.5`, errorContains: "no such overload"},
+		{expr: `object.spec.replicas == 3.0`, expected: true},
+		{expr: `size(object.spec.containers) == 2 && object.metadata.labels.size() == 2`, expected: true},
+		{expr: `object.spec.containers.all(c, !c.image.endsWith(":latest"))`, expected: false},
+		{expr: `object.spec.containers.exists(c, c.name == "sidecar")`, expected: true},
+		{expr: `object.spec.containers.exists_one(c, c.image.contains("nginx"))`, expected: true},
+		{expr: `object.spec.containers.all(c, has(c.resources) && has(c.resources.limits))`, expected: false},
+		{expr: `object.spec.containers.filter(c, has(c.resources)).map(c, c.name)`, expected: []string{"app"}},
+		{expr: `object.spec.containers.map(c, c.name.upperAscii())`, expected: []string{"APP", "SIDECAR"}},
+		{expr: `object.metadata.labels.all(k, k.matches("^[a-z]+$"))`, expected: true},
+		{expr: `"tier" in object.metadata.labels && "prod" in ["dev", "prod"]`, expected: true},
+		{expr: `has(object.spec.template) ? object.spec.template.spec : "none"`, expected: "none"},
+		{expr: `object.spec.containers[1].image.split(":")[1]`, expected: "latest"},
+		{expr: `['a', r'\d', "c\n"].join("-")`, expected: "a-\\d-c\n"},
+		{expr: `" web ".trim().replace("w", "W") == application.name.replace("w", "W")`, expected: true},
+		{expr: `{"a": 1}.a + int("2") - -1`, expected: int64(4)},
+		{expr: `string(7 / 2) + "/" + string(7 % 2)`, expected: "3/1"},
+		{expr: `[1, 2] + [3] == [1, 2, 3]`, expected: true},
+		// A missing field is an error, unless the result is decided by the
+		// other operand of && or ||.
+		{expr: `object.spec.template.spec == null`, errorContains: "no such key: template"},
+		{expr: `false && object.spec.template.spec == null`, expected: false},
+		{expr: `object.spec.template.spec == null || true`, expected: true},
+		{expr: `object.spec.containers[5]`, errorContains: "index out of bounds: 5"},
+		{expr: `object.spec.replicas / 0`, errorContains: "division by zero"},
+		{expr: `object.metadata.name + 1`, errorContains: "no such overload"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, program, err := compileExpression(tc.expr)
+			require.NoError(t, err)
+			out, _, err := program.Eval(vars)
+			if tc.errorContains != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.errorContains)
+				return
+			}
+			require.NoError(t, err)
+			value, err := out.ConvertToNative(reflect.TypeOf(tc.expected))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for expr, expected := range map[string]string{
+		`object.`:                   "Syntax error",
+		`(1 + 2`:                    "Syntax error",
+		`"unterminated`:             "Syntax error",
+		`object.spec @ 1`:           "Syntax error",
+		`object.metadata.name ==`:   "Syntax error",
+		`unknown.field`:             "undeclared reference to 'unknown'",
+		`object.spec.lowerAscii2()`: "undeclared reference to 'lowerAscii2'",
+		`size(object) + 1`:          "rule must evaluate to a bool, not int",
+		`"a" + "b"`:                 "rule must evaluate to a bool, not string",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := compile(expr)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), expected)
+		})
+	}
+}
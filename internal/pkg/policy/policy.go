@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/manifest"
+
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+)
+
+// ExemptionAnnotation is the Application annotation listing, comma-separated,
+// the policies that do not apply to it. Entries are globs, so "*" exempts the
+// Application from all policies.
+const ExemptionAnnotation = "policyExemptions"
+
+type Severity string
+
+const (
+	SeverityWarn Severity = "warn"
+	SeverityDeny Severity = "deny"
+)
+
+// Policy is a CEL rule evaluated against every rendered resource of the
+// matching kinds. The rule must evaluate to true for the resource to comply.
+type Policy struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description,omitempty"`
+	Severity    Severity `yaml:"severity,omitempty"`
+	// Kinds limits the policy to resources of these kinds; empty means all.
+	Kinds   []string `yaml:"kinds,omitempty"`
+	Rule    string   `yaml:"rule"`
+	Message string   `yaml:"message,omitempty"`
+
+	rule cel.Program
+}
+
+type policyFile struct {
+	Policies []*Policy `yaml:"policies"`
+}
+
+// Result is a resource that does not comply with a policy.
+type Result struct {
+	Policy   string
+	Severity Severity
+	Message  string
+	// Exempt is set when the Application is exempted from the policy; such
+	// results are reported but never fail the Application.
+	Exempt bool
+}
+
+type Set struct {
+	policies []*Policy
+}
+
+// Load loads the policies from the .yaml and .yml files in dirs and their
+// subdirectories. Every file holds a list of policies under `policies`;
+// policy names must be unique across all files.
+func Load(dirs ...string) (*Set, error) {
+	s := &Set{}
+	names := make(map[string]string)
+	for _, dir := range dirs {
+		if err := s.loadDir(dir, names); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Set) loadDir(dir string, names map[string]string) error {
+	return filepath.WalkDir(dir, func(file string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read policy directory %s: %w", dir, err)
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml":
+		case ".rego":
+			// Rego (OPA) is deliberately not supported; a policy directory
+			// written for conftest fails loudly instead of passing silently.
+			return fmt.Errorf("%s: Rego policies are not supported, express the rule in CEL", file)
+		default:
+			return nil
+		}
+		policies, err := parseFile(file)
+		if err != nil {
+			return err
+		}
+		for _, p := range policies {
+			if other, ok := names[p.Name]; ok {
+				return fmt.Errorf("%s: policy %q is already defined in %s", file, p.Name, other)
+			}
+			names[p.Name] = file
+			s.policies = append(s.policies, p)
+		}
+		return nil
+	})
+}
+
+func parseFile(file string) ([]*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", file, err)
+	}
+	var f policyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", file, err)
+	}
+	for i, p := range f.Policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%s: policy #%d has no name", file, i)
+		}
+		switch p.Severity {
+		case "":
+			p.Severity = SeverityDeny
+		case SeverityWarn, SeverityDeny:
+		default:
+			return nil, fmt.Errorf("%s: policy %q has unknown severity %q, expected warn or deny", file, p.Name, p.Severity)
+		}
+		if strings.TrimSpace(p.Rule) == "" {
+			return nil, fmt.Errorf("%s: policy %q has no rule", file, p.Name)
+		}
+		if p.rule, err = compile(p.Rule); err != nil {
+			return nil, fmt.Errorf("%s: invalid rule of policy %q: %w", file, p.Name, err)
+		}
+	}
+	return f.Policies, nil
+}
+
+// Len returns the number of policies in the set.
+func (s *Set) Len() int {
+	return len(s.policies)
+}
+
+// Evaluate checks a rendered resource of app against the policies matching its
+// kind. The resource is available to rules as `object` and the Application as
+// `application`. A rule that cannot be evaluated, e.g. because it selects a
+// missing field without has(), counts as not complied with.
+func (s *Set) Evaluate(resource manifest.Resource, app argo.Application) ([]Result, error) {
+	var decoded any
+	if err := yaml.Unmarshal(resource.Raw, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", resource, err)
+	}
+	vars := map[string]any{
+		"object":      fromYAML(decoded),
+		"application": applicationValue(app),
+	}
+	exemptions := exemptionsOf(app)
+
+	var results []Result
+	for _, p := range s.policies {
+		if !p.matches(resource.Kind) {
+			continue
+		}
+		message := p.failureMessage()
+		ok, err := evaluateBool(p.rule, vars)
+		if err != nil {
+			message = fmt.Sprintf("%s (rule could not be evaluated: %v)", message, err)
+		} else if ok {
+			continue
+		}
+		results = append(results, Result{
+			Policy:   p.Name,
+			Severity: p.Severity,
+			Message:  message,
+			Exempt:   isExempt(p.Name, exemptions),
+		})
+	}
+	return results, nil
+}
+
+func (p *Policy) matches(kind string) bool {
+	if len(p.Kinds) == 0 {
+		return true
+	}
+	for _, k := range p.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) failureMessage() string {
+	switch {
+	case p.Message != "":
+		return p.Message
+	case p.Description != "":
+		return p.Description
+	}
+	return "violates policy " + p.Name
+}
+
+func applicationValue(app argo.Application) map[string]any {
+	stringMap := func(m map[string]string) map[string]any {
+		out := make(map[string]any, len(m))
+		for key, value := range m {
+			out[key] = value
+		}
+		return out
+	}
+	return map[string]any{
+		"name":           app.Name,
+		"namespace":      app.Namespace,
+		"env":            app.Env,
+		"instance":       app.Instance,
+		"repoURL":        app.RepoURL,
+		"path":           app.Path,
+		"targetRevision": app.TargetRevision,
+		"labels":         stringMap(app.Labels),
+		"annotations":    stringMap(app.Annotations),
+		"destination": map[string]any{
+			"server":    app.Destination.Server,
+			"name":      app.Destination.Name,
+			"namespace": app.Destination.Namespace,
+		},
+	}
+}
+
+func exemptionsOf(app argo.Application) []string {
+	var patterns []string
+	for _, entry := range strings.Split(app.Annotations[ExemptionAnnotation], ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			patterns = append(patterns, entry)
+		}
+	}
+	return patterns
+}
+
+func isExempt(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
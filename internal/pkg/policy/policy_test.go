@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/manifest"
+
+	"github.com/stretchr/testify/require"
+)
+
+const orgPolicies = `policies:
+  - name: no-latest-tag
+    description: Container images must be pinned
+    kinds: [Deployment, StatefulSet, DaemonSet]
+    rule: >
+      object.spec.template.spec.containers.all(c,
+        c.image.contains(":") && !c.image.endsWith(":latest"))
+  - name: resource-limits
+    severity: warn
+    kinds: [Deployment, StatefulSet, DaemonSet]
+    rule: >
+      object.spec.template.spec.containers.all(c,
+        has(c.resources) && has(c.resources.limits))
+    message: containers must declare resource limits
+  - name: no-host-path
+    rule: >
+      !has(object.spec) || !has(object.spec.template) || !has(object.spec.template.spec.volumes) ||
+      object.spec.template.spec.volumes.all(v, !has(v.hostPath))
+  - name: env-label
+    severity: warn
+    rule: object.metadata.labels.env == application.env
+`
+
+func writePolicies(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestEvaluate(t *testing.T) {
+	set, err := Load(writePolicies(t, map[string]string{"org/rules.yaml": orgPolicies, "README.md": "docs"}))
+	require.NoError(t, err)
+	require.Equal(t, 4, set.Len())
+
+	resources, err := manifest.Parse([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: {env: dev}
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: nginx:latest
+      volumes:
+        - name: data
+          hostPath: {path: /var/data}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`))
+	require.NoError(t, err)
+
+	app := argo.Application{Name: "web", Env: "dev"}
+	results, err := set.Evaluate(resources[0], app)
+	require.NoError(t, err)
+	require.Equal(t, []Result{
+		{Policy: "no-latest-tag", Severity: SeverityDeny, Message: "Container images must be pinned"},
+		{Policy: "resource-limits", Severity: SeverityWarn, Message: "containers must declare resource limits"},
+		{Policy: "no-host-path", Severity: SeverityDeny, Message: "violates policy no-host-path"},
+	}, results)
+
+	results, err = set.Evaluate(resources[1], app)
+	require.NoError(t, err)
+	require.Equal(t, []Result{
+		{Policy: "env-label", Severity: SeverityWarn, Message: "violates policy env-label (rule could not be evaluated: no such key: labels)"},
+	}, results)
+
+	app.Annotations = map[string]string{ExemptionAnnotation: "no-latest-tag, no-host-*"}
+	results, err = set.Evaluate(resources[0], app)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.True(t, results[0].Exempt)
+	require.False(t, results[1].Exempt)
+	require.True(t, results[2].Exempt)
+}
+
+func TestLoadDirErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		files         map[string]string
+		errorContains string
+	}{
+		{
+			name:          "rego module",
+			files:         map[string]string{"deny.rego": "package main"},
+			errorContains: "Rego policies are not supported",
+		},
+		{
+			name:          "invalid rule",
+			files:         map[string]string{"a.yaml": "policies:\n  - name: broken\n    rule: object.spec.(\n"},
+			errorContains: `invalid rule of policy "broken"`,
+		},
+		{
+			name:          "unknown severity",
+			files:         map[string]string{"a.yaml": "policies:\n  - name: p\n    severity: error\n    rule: 'true'\n"},
+			errorContains: `unknown severity "error"`,
+		},
+		{
+			name:          "unknown field",
+			files:         map[string]string{"a.yaml": "policies:\n  - name: p\n    expression: 'true'\n"},
+			errorContains: "field expression not found",
+		},
+		{
+			name: "duplicate name",
+			files: map[string]string{
+				"a.yaml": "policies:\n  - name: p\n    rule: 'true'\n",
+				"b.yaml": "policies:\n  - name: p\n    rule: 'false'\n",
+			},
+			errorContains: `policy "p" is already defined`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(writePolicies(t, tc.files))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.errorContains)
+		})
+	}
+}
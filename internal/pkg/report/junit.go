@@ -0,0 +1,154 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the run report as JUnit XML: every application is a test
+// case and applications are grouped into suites by env/instance.
+func (r *RunReport) WriteJUnit(path string) error {
+	suites := make(map[string]*junitTestSuite)
+	var suiteNames []string
+	suiteMs := make(map[string]int64)
+
+	for _, app := range r.Applications {
+		suiteName := suiteNameOf(app)
+		suite, ok := suites[suiteName]
+		if !ok {
+			suite = &junitTestSuite{Name: suiteName}
+			suites[suiteName] = suite
+			suiteNames = append(suiteNames, suiteName)
+		}
+		durationMs := app.CloneDurationMs + app.RenderDurationMs
+		suiteMs[suiteName] += durationMs
+
+		testCase := junitTestCase{
+			Name:      app.Name,
+			ClassName: strings.ReplaceAll(suiteName, "/", "."),
+			Time:      seconds(durationMs),
+		}
+		switch {
+		case app.Skipped:
+			testCase.Skipped = &junitSkipped{Message: "filtered out"}
+			suite.Skipped++
+		case app.Error != "":
+			text := app.Stderr
+			if text == "" {
+				text = app.Error
+			}
+			failureType := "RenderError"
+			switch {
+			case len(app.SchemaErrors) > 0:
+				failureType = "SchemaError"
+			case app.Denied():
+				failureType = "PolicyViolation"
+			}
+			testCase.Failure = &junitFailure{Message: firstLine(app.Error), Type: failureType, Text: text}
+			suite.Failures++
+		case app.Reused:
+			testCase.SystemOut = "reused previous output:\n" + strings.Join(app.OutputFiles, "\n")
+		default:
+			testCase.SystemOut = strings.Join(app.OutputFiles, "\n")
+			if len(app.Policies) > 0 {
+				testCase.SystemOut += "\npolicy results:"
+				for _, result := range app.Policies {
+					testCase.SystemOut += "\n" + result.String()
+				}
+			}
+			if len(app.DeprecatedAPIs) > 0 {
+				testCase.SystemOut += "\ndeprecated APIs:"
+				for _, api := range app.DeprecatedAPIs {
+					testCase.SystemOut += "\n" + api.String()
+				}
+			}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	sort.Strings(suiteNames)
+	doc := junitTestSuites{Name: "roar", Time: seconds(r.DurationMs)}
+	for _, name := range suiteNames {
+		suite := suites[name]
+		suite.Time = seconds(suiteMs[name])
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		doc.Suites = append(doc.Suites, *suite)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report to %s: %w", path, err)
+	}
+	return nil
+}
+
+func suiteNameOf(app ApplicationReport) string {
+	var parts []string
+	if app.Env != "" {
+		parts = append(parts, app.Env)
+	}
+	if app.Instance != "" {
+		parts = append(parts, app.Instance)
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, "/")
+}
+
+func seconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
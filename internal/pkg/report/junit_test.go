@@ -0,0 +1,46 @@
+package report
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	runReport := NewRunReport("dev", "chart", nil, "rendered")
+	runReport.Applications = []ApplicationReport{
+		{Name: "ok", Env: "dev", Instance: "inf1", RenderDurationMs: 1500, OutputFiles: []string{"dev/inf1/ok.yaml"}},
+		{Name: "broken", Env: "dev", Instance: "inf1", Error: "failed to render chart: helm template failed\nOutput:\n...", Stderr: "Error: template: chart/templates/x.yaml:3: nil pointer"},
+		{Name: "filtered", Env: "prod", Skipped: true},
+		{Name: "no-labels", Error: "policy checks failed", Policies: []PolicyResult{{Policy: "no-latest", Severity: PolicyDeny, Resource: "Deployment web", Message: "images must be pinned"}}},
+	}
+	runReport.Finish(nil)
+
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	require.NoError(t, runReport.WriteJUnit(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(data, &doc))
+
+	require.Equal(t, 4, doc.Tests)
+	require.Equal(t, 2, doc.Failures)
+	require.Equal(t, 1, doc.Skipped)
+	require.Len(t, doc.Suites, 3)
+	require.Equal(t, []string{"default", "dev/inf1", "prod"}, []string{doc.Suites[0].Name, doc.Suites[1].Name, doc.Suites[2].Name})
+
+	devSuite := doc.Suites[1]
+	require.Equal(t, "1.500", devSuite.Time)
+	require.Equal(t, "dev.inf1", devSuite.Cases[0].ClassName)
+	require.Nil(t, devSuite.Cases[0].Failure)
+	require.NotNil(t, devSuite.Cases[1].Failure)
+	require.Equal(t, "failed to render chart: helm template failed", devSuite.Cases[1].Failure.Message)
+	require.Equal(t, "Error: template: chart/templates/x.yaml:3: nil pointer", devSuite.Cases[1].Failure.Text)
+
+	require.NotNil(t, doc.Suites[2].Cases[0].Skipped)
+	require.Equal(t, "PolicyViolation", doc.Suites[0].Cases[0].Failure.Type)
+}
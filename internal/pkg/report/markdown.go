@@ -0,0 +1,154 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"roar/internal/pkg/diff"
+)
+
+const DefaultMarkdownMaxDiff = 20000
+
+type MarkdownOptions struct {
+	Title string
+	// MaxDiffBytes limits the size of the diff shown for a single application;
+	// zero or less disables truncation.
+	MaxDiffBytes int
+}
+
+// WriteMarkdown renders a change set as a merge request note: a summary table
+// followed by a collapsible section with the diff of every changed application.
+func WriteMarkdown(w io.Writer, changes *diff.ChangeSet, opts MarkdownOptions) error {
+	var sb strings.Builder
+	title := opts.Title
+	if title == "" {
+		title = "roar: rendered manifests changes"
+	}
+	fmt.Fprintf(&sb, "### %s\n\n", title)
+
+	if !changes.HasChanges() && changes.Count(diff.StatusFailed) == 0 {
+		fmt.Fprintf(&sb, "No changes in %d applications.\n", len(changes.Apps))
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	fmt.Fprintf(&sb, "**%d** added, **%d** removed, **%d** modified, **%d** failed, %d unchanged applications.\n\n",
+		changes.Count(diff.StatusAdded), changes.Count(diff.StatusRemoved), changes.Count(diff.StatusModified),
+		changes.Count(diff.StatusFailed), changes.Count(diff.StatusUnchanged))
+
+	sb.WriteString("| Application | Status | Resources added | Resources modified | Resources removed | Parameters changed |\n")
+	sb.WriteString("|---|---|---:|---:|---:|---:|\n")
+	for _, app := range changes.Apps {
+		if app.Status == diff.StatusUnchanged {
+			continue
+		}
+		added, modified, removed := resourceCounts(app)
+		fmt.Fprintf(&sb, "| `%s` | %s | %d | %d | %d | %d |\n",
+			escapeCell(app.Name), app.Status, added, modified, removed, len(app.Params))
+	}
+	sb.WriteString("\n")
+
+	for _, app := range changes.Apps {
+		if app.Status == diff.StatusUnchanged {
+			continue
+		}
+		writeAppDetails(&sb, app, opts.MaxDiffBytes)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeAppDetails(sb *strings.Builder, app diff.AppChange, maxDiff int) {
+	fmt.Fprintf(sb, "<details><summary><code>%s</code>: %s</summary>\n\n", htmlEscape(app.Name), app.Status)
+
+	if app.Status == diff.StatusFailed {
+		fence := codeFence(app.Error)
+		fmt.Fprintf(sb, "%s\n%s\n%s\n\n</details>\n\n", fence, strings.TrimRight(app.Error, "\n"), fence)
+		return
+	}
+
+	if app.BaseError != "" {
+		fence := codeFence(app.BaseError)
+		fmt.Fprintf(sb, "_Failed to render at the base revision:_\n\n%s\n%s\n%s\n\n", fence, strings.TrimRight(app.BaseError, "\n"), fence)
+	}
+
+	if len(app.Params) > 0 {
+		sb.WriteString("| Parameter | Before | After |\n|---|---|---|\n")
+		for _, p := range app.Params {
+			fmt.Fprintf(sb, "| `%s` | `%s` | `%s` |\n", escapeCell(p.Name), escapeCell(p.Base), escapeCell(p.Head))
+		}
+		sb.WriteString("\n")
+	}
+
+	var diffText strings.Builder
+	for _, r := range app.Resources {
+		diffText.WriteString(r.Diff)
+	}
+	if diffText.Len() > 0 {
+		text, truncated := truncateLines(diffText.String(), maxDiff)
+		fence := codeFence(text)
+		fmt.Fprintf(sb, "%sdiff\n%s%s\n", fence, text, fence)
+		if truncated {
+			fmt.Fprintf(sb, "\n_Diff truncated: %d of %d bytes shown._\n", len(text), diffText.Len())
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</details>\n\n")
+}
+
+func resourceCounts(app diff.AppChange) (added, modified, removed int) {
+	for _, r := range app.Resources {
+		switch r.Status {
+		case diff.StatusAdded:
+			added++
+		case diff.StatusModified:
+			modified++
+		case diff.StatusRemoved:
+			removed++
+		}
+	}
+	return added, modified, removed
+}
+
+// truncateLines cuts s to at most limit bytes at a line boundary. A first
+// line longer than limit is cut in the middle, so that something is always
+// shown.
+func truncateLines(s string, limit int) (string, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+	cut := strings.LastIndexByte(s[:limit], '\n')
+	if cut < 0 {
+		cut = limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		return s[:cut] + "\n", true
+	}
+	return s[:cut+1], true
+}
+
+// codeFence returns a backtick fence longer than any backtick run in s.
+func codeFence(s string) string {
+	longest, current := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "`", "'").Replace(s)
+}
+
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
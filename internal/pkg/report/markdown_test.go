@@ -0,0 +1,72 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"roar/internal/pkg/diff"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	longDiff := "--- a\n+++ b\n" + strings.Repeat("+line with ``` fence\n", 50)
+	changes := &diff.ChangeSet{Apps: []diff.AppChange{
+		{Name: "app-added", Status: diff.StatusAdded, Resources: []diff.ResourceChange{
+			{Status: diff.StatusAdded, Diff: "--- /dev/null\n+++ b/app-added/v1 ConfigMap cm\n@@ -0,0 +1 @@\n+kind: ConfigMap\n"},
+		}},
+		{Name: "app-failed", Status: diff.StatusFailed, Error: "helm template failed"},
+		{Name: "app-modified", Status: diff.StatusModified,
+			Params:    []diff.ParamChange{{Name: "set global.image.tag", Base: "1.0", Head: "1.1"}},
+			Resources: []diff.ResourceChange{{Status: diff.StatusModified, Diff: longDiff}},
+		},
+		{Name: "app-same", Status: diff.StatusUnchanged},
+	}}
+
+	var sb strings.Builder
+	require.NoError(t, WriteMarkdown(&sb, changes, MarkdownOptions{MaxDiffBytes: 200}))
+	out := sb.String()
+
+	require.Contains(t, out, "**1** added, **0** removed, **1** modified, **1** failed, 1 unchanged applications.")
+	require.Contains(t, out, "| `app-added` | added | 1 | 0 | 0 | 0 |\n")
+	require.Contains(t, out, "| `app-modified` | modified | 0 | 1 | 0 | 1 |\n")
+	require.NotContains(t, out, "app-same")
+	require.Contains(t, out, "<details><summary><code>app-modified</code>: modified</summary>")
+	require.Contains(t, out, "| `set global.image.tag` | `1.0` | `1.1` |")
+	require.Contains(t, out, "````diff\n")
+	require.Contains(t, out, "_Diff truncated: ")
+	require.Contains(t, out, "helm template failed")
+}
+
+func TestWriteMarkdownNoChanges(t *testing.T) {
+	changes := &diff.ChangeSet{Apps: []diff.AppChange{{Name: "app", Status: diff.StatusUnchanged}}}
+
+	var sb strings.Builder
+	require.NoError(t, WriteMarkdown(&sb, changes, MarkdownOptions{}))
+	require.Contains(t, sb.String(), "No changes in 1 applications.")
+
+	// Failed applications are reported even without changes
+	changes.Apps = append(changes.Apps, diff.AppChange{Name: "broken", Status: diff.StatusFailed, Error: "boom"})
+	sb.Reset()
+	require.NoError(t, WriteMarkdown(&sb, changes, MarkdownOptions{}))
+	require.Contains(t, sb.String(), "**1** failed")
+	require.Contains(t, sb.String(), "boom")
+}
+
+func TestTruncateLines(t *testing.T) {
+	out, truncated := truncateLines("aaa\nbbb\nccc\n", 9)
+	require.True(t, truncated)
+	require.Equal(t, "aaa\nbbb\n", out)
+
+	out, truncated = truncateLines("aaaaaa\nbbb\n", 4)
+	require.True(t, truncated)
+	require.Equal(t, "aaaa\n", out)
+
+	out, truncated = truncateLines("ééé\n", 3)
+	require.True(t, truncated)
+	require.Equal(t, "é\n", out)
+
+	out, truncated = truncateLines("aaa\n", 0)
+	require.False(t, truncated)
+	require.Equal(t, "aaa\n", out)
+}
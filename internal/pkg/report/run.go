@@ -0,0 +1,280 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// RunReport is the machine-readable description of a single roar invocation.
+type RunReport struct {
+	Version      string              `json:"version"`
+	ChartPath    string              `json:"chartPath"`
+	ValuesFiles  []string            `json:"valuesFiles"`
+	OutputDir    string              `json:"outputDir"`
+	StartedAt    time.Time           `json:"startedAt"`
+	DurationMs   int64               `json:"durationMs"`
+	Error        string              `json:"error,omitempty"`
+	Summary      Summary             `json:"summary"`
+	Applications []ApplicationReport `json:"applications"`
+	// Duplicates lists the resources rendered by more than one application.
+	Duplicates []Duplicate `json:"duplicates,omitempty"`
+	// InvalidDocuments lists the problems of the app-of-apps documents that
+	// were skipped in lenient mode; a document may have several.
+	InvalidDocuments []InvalidDocument `json:"invalidDocuments,omitempty"`
+}
+
+type Summary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+	Reused    int `json:"reused"`
+	// CacheHits and CacheMisses count renders served from and added to the
+	// render cache; both stay zero when the cache is disabled.
+	CacheHits   int `json:"cacheHits"`
+	CacheMisses int `json:"cacheMisses"`
+	// PolicyWarnings and PolicyDenials count the policy results of all
+	// applications, not counting exempted ones.
+	PolicyWarnings int `json:"policyWarnings"`
+	PolicyDenials  int `json:"policyDenials"`
+	// DeprecatedAPIs and RemovedAPIs count the rendered resources using
+	// deprecated and removed Kubernetes APIs.
+	DeprecatedAPIs int `json:"deprecatedAPIs"`
+	RemovedAPIs    int `json:"removedAPIs"`
+	// Duplicates counts the resources rendered by more than one application.
+	Duplicates int `json:"duplicates"`
+	// InvalidDocuments counts the app-of-apps documents skipped in lenient
+	// mode.
+	InvalidDocuments int `json:"invalidDocuments"`
+}
+
+const (
+	RenderCacheHit  = "hit"
+	RenderCacheMiss = "miss"
+)
+
+const (
+	PolicyWarn = "warn"
+	PolicyDeny = "deny"
+)
+
+type ApplicationReport struct {
+	// Name is the qualified name of the Application, <namespace>/<name> for
+	// Applications outside of the Argo CD control plane namespace.
+	Name             string            `json:"name"`
+	Namespace        string            `json:"namespace,omitempty"`
+	Env              string            `json:"env,omitempty"`
+	Instance         string            `json:"instance,omitempty"`
+	RepoURL          string            `json:"repoURL"`
+	Revision         string            `json:"revision"`
+	CommitSHA        string            `json:"commitSHA,omitempty"`
+	ChartPath        string            `json:"chartPath"`
+	ValuesFiles      []string          `json:"valuesFiles"`
+	Setters          map[string]string `json:"setters"`
+	OutputFiles      []string          `json:"outputFiles"`
+	Resources        map[string]int    `json:"resources"`
+	CloneCached      bool              `json:"cloneCached"`
+	CloneDurationMs  int64             `json:"cloneDurationMs"`
+	RenderDurationMs int64             `json:"renderDurationMs"`
+	// RenderCache is "hit" or "miss" when the render cache is enabled.
+	RenderCache string `json:"renderCache,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	Reused      bool   `json:"reused,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Stderr is the diagnostic output of a failed `helm template` call.
+	Stderr string `json:"stderr,omitempty"`
+	// SchemaErrors lists the rendered resources that do not match their
+	// schemas.
+	SchemaErrors []SchemaError `json:"schemaErrors,omitempty"`
+	// Policies lists the rendered resources that do not comply with a
+	// policy.
+	Policies []PolicyResult `json:"policies,omitempty"`
+	// DeprecatedAPIs lists the rendered resources using deprecated or removed
+	// Kubernetes APIs.
+	DeprecatedAPIs []DeprecatedAPI `json:"deprecatedAPIs,omitempty"`
+}
+
+// Denied reports whether a policy with the deny severity failed the
+// application.
+func (a ApplicationReport) Denied() bool {
+	for _, result := range a.Policies {
+		if result.Denies() {
+			return true
+		}
+	}
+	return false
+}
+
+type SchemaError struct {
+	Resource string `json:"resource"`
+	// Source is the chart template the resource was rendered from.
+	Source  string `json:"source,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+type DeprecatedAPI struct {
+	Resource   string `json:"resource"`
+	Source     string `json:"source,omitempty"`
+	APIVersion string `json:"apiVersion"`
+	// Status is "deprecated" or "removed" as of the target Kubernetes
+	// version.
+	Status       string `json:"status"`
+	DeprecatedIn string `json:"deprecatedIn,omitempty"`
+	RemovedIn    string `json:"removedIn,omitempty"`
+	Replacement  string `json:"replacement,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
+
+func (a DeprecatedAPI) String() string {
+	s := fmt.Sprintf("%s (%s) uses %s API", a.Resource, a.APIVersion, a.Status)
+	if a.Replacement != "" {
+		s += ", migrate to " + a.Replacement
+	}
+	return s
+}
+
+// Duplicate is a resource rendered by several applications into the same
+// cluster and namespace; Argo CD reports such resources as shared.
+type Duplicate struct {
+	Cluster      string   `json:"cluster"`
+	Group        string   `json:"group,omitempty"`
+	Kind         string   `json:"kind"`
+	Namespace    string   `json:"namespace,omitempty"`
+	Name         string   `json:"name"`
+	Resource     string   `json:"resource"`
+	Applications []string `json:"applications"`
+}
+
+// InvalidDocument is a problem of a document of the app-of-apps output that
+// could not be turned into an Application.
+type InvalidDocument struct {
+	Document    int    `json:"document"`
+	Source      string `json:"source,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Column      int    `json:"column,omitempty"`
+	Application string `json:"application,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Message     string `json:"message"`
+}
+
+const (
+	APIDeprecated = "deprecated"
+	APIRemoved    = "removed"
+)
+
+type PolicyResult struct {
+	Policy   string `json:"policy"`
+	Severity string `json:"severity"`
+	Resource string `json:"resource"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+	// Exempt is set when the Application is exempted from the policy.
+	Exempt bool `json:"exempt,omitempty"`
+}
+
+// Denies reports whether the result fails the application.
+func (r PolicyResult) Denies() bool {
+	return r.Severity == PolicyDeny && !r.Exempt
+}
+
+func (r PolicyResult) String() string {
+	s := fmt.Sprintf("[%s] %s", r.Policy, r.Resource)
+	if r.Source != "" {
+		s += " (" + r.Source + ")"
+	}
+	s += ": " + r.Message
+	if r.Exempt {
+		s += " (exempt)"
+	}
+	return s
+}
+
+func (e SchemaError) String() string {
+	s := e.Resource
+	if e.Source != "" {
+		s += " (" + e.Source + ")"
+	}
+	if e.Path != "" {
+		s += " " + e.Path
+	}
+	return s + ": " + e.Message
+}
+
+func NewRunReport(version, chartPath string, valuesFiles []string, outputDir string) *RunReport {
+	return &RunReport{
+		Version:      version,
+		ChartPath:    chartPath,
+		ValuesFiles:  valuesFiles,
+		OutputDir:    outputDir,
+		StartedAt:    time.Now(),
+		Applications: []ApplicationReport{},
+	}
+}
+
+// Finish fills the summary and total duration; err is the error that aborted
+// the run, if any.
+func (r *RunReport) Finish(err error) {
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Summary = Summary{Total: len(r.Applications), Duplicates: len(r.Duplicates)}
+	documents := make(map[int]bool)
+	for _, document := range r.InvalidDocuments {
+		documents[document.Document] = true
+	}
+	r.Summary.InvalidDocuments = len(documents)
+	for _, app := range r.Applications {
+		switch {
+		case app.Skipped:
+			r.Summary.Skipped++
+		case app.Error != "":
+			r.Summary.Failed++
+		default:
+			r.Summary.Succeeded++
+		}
+		if app.Reused {
+			r.Summary.Reused++
+		}
+		switch app.RenderCache {
+		case RenderCacheHit:
+			r.Summary.CacheHits++
+		case RenderCacheMiss:
+			r.Summary.CacheMisses++
+		}
+		for _, api := range app.DeprecatedAPIs {
+			if api.Status == APIRemoved {
+				r.Summary.RemovedAPIs++
+			} else {
+				r.Summary.DeprecatedAPIs++
+			}
+		}
+		for _, result := range app.Policies {
+			switch {
+			case result.Exempt:
+			case result.Severity == PolicyWarn:
+				r.Summary.PolicyWarnings++
+			case result.Severity == PolicyDeny:
+				r.Summary.PolicyDenials++
+			}
+		}
+	}
+}
+
+func (r *RunReport) WriteJSON(path string) error {
+	for i := range r.Applications {
+		sort.Strings(r.Applications[i].OutputFiles)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run report to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReportWriteJSON(t *testing.T) {
+	runReport := NewRunReport("1.0.0", "charts/app-of-apps", []string{"values.yaml"}, "rendered")
+	runReport.Applications = append(runReport.Applications,
+		ApplicationReport{Name: "ok", OutputFiles: []string{"b.yaml", "a.yaml"}, Resources: map[string]int{"Deployment": 1}, Policies: []PolicyResult{
+			{Policy: "limits", Severity: PolicyWarn, Resource: "Deployment web"},
+			{Policy: "no-latest", Severity: PolicyDeny, Resource: "Deployment web", Exempt: true},
+		}},
+		ApplicationReport{Name: "broken", Error: "failed to render chart"},
+	)
+	runReport.InvalidDocuments = []InvalidDocument{
+		{Document: 3, Message: "yaml: line 4: mapping values are not allowed in this context"},
+		{Document: 5, Line: 7, Column: 3, Application: "api", Field: "spec.source.targetRevision", Message: "is missing"},
+		{Document: 5, Line: 9, Column: 5, Application: "api", Field: "spec.source.helm", Message: "helm sources are not supported"},
+	}
+	runReport.Finish(errors.New("aborted"))
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, runReport.WriteJSON(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var loaded map[string]any
+	require.NoError(t, json.Unmarshal(data, &loaded))
+	require.Equal(t, "1.0.0", loaded["version"])
+	require.Equal(t, "aborted", loaded["error"])
+	require.Equal(t, map[string]any{"total": 2.0, "succeeded": 1.0, "failed": 1.0, "skipped": 0.0, "reused": 0.0, "cacheHits": 0.0, "cacheMisses": 0.0, "policyWarnings": 1.0, "policyDenials": 0.0, "deprecatedAPIs": 0.0, "removedAPIs": 0.0, "duplicates": 0.0, "invalidDocuments": 2.0}, loaded["summary"])
+
+	apps := loaded["applications"].([]any)
+	require.Equal(t, []any{"a.yaml", "b.yaml"}, apps[0].(map[string]any)["outputFiles"])
+	require.Equal(t, "failed to render chart", apps[1].(map[string]any)["error"])
+}
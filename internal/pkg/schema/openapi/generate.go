@@ -0,0 +1,222 @@
+//go:build ignore
+
+// generate.go writes the bundled schemas of built-in resources, one
+// v<major>.<minor>.json file per supported Kubernetes minor version. For every
+// version it downloads the upstream OpenAPI v3 documents of the group versions
+// below, keeps the schemas of the listed kinds and the components they
+// reference, and drops everything the validator does not read, such as
+// descriptions and defaults. The status of a resource is reduced to an object:
+// controllers set it, manifests do not.
+//
+// Run it with `go generate ./internal/pkg/schema` after changing the versions
+// or the kinds.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// versions are the supported Kubernetes minor versions of major version 1.
+var versions = []int{25, 26, 27, 28, 29, 30, 31, 32}
+
+// sources lists the upstream documents and the kinds taken from them; kinds
+// missing from a version are skipped.
+var sources = []struct {
+	file    string
+	group   string
+	version string
+	kinds   []string
+}{
+	{"api__v1_openapi.json", "", "v1", []string{"ConfigMap", "Namespace", "PersistentVolumeClaim", "Pod", "Secret", "Service", "ServiceAccount"}},
+	{"apis__apps__v1_openapi.json", "apps", "v1", []string{"DaemonSet", "Deployment", "StatefulSet"}},
+	{"apis__batch__v1_openapi.json", "batch", "v1", []string{"CronJob", "Job"}},
+	{"apis__networking.k8s.io__v1_openapi.json", "networking.k8s.io", "v1", []string{"Ingress", "IngressClass", "NetworkPolicy"}},
+	{"apis__policy__v1_openapi.json", "policy", "v1", []string{"PodDisruptionBudget"}},
+	{"apis__autoscaling__v2_openapi.json", "autoscaling", "v2", []string{"HorizontalPodAutoscaler"}},
+	{"apis__rbac.authorization.k8s.io__v1_openapi.json", "rbac.authorization.k8s.io", "v1", []string{"ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding"}},
+	{"apis__admissionregistration.k8s.io__v1_openapi.json", "admissionregistration.k8s.io", "v1", []string{"ValidatingAdmissionPolicy", "ValidatingAdmissionPolicyBinding"}},
+}
+
+// keep lists the schema keywords the validator reads.
+var keep = map[string]bool{
+	"type": true, "properties": true, "required": true, "additionalProperties": true, "items": true,
+	"enum": true, "nullable": true, "allOf": true, "anyOf": true, "oneOf": true, "$ref": true,
+	"x-kubernetes-int-or-string": true, "x-kubernetes-preserve-unknown-fields": true,
+	"x-kubernetes-group-version-kind": true,
+}
+
+const (
+	baseURL       = "https://raw.githubusercontent.com/kubernetes/kubernetes/v1.%d.0/api/openapi-spec/v3/%s"
+	componentsRef = "#/components/schemas/"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run generate.go OUTPUT_DIR")
+		os.Exit(2)
+	}
+	for _, minor := range versions {
+		if err := generate(os.Args[1], minor); err != nil {
+			fmt.Fprintf(os.Stderr, "Kubernetes 1.%d: %v\n", minor, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func generate(dir string, minor int) error {
+	components := make(map[string]map[string]any)
+	var roots []string
+	for _, source := range sources {
+		var doc struct {
+			Components struct {
+				Schemas map[string]map[string]any `json:"schemas"`
+			} `json:"components"`
+		}
+		if err := download(fmt.Sprintf(baseURL, minor, source.file), &doc); err != nil {
+			return err
+		}
+		for name, schema := range doc.Components.Schemas {
+			components[name] = schema
+			if isKind(schema, source.group, source.version, source.kinds) {
+				roots = append(roots, name)
+			}
+		}
+	}
+
+	schemas := make(map[string]any)
+	for len(roots) > 0 {
+		name := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		if _, done := schemas[name]; done {
+			continue
+		}
+		schema, ok := components[name]
+		if !ok {
+			return fmt.Errorf("unknown component %s", name)
+		}
+		trimmed := trim(schema).(map[string]any)
+		if _, isKind := trimmed["x-kubernetes-group-version-kind"]; isKind {
+			if props, ok := trimmed["properties"].(map[string]any); ok && props["status"] != nil {
+				props["status"] = map[string]any{"type": "object"}
+			}
+		}
+		schemas[name] = trimmed
+		roots = append(roots, references(trimmed)...)
+	}
+
+	out, err := os.Create(filepath.Join(dir, fmt.Sprintf("v1.%d.json", minor)))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{
+		"components": map[string]any{"schemas": schemas},
+		"info":       map[string]any{"title": "Kubernetes", "version": fmt.Sprintf("v1.%d.0", minor)},
+		"openapi":    "3.0.0",
+	})
+}
+
+func download(url string, v any) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func isKind(schema map[string]any, group, version string, kinds []string) bool {
+	gvks, _ := schema["x-kubernetes-group-version-kind"].([]any)
+	for _, gvk := range gvks {
+		gvk, _ := gvk.(map[string]any)
+		if gvk["group"] != group || gvk["version"] != version {
+			continue
+		}
+		for _, kind := range kinds {
+			if gvk["kind"] == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trim drops the keywords not in keep and replaces an allOf of a single $ref,
+// which upstream uses to attach a description, with the $ref.
+func trim(v any) any {
+	schema, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	trimmed := make(map[string]any)
+	for key, value := range schema {
+		if !keep[key] {
+			continue
+		}
+		switch key {
+		case "properties":
+			props := make(map[string]any)
+			for name, prop := range value.(map[string]any) {
+				props[name] = trim(prop)
+			}
+			value = props
+		case "items", "additionalProperties":
+			value = trim(value)
+		case "allOf", "anyOf", "oneOf":
+			var subs []any
+			for _, sub := range value.([]any) {
+				subs = append(subs, trim(sub))
+			}
+			value = subs
+		}
+		trimmed[key] = value
+	}
+	if allOf, ok := trimmed["allOf"].([]any); ok && len(trimmed) == 1 && len(allOf) == 1 {
+		if ref, ok := allOf[0].(map[string]any); ok && len(ref) == 1 && ref["$ref"] != nil {
+			return ref
+		}
+	}
+	return trimmed
+}
+
+// references returns the components a trimmed schema refers to.
+func references(v any) []string {
+	var refs []string
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if ref, ok := v[key].(string); ok && key == "$ref" {
+				refs = append(refs, strings.TrimPrefix(ref, componentsRef))
+				continue
+			}
+			refs = append(refs, references(v[key])...)
+		}
+	case []any:
+		for _, item := range v {
+			refs = append(refs, references(item)...)
+		}
+	}
+	return refs
+}
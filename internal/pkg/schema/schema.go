@@ -0,0 +1,296 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the subset of the OpenAPI v3 schema used by Kubernetes structural
+// schemas (and therefore by CRDs) that roar validates. Schema directories are
+// YAML, the bundled schemas of built-in resources JSON.
+type Schema struct {
+	Type                 string             `yaml:"type" json:"type"`
+	Properties           map[string]*Schema `yaml:"properties" json:"properties"`
+	Required             []string           `yaml:"required" json:"required"`
+	AdditionalProperties *Additional        `yaml:"additionalProperties" json:"additionalProperties"`
+	Items                *Schema            `yaml:"items" json:"items"`
+	Enum                 []any              `yaml:"enum" json:"enum"`
+	Nullable             bool               `yaml:"nullable" json:"nullable"`
+	AnyOf                []*Schema          `yaml:"anyOf" json:"anyOf"`
+	OneOf                []*Schema          `yaml:"oneOf" json:"oneOf"`
+	AllOf                []*Schema          `yaml:"allOf" json:"allOf"`
+	Ref                  string             `yaml:"$ref" json:"$ref"`
+	IntOrString          bool               `yaml:"x-kubernetes-int-or-string" json:"x-kubernetes-int-or-string"`
+	PreserveUnknown      bool               `yaml:"x-kubernetes-preserve-unknown-fields" json:"x-kubernetes-preserve-unknown-fields"`
+}
+
+// Additional is the value of additionalProperties: either a boolean or the
+// schema of every additional property.
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *Additional) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&a.Allowed)
+	}
+	a.Allowed = true
+	return node.Decode(&a.Schema)
+}
+
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// Violation is a single place where a resource does not match its schema.
+type Violation struct {
+	// Path is the location in the resource, e.g. spec.template.spec.containers[0].image.
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Prefixes of $ref: definitions are those of the schema bundles in schema
+// directories, components the bundled schemas of built-in resources.
+const (
+	definitionsRef = "#/definitions/"
+	componentsRef  = "#/components/schemas/"
+)
+
+// validator resolves $ref against the definitions of the schema set and the
+// components of the Kubernetes version the resource is validated for.
+type validator struct {
+	definitions map[string]*Schema
+	components  map[string]*Schema
+	violations  []Violation
+}
+
+func (v *validator) report(path, format string, args ...any) {
+	v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) resolve(s *Schema) (*Schema, error) {
+	for s != nil && s.Ref != "" {
+		var def *Schema
+		var ok bool
+		if name, isComponent := strings.CutPrefix(s.Ref, componentsRef); isComponent {
+			def, ok = v.components[name]
+		} else {
+			def, ok = v.definitions[strings.TrimPrefix(s.Ref, definitionsRef)]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown schema reference %s", s.Ref)
+		}
+		s = def
+	}
+	return s, nil
+}
+
+func (v *validator) validate(s *Schema, value any, path string) {
+	s, err := v.resolve(s)
+	if err != nil {
+		v.report(path, "%v", err)
+		return
+	}
+	if s == nil {
+		return
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" && !s.PreserveUnknown {
+			v.report(path, "must not be null")
+		}
+		return
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(sub, value, path)
+	}
+	for _, alternatives := range [][]*Schema{s.AnyOf, s.OneOf} {
+		if len(alternatives) > 0 && !v.matchesAny(alternatives, value, path) {
+			v.reportNoMatch(alternatives, value, path)
+		}
+	}
+
+	if s.IntOrString {
+		if !isString(value) && !isInteger(value) {
+			v.report(path, "expected integer or string, got %s", typeName(value))
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.report(path, "expected object, got %s", typeName(value))
+			return
+		}
+		v.validateObject(s, obj, path)
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			v.report(path, "expected array, got %s", typeName(value))
+			return
+		}
+		for i, item := range items {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "string":
+		if !isString(value) {
+			v.report(path, "expected string, got %s", typeName(value))
+			return
+		}
+	case "integer":
+		if !isInteger(value) {
+			v.report(path, "expected integer, got %s", typeName(value))
+			return
+		}
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+		default:
+			v.report(path, "expected number, got %s", typeName(value))
+			return
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.report(path, "expected boolean, got %s", typeName(value))
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		v.report(path, "unsupported value %v, expected one of: %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validator) validateObject(s *Schema, obj map[string]any, path string) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			v.report(path, "missing required field %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(prop, obj[name], fieldPath)
+			continue
+		}
+		switch {
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			v.validate(s.AdditionalProperties.Schema, obj[name], fieldPath)
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Allowed:
+		case s.PreserveUnknown:
+		case len(s.Properties) == 0 && s.AdditionalProperties == nil:
+			// An object schema without properties accepts any field.
+		default:
+			v.report(path, "unknown field %q", name)
+		}
+	}
+}
+
+// matchesAny reports whether value matches at least one of the schemas; the
+// violations of the alternatives are not reported.
+func (v *validator) matchesAny(schemas []*Schema, value any, path string) bool {
+	for _, s := range schemas {
+		sub := &validator{definitions: v.definitions, components: v.components}
+		sub.validate(s, value, path)
+		if len(sub.violations) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reportNoMatch reports a value matching none of the alternatives. When they
+// only differ in type, as for IntOrString and Quantity, the expected types are
+// named.
+func (v *validator) reportNoMatch(alternatives []*Schema, value any, path string) {
+	types := make([]string, len(alternatives))
+	for i, s := range alternatives {
+		if s.Type == "" || !reflect.DeepEqual(*s, Schema{Type: s.Type, Nullable: s.Nullable}) {
+			v.report(path, "does not match any of the allowed schemas")
+			return
+		}
+		types[i] = s.Type
+	}
+	v.report(path, "expected %s, got %s", strings.Join(types, " or "), typeName(value))
+}
+
+// isString reports whether value is a string. Unquoted timestamps are decoded
+// as time.Time by yaml.v3 but are strings for Kubernetes.
+func isString(value any) bool {
+	switch value.(type) {
+	case string, time.Time:
+		return true
+	}
+	return false
+}
+
+func isInteger(value any) bool {
+	switch n := value.(type) {
+	case int, int64, uint64:
+		return true
+	case float64:
+		return n == math.Trunc(n)
+	}
+	return false
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string, time.Time:
+		return "string"
+	case int, int64, uint64, float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,360 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"roar/internal/pkg/manifest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseResource(t *testing.T, doc string) manifest.Resource {
+	t.Helper()
+	resources, err := manifest.Parse([]byte(doc))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	return resources[0]
+}
+
+func TestValidateBundled(t *testing.T) {
+	set, err := Bundled()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		doc         string
+		kubeVersion string
+		expected    []string
+		notFound    bool
+	}{
+		{
+			name: "valid deployment",
+			doc: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: {app: web}
+  creationTimestamp: null
+spec:
+  replicas: 2
+  selector:
+    matchLabels: {app: web}
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+          ports:
+            - containerPort: 80
+          resources:
+            limits: {cpu: 500m, memory: 128Mi}
+            requests: {cpu: 1}
+`,
+		},
+		{
+			name: "wrong types, unknown fields",
+			doc: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: {version: 2}
+spec:
+  replicas: "2"
+  selector:
+    matchLabels: {app: web}
+  template:
+    spec:
+      containers:
+        - name: web
+          imagePullPolicy: Sometimes
+          ports:
+            - containerPort: http
+      nodeSelecter: {disk: ssd}
+`,
+			expected: []string{
+				"metadata.labels.version: expected string, got number",
+				"spec.replicas: expected integer, got string",
+				`spec.template.spec: unknown field "nodeSelecter"`,
+				"spec.template.spec.containers[0].imagePullPolicy: unsupported value Sometimes, expected one of: Always, IfNotPresent, Never",
+				"spec.template.spec.containers[0].ports[0].containerPort: expected integer, got string",
+			},
+		},
+		{
+			name: "missing required fields",
+			doc: `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  schedule: "@daily"
+`,
+			expected: []string{`spec: missing required field "jobTemplate"`},
+		},
+		{
+			name: "int or string",
+			doc: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+      targetPort: http
+    - port: 443
+      targetPort: 8443
+    - port: 8080
+      targetPort: [1]
+`,
+			expected: []string{"spec.ports[2].targetPort: expected integer or string, got array"},
+		},
+		{
+			name: "status is not checked",
+			doc: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector: {}
+  template: {}
+status:
+  replicas: 1
+  anything: goes
+`,
+		},
+		{
+			name: "api not served by the target version",
+			doc: `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: require-labels
+spec:
+  policyName: require-labels
+  validationActions: [Deny]
+`,
+			kubeVersion: "v1.29.4",
+			expected:    []string{"admissionregistration.k8s.io/v1 ValidatingAdmissionPolicyBinding is not served by Kubernetes v1.29.4, it is available since 1.30"},
+		},
+		{
+			name: "api served by the target version",
+			doc: `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: require-labels
+spec:
+  policyName: require-labels
+  validationActions: [Deny]
+`,
+			kubeVersion: "1.30.0",
+		},
+		{
+			name: "field added after the target version",
+			doc: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  managedBy: example.com/queue
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+        - name: migrate
+          image: migrate:1.0
+`,
+			kubeVersion: "1.29",
+			expected:    []string{`spec: unknown field "managedBy"`},
+		},
+		{
+			name: "field served by the target version",
+			doc: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  managedBy: example.com/queue
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+        - name: migrate
+          image: migrate:1.0
+`,
+			kubeVersion: "1.30",
+		},
+		{
+			name: "no schema",
+			doc: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  anything: goes
+`,
+			notFound: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, found := set.Validate(parseResource(t, tc.doc), tc.kubeVersion)
+			require.Equal(t, !tc.notFound, found)
+			var messages []string
+			for _, v := range violations {
+				messages = append(messages, v.String())
+			}
+			require.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestKubernetesVersion(t *testing.T) {
+	set, err := Bundled()
+	require.NoError(t, err)
+
+	require.Equal(t, "1.32", set.KubernetesVersion(""))
+	require.Equal(t, "1.29", set.KubernetesVersion("v1.29.4"))
+	require.Equal(t, "1.25", set.KubernetesVersion("1.20"))
+	require.Equal(t, "1.32", set.KubernetesVersion("1.40"))
+}
+
+func TestLoadDirCRD(t *testing.T) {
+	dir := t.TempDir()
+	crd := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [size]
+              properties:
+                size:
+                  type: integer
+                  minimum: 1
+                color:
+                  type: string
+                  enum: [red, green]
+    - name: v1alpha1
+      served: false
+      storage: false
+`
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "example"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "example", "widgets.yaml"), []byte(crd), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a schema"), 0644))
+
+	set, err := Bundled()
+	require.NoError(t, err)
+	kinds := set.Kinds()
+	require.NoError(t, set.LoadDir(dir))
+	require.Equal(t, kinds+1, set.Kinds())
+
+	violations, found := set.Validate(parseResource(t, `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+  labels: {size: large}
+spec:
+  color: blue
+  shape: round
+`), "")
+	require.True(t, found)
+	var messages []string
+	for _, v := range violations {
+		messages = append(messages, v.String())
+	}
+	require.Equal(t, []string{
+		`spec: missing required field "size"`,
+		`spec: unknown field "shape"`,
+		"spec.color: unsupported value blue, expected one of: red, green",
+	}, messages)
+
+	_, found = set.Validate(parseResource(t, "apiVersion: example.com/v1alpha1\nkind: Widget\nmetadata:\n  name: w\n"), "")
+	require.False(t, found)
+}
+
+func TestLoadDirBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := `definitions:
+  Size: {type: integer}
+resources:
+  - apiVersion: example.com/v1
+    kind: Runner
+    schema:
+      type: object
+      properties:
+        apiVersion: {type: string}
+        kind: {type: string}
+        metadata: {$ref: "#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}
+        size: {$ref: "#/definitions/Size"}
+        template: {$ref: "#/components/schemas/io.k8s.api.core.v1.PodTemplateSpec"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "runners.yaml"), []byte(bundle), 0644))
+
+	set, err := Bundled()
+	require.NoError(t, err)
+	require.NoError(t, set.LoadDir(dir))
+
+	doc := `apiVersion: example.com/v1
+kind: Runner
+metadata:
+  name: r
+size: large
+template:
+  spec:
+    containers:
+      - name: runner
+        resizePolicy: []
+`
+	messages := func(kubeVersion string) []string {
+		violations, found := set.Validate(parseResource(t, doc), kubeVersion)
+		require.True(t, found)
+		var messages []string
+		for _, v := range violations {
+			messages = append(messages, v.String())
+		}
+		return messages
+	}
+	require.Equal(t, []string{
+		"size: expected integer, got string",
+		`template.spec.containers[0]: unknown field "resizePolicy"`,
+	}, messages("1.26"))
+	require.Equal(t, []string{"size: expected integer, got string"}, messages("1.27"))
+}
+
+func TestLoadDirInvalid(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("resources:\n  - kind: Widget\n"), 0644))
+
+	set, err := Bundled()
+	require.NoError(t, err)
+	err = set.LoadDir(dir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "broken.yaml")
+}
+
+func TestLoadDirInvalidSince(t *testing.T) {
+	dir := t.TempDir()
+	bundle := "resources:\n  - apiVersion: example.com/v1\n    kind: Widget\n    since: latest\n    schema: {type: object}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "widgets.yaml"), []byte(bundle), 0644))
+
+	set, err := Bundled()
+	require.NoError(t, err)
+	require.ErrorContains(t, set.LoadDir(dir), "since of example.com/v1 Widget: invalid Kubernetes version 'latest'")
+}
@@ -0,0 +1,374 @@
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"roar/internal/pkg/kubeversion"
+	"roar/internal/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run openapi/generate.go openapi
+
+// bundledSchemas holds the OpenAPI v3 schemas of the built-in resources of
+// every supported Kubernetes minor version, one v<major>.<minor>.json file per
+// version. They are trimmed copies of the upstream api/openapi-spec/v3
+// documents written by openapi/generate.go.
+//
+//go:embed openapi/*.json
+var bundledSchemas embed.FS
+
+// objectMetaRef is the schema of the metadata of every resource.
+const objectMetaRef = componentsRef + "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+
+// Set is a collection of resource schemas keyed by apiVersion and kind: the
+// bundled schemas of built-in resources, selected by the Kubernetes version a
+// resource is validated for, and the schemas loaded from schema directories,
+// which take precedence.
+type Set struct {
+	// kubernetes holds the bundled schemas ordered by version.
+	kubernetes  []*kubernetesSchemas
+	definitions map[string]*Schema
+	resources   map[string]resourceSchema
+}
+
+// kubernetesSchemas are the bundled schemas of one Kubernetes minor version.
+type kubernetesSchemas struct {
+	version    string
+	components map[string]*Schema
+	resources  map[string]*Schema
+}
+
+type resourceSchema struct {
+	// Since is the first Kubernetes minor version, e.g. "1.21", that serves
+	// the API; empty when it has always been served.
+	Since  string
+	Schema *Schema
+	// Origin is the file the schema was loaded from.
+	Origin string
+}
+
+// bundle is the format of bundle files in schema directories.
+type bundle struct {
+	Definitions map[string]*Schema `yaml:"definitions"`
+	Resources   []struct {
+		APIVersion string  `yaml:"apiVersion"`
+		Kind       string  `yaml:"kind"`
+		Since      string  `yaml:"since"`
+		Schema     *Schema `yaml:"schema"`
+	} `yaml:"resources"`
+}
+
+// openAPIDocument is the part of an upstream OpenAPI v3 document roar reads.
+type openAPIDocument struct {
+	Components struct {
+		Schemas map[string]*struct {
+			Schema
+			GroupVersionKinds []struct {
+				Group   string `json:"group"`
+				Version string `json:"version"`
+				Kind    string `json:"kind"`
+			} `json:"x-kubernetes-group-version-kind"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+type customResourceDefinition struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Spec       struct {
+		Group string `yaml:"group"`
+		Names struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"names"`
+		Versions []struct {
+			Name   string `yaml:"name"`
+			Served *bool  `yaml:"served"`
+			Schema struct {
+				OpenAPIV3Schema *Schema `yaml:"openAPIV3Schema"`
+			} `yaml:"schema"`
+		} `yaml:"versions"`
+	} `yaml:"spec"`
+}
+
+// Bundled returns the set of the upstream schemas of built-in Kubernetes
+// resources shipped with roar. Like the API server, they reject unknown fields
+// unless upstream preserves them.
+func Bundled() (*Set, error) {
+	s := &Set{definitions: make(map[string]*Schema), resources: make(map[string]resourceSchema)}
+	files, err := fs.Glob(bundledSchemas, "openapi/*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		version := strings.TrimPrefix(strings.TrimSuffix(path.Base(file), ".json"), "v")
+		if err := kubeversion.Check(version); err != nil {
+			return nil, fmt.Errorf("bundled schemas %s: %w", file, err)
+		}
+		data, err := bundledSchemas.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		kube, err := parseOpenAPI(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load bundled schemas %s: %w", file, err)
+		}
+		kube.version = version
+		s.kubernetes = append(s.kubernetes, kube)
+	}
+	if len(s.kubernetes) == 0 {
+		return nil, fmt.Errorf("no bundled schemas")
+	}
+	sort.Slice(s.kubernetes, func(i, j int) bool {
+		return kubeversion.Less(s.kubernetes[i].version, s.kubernetes[j].version)
+	})
+	return s, nil
+}
+
+func parseOpenAPI(data []byte) (*kubernetesSchemas, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	kube := &kubernetesSchemas{components: make(map[string]*Schema), resources: make(map[string]*Schema)}
+	for name, component := range doc.Components.Schemas {
+		schema := &component.Schema
+		acceptNull(schema)
+		kube.components[name] = schema
+		for _, gvk := range component.GroupVersionKinds {
+			apiVersion := gvk.Version
+			if gvk.Group != "" {
+				apiVersion = gvk.Group + "/" + gvk.Version
+			}
+			kube.resources[resourceKey(apiVersion, gvk.Kind)] = schema
+		}
+	}
+	return kube, nil
+}
+
+// acceptNull makes s and its subschemas nullable: the API server decodes null
+// as the zero value of a field of a built-in resource, as in the
+// creationTimestamp: null helm charts often render.
+func acceptNull(s *Schema) {
+	if s == nil {
+		return
+	}
+	s.Nullable = true
+	for _, prop := range s.Properties {
+		acceptNull(prop)
+	}
+	if s.AdditionalProperties != nil {
+		acceptNull(s.AdditionalProperties.Schema)
+	}
+	acceptNull(s.Items)
+	for _, sub := range append(append(append([]*Schema{}, s.AllOf...), s.AnyOf...), s.OneOf...) {
+		acceptNull(sub)
+	}
+}
+
+// LoadDir adds the schemas from the .yaml and .yml files in dir and its
+// subdirectories. A file may contain CustomResourceDefinition manifests or
+// bundles of schemas; schemas of a resource already in the set replace the
+// existing ones, including the bundled ones of every Kubernetes version.
+func (s *Set) LoadDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read schema directory %s: %w", dir, err)
+		}
+		ext := filepath.Ext(path)
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read schema file %s: %w", path, err)
+		}
+		if err := s.add(data, path); err != nil {
+			return fmt.Errorf("failed to load schemas from %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func (s *Set) add(data []byte, origin string) error {
+	for i, doc := range manifest.SplitDocuments(data) {
+		var header struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+		}
+		if err := yaml.Unmarshal(doc, &header); err != nil {
+			return fmt.Errorf("failed to decode yaml document #%d: %w", i, err)
+		}
+		if header.Kind == "CustomResourceDefinition" && strings.HasPrefix(header.APIVersion, "apiextensions.k8s.io/") {
+			if err := s.addCRD(doc); err != nil {
+				return fmt.Errorf("document #%d: %w", i, err)
+			}
+			continue
+		}
+		var b bundle
+		if err := yaml.Unmarshal(doc, &b); err != nil {
+			return fmt.Errorf("failed to decode yaml document #%d: %w", i, err)
+		}
+		for name, def := range b.Definitions {
+			s.definitions[name] = def
+		}
+		for _, r := range b.Resources {
+			if r.APIVersion == "" || r.Kind == "" || r.Schema == nil {
+				return fmt.Errorf("document #%d: resource schemas need apiVersion, kind and schema", i)
+			}
+			if r.Since != "" {
+				if err := kubeversion.Check(r.Since); err != nil {
+					return fmt.Errorf("document #%d: since of %s %s: %w", i, r.APIVersion, r.Kind, err)
+				}
+			}
+			s.resources[resourceKey(r.APIVersion, r.Kind)] = resourceSchema{Since: r.Since, Schema: r.Schema, Origin: origin}
+		}
+	}
+	return nil
+}
+
+func (s *Set) addCRD(doc []byte) error {
+	var crd customResourceDefinition
+	if err := yaml.Unmarshal(doc, &crd); err != nil {
+		return fmt.Errorf("failed to decode CustomResourceDefinition: %w", err)
+	}
+	if crd.Spec.Group == "" || crd.Spec.Names.Kind == "" {
+		return fmt.Errorf("CustomResourceDefinition without spec.group or spec.names.kind")
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Served != nil && !*version.Served {
+			continue
+		}
+		root := &Schema{Type: "object", PreserveUnknown: true}
+		if version.Schema.OpenAPIV3Schema != nil {
+			copied := *version.Schema.OpenAPIV3Schema
+			root = &copied
+		}
+		// The API server validates the type meta and the object meta of
+		// custom resources itself; CRD schemas usually leave them out.
+		properties := make(map[string]*Schema, len(root.Properties)+3)
+		for name, prop := range root.Properties {
+			properties[name] = prop
+		}
+		for _, name := range []string{"apiVersion", "kind"} {
+			if properties[name] == nil {
+				properties[name] = &Schema{Type: "string"}
+			}
+		}
+		if properties["metadata"] == nil || len(properties["metadata"].Properties) == 0 {
+			properties["metadata"] = &Schema{Ref: objectMetaRef}
+		}
+		root.Properties = properties
+
+		apiVersion := crd.Spec.Group + "/" + version.Name
+		s.resources[resourceKey(apiVersion, crd.Spec.Names.Kind)] = resourceSchema{Schema: root, Origin: "CustomResourceDefinition " + crd.Spec.Names.Kind}
+	}
+	return nil
+}
+
+// Kinds returns the number of apiVersion and kind pairs in the set.
+func (s *Set) Kinds() int {
+	kinds := make(map[string]bool, len(s.resources))
+	for key := range s.resources {
+		kinds[key] = true
+	}
+	for _, kube := range s.kubernetes {
+		for key := range kube.resources {
+			kinds[key] = true
+		}
+	}
+	return len(kinds)
+}
+
+// KubernetesVersion returns the Kubernetes minor version whose bundled schemas
+// are used for kubeVersion: the newest one not newer than kubeVersion, the
+// oldest one for older versions and the newest one when kubeVersion is empty.
+func (s *Set) KubernetesVersion(kubeVersion string) string {
+	return s.kubernetesSchemas(kubeVersion).version
+}
+
+func (s *Set) kubernetesSchemas(kubeVersion string) *kubernetesSchemas {
+	selected := s.kubernetes[len(s.kubernetes)-1]
+	if kubeVersion == "" {
+		return selected
+	}
+	for i := len(s.kubernetes) - 1; i >= 0; i-- {
+		selected = s.kubernetes[i]
+		if !kubeversion.Less(kubeVersion, selected.version) {
+			break
+		}
+	}
+	return selected
+}
+
+// Validate checks a rendered resource against its schema. kubeVersion, if
+// set, is the Kubernetes version the manifests are rendered for; it selects
+// the bundled schemas, and APIs it does not serve are reported. found is false
+// when the set has no schema for the resource, which is then not checked.
+func (s *Set) Validate(resource manifest.Resource, kubeVersion string) (violations []Violation, found bool) {
+	key := resourceKey(resource.APIVersion, resource.Kind)
+	kube := s.kubernetesSchemas(kubeVersion)
+	var root *Schema
+	if rs, ok := s.resources[key]; ok {
+		if kubeVersion != "" && rs.Since != "" && kubeversion.Less(kubeVersion, rs.Since) {
+			return []Violation{{Message: fmt.Sprintf("%s %s is not served by Kubernetes %s, it is available since %s", resource.APIVersion, resource.Kind, kubeVersion, rs.Since)}}, true
+		}
+		root = rs.Schema
+	} else if root, ok = kube.resources[key]; !ok {
+		served := s.servedBy(key)
+		if len(served) == 0 {
+			return nil, false
+		}
+		if kubeVersion != "" {
+			return []Violation{{Message: fmt.Sprintf("%s %s is not served by Kubernetes %s, %s", resource.APIVersion, resource.Kind, kubeVersion, availability(served, kubeVersion))}}, true
+		}
+		// Without a version, APIs removed from the newest bundled version
+		// are validated against the last one serving them.
+		kube = served[len(served)-1]
+		root = kube.resources[key]
+	}
+
+	var value any
+	if err := yaml.Unmarshal(resource.Raw, &value); err != nil {
+		return []Violation{{Message: fmt.Sprintf("failed to decode resource: %v", err)}}, true
+	}
+	v := &validator{definitions: s.definitions, components: kube.components}
+	v.validate(root, value, "")
+	sort.SliceStable(v.violations, func(i, j int) bool {
+		return v.violations[i].Path < v.violations[j].Path
+	})
+	return v.violations, true
+}
+
+// servedBy returns the bundled versions that have a schema for key.
+func (s *Set) servedBy(key string) []*kubernetesSchemas {
+	var served []*kubernetesSchemas
+	for _, kube := range s.kubernetes {
+		if kube.resources[key] != nil {
+			served = append(served, kube)
+		}
+	}
+	return served
+}
+
+// availability describes when an API served by the given bundled versions,
+// but not by kubeVersion, was added or removed.
+func availability(served []*kubernetesSchemas, kubeVersion string) string {
+	if kubeversion.Less(kubeVersion, served[0].version) {
+		return "it is available since " + served[0].version
+	}
+	return "it was removed after " + served[len(served)-1].version
+}
+
+func resourceKey(apiVersion, kind string) string {
+	return apiVersion + " " + kind
+}
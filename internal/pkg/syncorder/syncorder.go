@@ -0,0 +1,217 @@
+package syncorder
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"roar/internal/pkg/argo"
+	"roar/internal/pkg/manifest"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	WaveAnnotation = "argocd.argoproj.io/sync-wave"
+	HookAnnotation = "argocd.argoproj.io/hook"
+	// Argo CD maps helm hooks to its own phases and uses the hook weight as
+	// the wave of resources without a sync wave.
+	HelmHookAnnotation       = "helm.sh/hook"
+	HelmHookWeightAnnotation = "helm.sh/hook-weight"
+)
+
+const (
+	PhasePreSync    = "PreSync"
+	PhaseSync       = "Sync"
+	PhasePostSync   = "PostSync"
+	PhaseSyncFail   = "SyncFail"
+	PhasePostDelete = "PostDelete"
+	// PhaseSkip marks resources that Argo CD does not apply; they are kept
+	// at the end of the output.
+	PhaseSkip = "Skip"
+)
+
+var phaseOrder = map[string]int{
+	PhasePreSync:    -1,
+	PhaseSync:       0,
+	PhasePostSync:   1,
+	PhaseSyncFail:   2,
+	PhasePostDelete: 3,
+	PhaseSkip:       4,
+}
+
+var helmHooks = map[string]string{
+	"crd-install":  PhasePreSync,
+	"pre-install":  PhasePreSync,
+	"pre-upgrade":  PhasePreSync,
+	"post-install": PhasePostSync,
+	"post-upgrade": PhasePostSync,
+	"post-delete":  PhasePostDelete,
+}
+
+// kinds is the order Argo CD applies the built-in kinds of one phase and
+// wave in; all other kinds, such as custom resources, come after them.
+var kinds = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+var kindOrder = make(map[string]int, len(kinds))
+
+func init() {
+	for i, kind := range kinds {
+		kindOrder[kind] = i - len(kinds)
+	}
+}
+
+// Wave returns the sync wave set by the annotations. Like Argo CD, it falls
+// back to the helm hook weight when the sync wave is missing or invalid and
+// returns 0 when neither is set to a number.
+func Wave(annotations map[string]string) int {
+	for _, key := range []string{WaveAnnotation, HelmHookWeightAnnotation} {
+		if value, ok := annotations[key]; ok {
+			if wave, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return wave
+			}
+		}
+	}
+	return 0
+}
+
+// Phase returns the sync phase of a resource with the annotations: the first
+// phase of an Argo CD hook, the phase of a helm hook or Sync for resources
+// that are not hooks. Helm hooks without an Argo CD counterpart, such as
+// tests, are skipped like Argo CD does.
+func Phase(annotations map[string]string) string {
+	if hook, ok := annotations[HookAnnotation]; ok {
+		for _, phase := range strings.Split(hook, ",") {
+			if phase = strings.TrimSpace(phase); phase != "" {
+				if _, known := phaseOrder[phase]; known {
+					return phase
+				}
+			}
+		}
+		return PhaseSkip
+	}
+	if hook, ok := annotations[HelmHookAnnotation]; ok {
+		for _, name := range strings.Split(hook, ",") {
+			if phase, known := helmHooks[strings.TrimSpace(name)]; known {
+				return phase
+			}
+		}
+		return PhaseSkip
+	}
+	return PhaseSync
+}
+
+// Task is a rendered resource with its place in the sync.
+type Task struct {
+	Resource manifest.Resource
+	Phase    string
+	Wave     int
+}
+
+func less(a, b Task) bool {
+	if a.Phase != b.Phase {
+		return phaseOrder[a.Phase] < phaseOrder[b.Phase]
+	}
+	if a.Wave != b.Wave {
+		return a.Wave < b.Wave
+	}
+	if ka, kb := kindOrder[a.Resource.Kind], kindOrder[b.Resource.Kind]; ka != kb {
+		return ka < kb
+	}
+	return a.Resource.Name < b.Resource.Name
+}
+
+// Tasks returns the resources in the order Argo CD applies them: by phase,
+// wave, kind and name. Resources that compare equal keep their order.
+func Tasks(resources []manifest.Resource) ([]Task, error) {
+	tasks := make([]Task, len(resources))
+	for i, resource := range resources {
+		var header struct {
+			Metadata struct {
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal(resource.Raw, &header); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", resource, err)
+		}
+		tasks[i] = Task{
+			Resource: resource,
+			Phase:    Phase(header.Metadata.Annotations),
+			Wave:     Wave(header.Metadata.Annotations),
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return less(tasks[i], tasks[j]) })
+	return tasks, nil
+}
+
+// Sort reorders the documents of rendered manifests into sync order. Every
+// document keeps its text, including the `# Source:` comment.
+func Sort(rendered []byte) ([]byte, error) {
+	resources, err := manifest.Parse(rendered)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := Tasks(resources)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, task := range tasks {
+		buf.WriteString("---\n")
+		buf.Write(task.Resource.Raw)
+	}
+	return buf.Bytes(), nil
+}
+
+// Applications sorts Applications by sync wave and then by name, the order
+// Argo CD syncs the Applications of an app-of-apps in.
+func Applications(apps []argo.Application) {
+	sort.SliceStable(apps, func(i, j int) bool {
+		a, b := apps[i], apps[j]
+		if wa, wb := Wave(a.Annotations), Wave(b.Annotations); wa != wb {
+			return wa < wb
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Namespace < b.Namespace
+	})
+}
@@ -0,0 +1,187 @@
+package syncorder
+
+import (
+	"testing"
+
+	"roar/internal/pkg/argo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWave(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    int
+	}{
+		{name: "no annotations", expected: 0},
+		{name: "sync wave", annotations: map[string]string{WaveAnnotation: "-2"}, expected: -2},
+		{name: "invalid sync wave", annotations: map[string]string{WaveAnnotation: "first"}, expected: 0},
+		{name: "helm hook weight", annotations: map[string]string{HelmHookWeightAnnotation: " 5 "}, expected: 5},
+		{name: "sync wave wins", annotations: map[string]string{WaveAnnotation: "1", HelmHookWeightAnnotation: "5"}, expected: 1},
+		{name: "invalid sync wave falls back to helm hook weight", annotations: map[string]string{WaveAnnotation: "first", HelmHookWeightAnnotation: "-3"}, expected: -3},
+		{name: "invalid sync wave and helm hook weight", annotations: map[string]string{WaveAnnotation: "first", HelmHookWeightAnnotation: "last"}, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Wave(tc.annotations))
+		})
+	}
+}
+
+func TestPhase(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+	}{
+		{name: "not a hook", expected: PhaseSync},
+		{name: "argo hook", annotations: map[string]string{HookAnnotation: "PostSync"}, expected: PhasePostSync},
+		{name: "several phases", annotations: map[string]string{HookAnnotation: "PreSync, PostSync"}, expected: PhasePreSync},
+		{name: "unknown phase", annotations: map[string]string{HookAnnotation: "Deploy"}, expected: PhaseSkip},
+		{name: "helm hook", annotations: map[string]string{HelmHookAnnotation: "pre-install,pre-upgrade"}, expected: PhasePreSync},
+		{name: "helm test", annotations: map[string]string{HelmHookAnnotation: "test"}, expected: PhaseSkip},
+		{name: "argo hook wins", annotations: map[string]string{HookAnnotation: "Sync", HelmHookAnnotation: "post-install"}, expected: PhaseSync},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Phase(tc.annotations))
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	rendered := `---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+# Source: chart/templates/migrate.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+---
+apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: smoke
+  annotations:
+    helm.sh/hook: post-install
+`
+
+	sorted, err := Sort([]byte(rendered))
+	require.NoError(t, err)
+	require.Equal(t, `---
+# Source: chart/templates/migrate.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: smoke
+  annotations:
+    helm.sh/hook: post-install
+`, string(sorted))
+}
+
+func TestSort_MalformedYAML(t *testing.T) {
+	_, err := Sort([]byte("kind: ConfigMap\nmetadata:\n  annotations: [broken\n"))
+	require.Error(t, err)
+}
+
+func TestApplications(t *testing.T) {
+	application := func(name, namespace, wave string) argo.Application {
+		app := argo.Application{Name: name, Namespace: namespace}
+		if wave != "" {
+			app.Annotations = map[string]string{WaveAnnotation: wave}
+		}
+		return app
+	}
+	apps := []argo.Application{
+		application("web", "", ""),
+		application("db", "", "-1"),
+		application("api", "team", "1"),
+		application("api", "", "1"),
+		application("cache", "", "0"),
+	}
+
+	Applications(apps)
+
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.QualifiedName())
+	}
+	require.Equal(t, []string{"db", "cache", "web", "api", "team/api"}, names)
+}